@@ -11,7 +11,10 @@ import (
 
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
 	httpdelivery "github.com/dovaclean/go-update-orchestrator/pkg/delivery/http"
+	relaydelivery "github.com/dovaclean/go-update-orchestrator/pkg/delivery/relay"
 	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry/sqlite"
@@ -141,7 +144,10 @@ func main() {
 	// For demo, use HTTP delivery (easier to test without SSH setup)
 	httpConfig := httpdelivery.DefaultConfig()
 	httpConfig.Timeout = 10 * time.Second
-	delivery := httpdelivery.NewWithConfig(httpConfig)
+	httpDelivery := httpdelivery.NewWithConfig(httpConfig)
+
+	// Devices in a location with a store relay (see cmd/relay) are pushed through it
+	delivery := relaydelivery.New(reg.(registry.RelayRegistry), httpDelivery)
 	fmt.Println("   ✓ HTTP delivery initialized")
 
 	// If you have SSH setup, you can use this instead:
@@ -168,7 +174,15 @@ func main() {
 	schedConfig := scheduler.DefaultConfig()
 	schedConfig.TickInterval = 30 * time.Second
 	schedConfig.MaxConcurrentUpdates = 3
-	sched := scheduler.New(schedConfig, orch, reg)
+
+	// Stage payloads in a content-addressed cache shared by all updates
+	payloadCache, err := cache.New(cache.DefaultConfig())
+	if err != nil {
+		log.Fatalf("Failed to open payload cache: %v", err)
+	}
+	fetcherConfig := payload.DefaultConfig()
+	fetcherConfig.Cache = payloadCache
+	sched := scheduler.NewWithFetcher(schedConfig, orch, reg, payload.NewFetcher(fetcherConfig))
 
 	if err := sched.Start(ctx); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
@@ -247,6 +261,7 @@ func main() {
 		port = "8081"
 	}
 	webConfig.Address = ":" + port
+	webConfig.PayloadCache = payloadCache
 
 	server, err := web.New(webConfig, orch, sched, reg)
 	if err != nil {
@@ -273,10 +288,17 @@ func main() {
 	fmt.Println("📡 API Endpoints:")
 	fmt.Println("   GET  /api/devices         - List all devices")
 	fmt.Println("   GET  /api/devices/{id}    - Get device details")
+	fmt.Println("   POST /api/devices/heartbeat - Device check-in (triggers on-connect updates)")
 	fmt.Println("   GET  /api/updates         - List all updates")
 	fmt.Println("   GET  /api/updates/{id}    - Get update status")
 	fmt.Println("   POST /api/updates/schedule - Schedule new update")
 	fmt.Println("   POST /api/updates/cancel   - Cancel update")
+	fmt.Println("   POST /api/updates/pause    - Pause update")
+	fmt.Println("   POST /api/updates/resume   - Resume paused update")
+	fmt.Println("   POST /api/updates/approve  - Approve a rollout phase")
+	fmt.Println("   POST /api/updates/reject   - Reject a rollout phase")
+	fmt.Println("   GET  /api/updates/upcoming - Next fire times of a recurring update")
+	fmt.Println("   GET  /api/relays           - List store relays (POST to assign, DELETE to remove)")
 	fmt.Println()
 	fmt.Println("📊 Current Status:")
 	fmt.Printf("   Devices:    %d (3 online, 2 offline)\n", len(sampleDevices))
@@ -0,0 +1,84 @@
+// Command relay runs a store-local relay (see package pkg/relay). The
+// orchestrator uploads each payload to the relay once, and the relay pushes
+// it to the devices in its location over HTTP.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	httpdelivery "github.com/dovaclean/go-update-orchestrator/pkg/delivery/http"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
+	"github.com/dovaclean/go-update-orchestrator/pkg/relay"
+)
+
+func main() {
+	defaults := cache.DefaultConfig()
+	listen := flag.String("listen", ":8090", "address to serve the relay API on")
+	cacheDir := flag.String("cache-dir", defaults.Dir, "directory to store payloads in")
+	maxBytes := flag.Int64("max-bytes", defaults.MaxBytes, "payload cache size budget in bytes (0 = unlimited)")
+	token := flag.String("token", "", "bearer token the orchestrator must send (optional)")
+	updateEndpoint := flag.String("update-endpoint", httpdelivery.DefaultConfig().UpdateEndpoint, "device endpoint payloads are pushed to")
+	location := flag.String("location", "", "location this relay serves, to register with the orchestrator")
+	advertise := flag.String("advertise", "", "base URL the orchestrator reaches this relay at (e.g., http://10.1.0.5:8090)")
+	register := flag.String("register", "", "orchestrator web URL to register with (e.g., http://orchestrator:8080)")
+	flag.Parse()
+
+	payloadCache, err := cache.New(&cache.Config{Dir: *cacheDir, MaxBytes: *maxBytes})
+	if err != nil {
+		log.Fatalf("Failed to open payload cache: %v", err)
+	}
+
+	deliveryConfig := httpdelivery.DefaultConfig()
+	deliveryConfig.UpdateEndpoint = *updateEndpoint
+	server, err := relay.NewServer(&relay.Config{
+		Cache:    payloadCache,
+		Delivery: httpdelivery.NewWithConfig(deliveryConfig),
+		Token:    *token,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create relay: %v", err)
+	}
+
+	if *register != "" {
+		if *location == "" || *advertise == "" {
+			log.Fatal("-register requires -location and -advertise")
+		}
+		if err := registerRelay(*register, core.Relay{Location: *location, Address: *advertise}); err != nil {
+			log.Fatalf("Failed to register relay: %v", err)
+		}
+		log.Printf("Registered as the relay for %s", *location)
+	}
+
+	log.Printf("Relay listening on %s (cache: %s)", *listen, *cacheDir)
+	if err := http.ListenAndServe(*listen, server); err != nil {
+		log.Fatalf("Relay server error: %v", err)
+	}
+}
+
+// registerRelay assigns the relay to its location through the orchestrator's
+// /api/relays endpoint.
+func registerRelay(orchestratorURL string, r core.Relay) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(orchestratorURL+"/api/relays", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("orchestrator returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -11,6 +11,14 @@ const (
 	DeviceUnknown DeviceStatus = "unknown" // Never seen or status unclear
 )
 
+// MetadataTimeZone is the device metadata key holding the device's IANA
+// time zone (e.g., "America/Chicago"), used to evaluate update windows.
+const MetadataTimeZone = "timezone"
+
+// MetadataHardwareModel is the device metadata key holding the device's
+// hardware model, matched against the models an update manifest supports.
+const MetadataHardwareModel = "hardware_model"
+
 // Device represents a target device for updates.
 type Device struct {
 	ID              string            // Unique device identifier
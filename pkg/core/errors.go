@@ -24,6 +24,22 @@ var (
 	// ErrInvalidUpdate indicates update validation failed.
 	ErrInvalidUpdate = errors.New("invalid update")
 
+	// ErrSuccessThresholdNotMet indicates a rollout phase fell below its success rate.
+	ErrSuccessThresholdNotMet = errors.New("success threshold not met")
+
+	// ErrUpdateRejected indicates an operator rejected an update at an approval gate.
+	ErrUpdateRejected = errors.New("update rejected")
+
+	// ErrUpdateInterrupted indicates an update was running when the scheduler stopped.
+	ErrUpdateInterrupted = errors.New("update interrupted by restart")
+
+	// ErrInvalidManifest indicates an update manifest is missing, malformed,
+	// expired or not signed by a trusted key.
+	ErrInvalidManifest = errors.New("invalid update manifest")
+
+	// ErrRelayNotFound indicates no relay is assigned to a location.
+	ErrRelayNotFound = errors.New("relay not found")
+
 	// ErrCancelled indicates the operation was cancelled.
 	ErrCancelled = errors.New("operation cancelled")
 )
@@ -0,0 +1,9 @@
+package core
+
+// Relay is a distribution point inside a location (e.g., a store) that
+// receives each payload once and passes it on to the location's devices over
+// the local network, so the payload crosses the location's uplink only once.
+type Relay struct {
+	Location string // Location the relay serves (matches Device.Location)
+	Address  string // Base URL of the relay (e.g., "http://10.1.0.5:8090")
+}
@@ -9,10 +9,12 @@ const (
 	StatusPending    UpdateStatus = "pending"     // Waiting to start
 	StatusScheduled  UpdateStatus = "scheduled"   // Scheduled for future execution
 	StatusInProgress UpdateStatus = "in_progress" // Currently executing
+	StatusVerifying  UpdateStatus = "verifying"   // Payload delivered, checking it was applied
 	StatusCompleted  UpdateStatus = "completed"   // Successfully completed
 	StatusFailed     UpdateStatus = "failed"      // Failed (some/all devices)
 	StatusCancelled  UpdateStatus = "cancelled"   // Cancelled by user
 	StatusPaused     UpdateStatus = "paused"      // Temporarily paused
+	StatusAwaitingApproval UpdateStatus = "awaiting_approval" // Held until an operator approves
 )
 
 // UpdateStrategy defines how the update should be rolled out.
@@ -23,6 +25,7 @@ const (
 	StrategyScheduled   UpdateStrategy = "scheduled"   // Execute at specific time
 	StrategyProgressive UpdateStrategy = "progressive" // Gradual rollout in phases
 	StrategyOnConnect   UpdateStrategy = "on_connect"  // Update when device connects
+	StrategyRecurring   UpdateStrategy = "recurring"   // Spawn an update instance on a cron schedule
 )
 
 // Update represents an update job to be executed.
@@ -30,25 +33,88 @@ type Update struct {
 	ID          string            // Unique update identifier
 	Name        string            // Human-readable name
 	PayloadURL  string            // Location of the update payload
+	PayloadSHA256 string          // Expected hex-encoded SHA-256 digest of the payload (optional)
+	PayloadSize int64             // Expected payload size in bytes (optional)
+	Manifest    []byte            // Signed JSON manifest describing the payload (optional)
+	ManifestSignature []byte      // Ed25519 signature of Manifest
+	TargetVersion string          // Firmware version devices report once updated (optional)
 	DeviceIDs   []string          // Target devices (if empty, use DeviceFilter)
 	DeviceFilter *Filter          // Dynamic device selection
 	Strategy    UpdateStrategy    // How to roll out the update
 	ScheduledAt *time.Time        // When to execute (for scheduled strategy)
 	WindowStart *time.Time        // Start of update window (e.g., 2 AM)
 	WindowEnd   *time.Time        // End of update window (e.g., 4 AM)
+	Window      *UpdateWindow     // Recurring window, evaluated in each device's time zone
 	RolloutPhases []RolloutPhase  // Phases for progressive strategy
+	RollbackPayloadURL string     // Payload that reverts the update (optional)
+	RollbackVersion string        // Firmware version devices report once rolled back (optional)
+	RollbackOf  string            // Update this update reverts (set on rollback updates)
+	Recurrence  *Recurrence       // Cron schedule (for recurring strategy)
+	InstanceOf  string            // Recurring update this instance was spawned from
 	Metadata    map[string]string // Custom update metadata
 	CreatedAt   time.Time         // When the update was created
 }
 
+// UpdateWindow is a recurring daily or weekly window in which devices may be
+// updated. Times are local to each device: the device's MetadataTimeZone is
+// used if set, otherwise TimeZone, otherwise UTC.
+type UpdateWindow struct {
+	Start    string         // Local opening time, "HH:MM" (e.g., "02:00")
+	End      string         // Local closing time, "HH:MM"; before Start wraps past midnight
+	Days     []time.Weekday // Days the window opens (empty = every day)
+	TimeZone string         // Default IANA time zone for devices without one
+}
+
+// Recurrence describes when a recurring update fires. Each firing spawns a
+// child instance of the update, rolled out progressively if the update has
+// rollout phases and immediately otherwise.
+type Recurrence struct {
+	Cron     string        // Five-field cron expression ("minute hour day-of-month month day-of-week") or a macro such as "@daily"
+	TimeZone string        // IANA time zone the expression is evaluated in (default UTC)
+	Overlap  OverlapPolicy // What to do when the previous instance is still running (default: skip)
+	CatchUp  CatchUpPolicy // What to do with firings missed while the scheduler was down (default: skip)
+}
+
+// OverlapPolicy defines what happens when a recurring update fires while its
+// previous instance is still running.
+type OverlapPolicy string
+
+const (
+	OverlapSkip    OverlapPolicy = "skip"    // Drop the new firing
+	OverlapQueue   OverlapPolicy = "queue"   // Start the new instance once the previous one finishes
+	OverlapReplace OverlapPolicy = "replace" // Cancel the previous instance and start the new one
+)
+
+// CatchUpPolicy defines what happens to firings of a recurring update that
+// were missed (e.g., because the scheduler was stopped or the update paused).
+type CatchUpPolicy string
+
+const (
+	CatchUpSkip CatchUpPolicy = "skip" // Drop missed firings
+	CatchUpOnce CatchUpPolicy = "once" // Run a single instance for all missed firings
+	CatchUpAll  CatchUpPolicy = "all"  // Run an instance per missed firing (subject to the overlap policy)
+)
+
 // RolloutPhase represents a phase in a progressive rollout.
 type RolloutPhase struct {
 	Name        string    // Phase name (e.g., "Canary", "Phase 1")
 	Percentage  int       // Percentage of devices to update (1-100)
 	WaitTime    time.Duration // Time to wait after phase before next
 	SuccessRate int       // Minimum success rate to proceed (0-100)
+	ObservationPeriod time.Duration // Time to watch updated devices before checking SuccessRate
+	RequiresApproval bool           // Hold the rollout for operator approval before this phase starts
+	OnGateFailure GateAction      // What to do when SuccessRate is not met (default: halt)
 }
 
+// GateAction defines what happens when a rollout phase misses its success rate.
+type GateAction string
+
+const (
+	GateHalt     GateAction = "halt"     // Stop the rollout and fail the update
+	GateBlock    GateAction = "block"    // Pause the rollout until it is resumed
+	GateApproval GateAction = "approval" // Hold the rollout until an operator approves it
+)
+
 // Status represents the current state of an update job.
 type Status struct {
 	UpdateID      string            // Update identifier
@@ -61,4 +127,22 @@ type Status struct {
 	StartedAt     time.Time         // When the update started
 	CompletedAt   *time.Time        // When the update completed (nil if not done)
 	EstimatedEnd  *time.Time        // Estimated completion time
+	EstimatedEarliest *time.Time    // Lower confidence bound of EstimatedEnd
+	EstimatedLatest *time.Time      // Upper confidence bound of EstimatedEnd
+	Throughput    float64           // Bytes transferred per second
+	RollbackID    string            // Linked rollback update (if one was triggered)
+	RollbackOf    string            // Update this update reverts (for rollback updates)
+	PendingApproval string          // Phase awaiting operator approval (if any)
+	Approvals     []Approval        // Approval gate decisions, oldest first
+	InstanceOf    string            // Recurring update this instance was spawned from
+	NextRun       *time.Time        // Next firing (for recurring updates)
+}
+
+// Approval records an operator's decision on a rollout approval gate.
+type Approval struct {
+	Phase    string    // Phase the decision applies to
+	Approver string    // Identity of the operator
+	Approved bool      // True if approved, false if rejected
+	Reason   string    // Optional reason (e.g., for a rejection)
+	Time     time.Time // When the decision was made
 }
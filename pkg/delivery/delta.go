@@ -0,0 +1,37 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDeltaRejected indicates a device refused a delta payload, e.g. because
+// it does not run the base version or cannot apply patches. The full
+// payload can be pushed instead.
+var ErrDeltaRejected = errors.New("device rejected delta payload")
+
+// DeltaPayload describes a delta payload: a patch that turns the firmware a
+// device runs into the update's firmware image.
+type DeltaPayload struct {
+	BaseVersion   string // Firmware version the patch applies to
+	TargetVersion string // Firmware version the patched image installs
+	Target        Digest // Digest of the patched image
+}
+
+// deltaKey is the context key for the delta payload description.
+type deltaKey struct{}
+
+// WithDelta returns a context for pushing a delta payload instead of the full
+// image. The payload digest carried by the context (see WithPayloadDigest)
+// then describes the patch, and Target the image it produces. Push
+// implementations tell the device so and return an error matching
+// ErrDeltaRejected if the device refuses the patch.
+func WithDelta(ctx context.Context, delta DeltaPayload) context.Context {
+	return context.WithValue(ctx, deltaKey{}, delta)
+}
+
+// Delta returns the delta payload description carried by ctx, if any.
+func Delta(ctx context.Context) (DeltaPayload, bool) {
+	delta, ok := ctx.Value(deltaKey{}).(DeltaPayload)
+	return delta, ok && delta.BaseVersion != ""
+}
@@ -0,0 +1,39 @@
+package delivery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Digest identifies the content of an update payload.
+type Digest struct {
+	SHA256 string // Hex-encoded SHA-256 digest
+	Size   int64  // Size in bytes
+}
+
+// Header returns the digest in the RFC 3230 Digest header format
+// (e.g., "sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=").
+func (d Digest) Header() string {
+	sum, err := hex.DecodeString(d.SHA256)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// digestKey is the context key for the payload digest.
+type digestKey struct{}
+
+// WithPayloadDigest returns a context carrying the digest of the payload
+// being pushed. Push implementations forward it to the device or use it to
+// check the payload the device received.
+func WithPayloadDigest(ctx context.Context, digest Digest) context.Context {
+	return context.WithValue(ctx, digestKey{}, digest)
+}
+
+// PayloadDigest returns the payload digest carried by ctx, if any.
+func PayloadDigest(ctx context.Context) (Digest, bool) {
+	digest, ok := ctx.Value(digestKey{}).(Digest)
+	return digest, ok && digest.SHA256 != ""
+}
@@ -3,15 +3,19 @@ package http
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dovaclean/go-update-orchestrator/internal/retry"
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
 )
 
 // Config holds HTTP delivery configuration.
@@ -39,8 +43,35 @@ type Config struct {
 
 	// SkipTLSVerify bypasses certificate verification (insecure, for testing)
 	SkipTLSVerify bool
+
+	// VersionFormat selects how the verify response is parsed (default: json)
+	VersionFormat VersionFormat
+
+	// VersionField is the dot-separated path of the version in a JSON
+	// verify response, e.g. "version" or "firmware.current" (default: version)
+	VersionField string
+
+	// Resumable enables resuming interrupted uploads. Before each attempt
+	// the device is asked with a HEAD request on UpdateEndpoint how much of
+	// the payload it already holds (UploadOffsetHeader), and only the rest
+	// is sent, with a Content-Range header. Devices that do not answer get
+	// the whole payload. Requires a seekable payload and a payload digest
+	// (see delivery.WithPayloadDigest), which identifies the upload.
+	Resumable bool
 }
 
+// UploadOffsetHeader is the response header in which devices supporting
+// resumable uploads report how many bytes of a payload they already hold.
+const UploadOffsetHeader = "Upload-Offset"
+
+// VersionFormat describes the body returned by the verify endpoint.
+type VersionFormat string
+
+const (
+	VersionFormatJSON VersionFormat = "json" // JSON object containing the version at VersionField
+	VersionFormatText VersionFormat = "text" // Plain-text body containing only the version
+)
+
 // DefaultConfig returns sensible defaults for HTTP delivery.
 func DefaultConfig() *Config {
 	return &Config{
@@ -50,29 +81,11 @@ func DefaultConfig() *Config {
 		VerifyEndpoint: "/version",
 		MaxRetries:     3,
 		SkipTLSVerify:  false,
+		VersionFormat:  VersionFormatJSON,
+		VersionField:   "version",
 	}
 }
 
-// safeSeeker wraps an io.ReadSeeker with a mutex for thread-safe operations.
-// This is needed when the same payload is shared across multiple goroutines.
-// Both Read and Seek must be protected to avoid races.
-type safeSeeker struct {
-	rs io.ReadSeeker
-	mu sync.Mutex
-}
-
-func (s *safeSeeker) Read(p []byte) (n int, err error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.rs.Read(p)
-}
-
-func (s *safeSeeker) Seek(offset int64, whence int) (int64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.rs.Seek(offset, whence)
-}
-
 // Delivery implements HTTP-based update delivery.
 type Delivery struct {
 	config      *Config
@@ -123,31 +136,33 @@ func NewWithConfig(config *Config) *Delivery {
 
 // Push delivers the update payload to a device via HTTP POST.
 // The payload is streamed directly to the device without loading into memory.
-// Retries are supported if the payload implements io.Seeker (e.g., *os.File, *bytes.Reader).
+// Retries are supported if the payload implements io.Seeker (e.g., *os.File, *bytes.Reader);
+// with Config.Resumable they continue from where the device left off.
+// Delta payloads (see delivery.WithDelta) are sent with X-Delta-* headers;
+// a device answering 409 Conflict or 415 Unsupported Media Type rejects the
+// delta, which is reported as delivery.ErrDeltaRejected.
 func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
 	url := device.Address + d.config.UpdateEndpoint
 
-	// Wrap seekable payloads in a thread-safe wrapper for concurrent access
-	var seeker io.Seeker
-	var canSeek bool
+	// Report bytes sent if the caller asked for progress
+	payload = delivery.NewProgressReader(ctx, payload)
+	seeker, canSeek := payload.(io.Seeker)
 
-	if rs, ok := payload.(io.ReadSeeker); ok {
-		// Wrap in thread-safe seeker to prevent race conditions
-		// when the same payload is used by multiple goroutines
-		safe := &safeSeeker{rs: rs}
-		payload = safe
-		seeker = safe
-		canSeek = true
-	} else if s, ok := payload.(io.Seeker); ok {
-		seeker = s
-		canSeek = true
-	}
+	digest, hasDigest := delivery.PayloadDigest(ctx)
+	deltaPayload, isDelta := delivery.Delta(ctx)
+	resumable := d.config.Resumable && canSeek && hasDigest && digest.Size > 0
 
 	// Wrap the push logic for retry
 	return retry.Do(ctx, d.retryConfig, func() error {
-		// Reset payload to beginning if this is a retry
+		// Resume from what the device already holds, if it can tell
+		var offset int64
+		if resumable {
+			offset = d.uploadOffset(ctx, url, device, digest)
+		}
+
+		// Reset payload to the offset if this is a retry
 		if canSeek && seeker != nil {
-			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
 				return fmt.Errorf("failed to reset payload for retry: %w", err)
 			}
 		}
@@ -157,6 +172,10 @@ func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Read
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
+		if offset > 0 {
+			req.ContentLength = digest.Size - offset
+			req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, digest.Size-1, digest.Size))
+		}
 
 		// Set content type
 		req.Header.Set("Content-Type", "application/octet-stream")
@@ -170,6 +189,23 @@ func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Read
 		req.Header.Set("X-Device-ID", device.ID)
 		req.Header.Set("X-Device-Name", device.Name)
 
+		// Let the device check the payload it received
+		if digest, ok := delivery.PayloadDigest(ctx); ok {
+			req.Header.Set("Digest", digest.Header())
+			req.Header.Set("X-Payload-SHA256", digest.SHA256)
+		}
+		if manifest, ok := delivery.Manifest(ctx); ok {
+			req.Header.Set("X-Update-Manifest", base64.StdEncoding.EncodeToString(manifest.Manifest))
+			req.Header.Set("X-Update-Manifest-Signature", base64.StdEncoding.EncodeToString(manifest.Signature))
+		}
+
+		// Tell the device the payload is a patch and what it must produce
+		if isDelta {
+			req.Header.Set("X-Delta-Base-Version", deltaPayload.BaseVersion)
+			req.Header.Set("X-Delta-Target-Version", deltaPayload.TargetVersion)
+			req.Header.Set("X-Delta-Target-SHA256", deltaPayload.Target.SHA256)
+		}
+
 		// Execute the request
 		resp, err := d.client.Do(req)
 		if err != nil {
@@ -192,6 +228,18 @@ func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Read
 				return fmt.Errorf("update push failed with status %d: %s", resp.StatusCode, string(body[:n]))
 			}
 
+			// The device no longer holds what it reported; ask again next attempt
+			if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+				return fmt.Errorf("update push failed with status %d: %s", resp.StatusCode, string(body[:n]))
+			}
+
+			// The device cannot apply this patch; the full payload may still do
+			if isDelta && (resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusUnsupportedMediaType) {
+				return &retry.NonRetryable{
+					Err: fmt.Errorf("%w: status %d: %s", delivery.ErrDeltaRejected, resp.StatusCode, string(body[:n])),
+				}
+			}
+
 			// 4xx errors should not be retried (client error)
 			return &retry.NonRetryable{
 				Err: fmt.Errorf("update push failed with status %d: %s", resp.StatusCode, string(body[:n])),
@@ -202,6 +250,36 @@ func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Read
 	})
 }
 
+// uploadOffset asks the device how many bytes of the payload it already
+// holds from an interrupted upload. It returns 0 (send everything) if the
+// device does not support resumable uploads or reports an unusable offset.
+func (d *Delivery) uploadOffset(ctx context.Context, url string, device core.Device, digest delivery.Digest) int64 {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0
+	}
+	for key, value := range d.config.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("X-Device-ID", device.ID)
+	req.Header.Set("X-Payload-SHA256", digest.SHA256)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+	offset, err := strconv.ParseInt(resp.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 || offset >= digest.Size {
+		return 0
+	}
+	return offset
+}
+
 // isRetryable determines if an error is worth retrying
 func isRetryable(err error) bool {
 	// Context errors should not be retried
@@ -214,7 +292,10 @@ func isRetryable(err error) bool {
 }
 
 // Verify checks if the update was successfully applied via HTTP GET.
-// This calls the device's version endpoint and checks the firmware version.
+// This calls the device's version endpoint and, if the context carries a
+// target version (see delivery.WithTargetVersion), compares the reported
+// firmware version against it, returning a *delivery.VersionMismatchError
+// on mismatch.
 func (d *Delivery) Verify(ctx context.Context, device core.Device) error {
 	// Build the verify URL
 	url := device.Address + d.config.VerifyEndpoint
@@ -242,7 +323,67 @@ func (d *Delivery) Verify(ctx context.Context, device core.Device) error {
 		return fmt.Errorf("verify failed with status %d", resp.StatusCode)
 	}
 
-	// TODO: Parse response body and verify firmware version
-	// For now, just check that the endpoint is reachable
-	return nil
+	// Without an expected version, a reachable endpoint is enough
+	expected, ok := delivery.TargetVersion(ctx)
+	if !ok {
+		return nil
+	}
+
+	reported, err := d.parseVersion(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to parse version from %s: %w", device.Address, err)
+	}
+
+	return delivery.CheckVersion(device.ID, expected, reported)
+}
+
+// maxVersionResponseSize limits how much of the verify response is read.
+const maxVersionResponseSize = 64 * 1024
+
+// parseVersion extracts the firmware version from a verify response body.
+func (d *Delivery) parseVersion(body io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxVersionResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if d.config.VersionFormat == VersionFormatText {
+		version := strings.TrimSpace(string(data))
+		if version == "" {
+			return "", errors.New("empty version response")
+		}
+		return version, nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("invalid JSON response: %w", err)
+	}
+
+	field := d.config.VersionField
+	if field == "" {
+		field = "version"
+	}
+
+	// Walk the dot-separated path through nested objects
+	value := doc
+	for _, key := range strings.Split(field, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("field %q not found in response", field)
+		}
+		if value, ok = obj[key]; !ok {
+			return "", fmt.Errorf("field %q not found in response", field)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		// Some devices report numeric build versions
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("field %q is not a string", field)
+	}
 }
@@ -2,6 +2,10 @@ package http
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,7 +14,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dovaclean/go-update-orchestrator/internal/retry"
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delta"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
 )
 
 func TestNew(t *testing.T) {
@@ -318,6 +326,119 @@ func TestVerify_CustomEndpoint(t *testing.T) {
 	}
 }
 
+func TestVerify_VersionMatch(t *testing.T) {
+	server := mocks.NewDeviceServer("2.4.0")
+	defer server.Close()
+
+	d := New()
+	device := core.Device{ID: "test-device-001", Address: server.URL()}
+
+	ctx := delivery.WithTargetVersion(context.Background(), "2.4.0")
+	if err := d.Verify(ctx, device); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+}
+
+func TestVerify_EquivalentVersion(t *testing.T) {
+	server := mocks.NewDeviceServer("v2.4")
+	defer server.Close()
+
+	d := New()
+	device := core.Device{ID: "test-device-001", Address: server.URL()}
+
+	// Versions are compared by precedence, not byte for byte
+	for _, target := range []string{"2.4.0", "v2.4.0+build.7"} {
+		if err := d.Verify(delivery.WithTargetVersion(context.Background(), target), device); err != nil {
+			t.Errorf("Verify() against %s failed: %v", target, err)
+		}
+	}
+}
+
+func TestVerify_VersionMismatch(t *testing.T) {
+	server := mocks.NewDeviceServer("2.3.9")
+	defer server.Close()
+
+	d := New()
+	device := core.Device{ID: "test-device-001", Address: server.URL()}
+
+	ctx := delivery.WithTargetVersion(context.Background(), "2.4.0")
+	err := d.Verify(ctx, device)
+	if err == nil {
+		t.Fatal("expected version mismatch error, got nil")
+	}
+
+	var mismatch *delivery.VersionMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected *delivery.VersionMismatchError, got %T: %v", err, err)
+	}
+	if mismatch.Reported != "2.3.9" || mismatch.Expected != "2.4.0" {
+		t.Errorf("expected reported 2.3.9 / expected 2.4.0, got %s / %s", mismatch.Reported, mismatch.Expected)
+	}
+	if !errors.Is(err, core.ErrVerificationFailed) {
+		t.Error("expected mismatch to match core.ErrVerificationFailed")
+	}
+}
+
+func TestVerify_NestedVersionField(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"firmware": {"current": "3.1.0", "previous": "3.0.0"}}`))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.VersionField = "firmware.current"
+	d := NewWithConfig(config)
+	device := core.Device{ID: "test-device-001", Address: server.URL}
+
+	ctx := delivery.WithTargetVersion(context.Background(), "3.1.0")
+	if err := d.Verify(ctx, device); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+}
+
+func TestVerify_MissingVersionField(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	d := New()
+	device := core.Device{ID: "test-device-001", Address: server.URL}
+
+	ctx := delivery.WithTargetVersion(context.Background(), "3.1.0")
+	err := d.Verify(ctx, device)
+	if err == nil {
+		t.Fatal("expected error for missing version field, got nil")
+	}
+
+	var mismatch *delivery.VersionMismatchError
+	if errors.As(err, &mismatch) {
+		t.Error("missing field should not be reported as a version mismatch")
+	}
+}
+
+func TestVerify_TextVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v2.3-r7\n"))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.VersionFormat = VersionFormatText
+	d := NewWithConfig(config)
+	device := core.Device{ID: "test-device-001", Address: server.URL}
+
+	if err := d.Verify(delivery.WithTargetVersion(context.Background(), "v2.3-r7"), device); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	err := d.Verify(delivery.WithTargetVersion(context.Background(), "v2.4-r1"), device)
+	var mismatch *delivery.VersionMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Reported != "v2.3-r7" {
+		t.Errorf("expected mismatch reporting v2.3-r7, got %v", err)
+	}
+}
+
 func TestDefaultConfig(t *testing.T) {
 	config := DefaultConfig()
 
@@ -570,6 +691,72 @@ func TestPush_RetryWithSeeker(t *testing.T) {
 	}
 }
 
+func TestPush_ReportsProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	payload := strings.Repeat("A", 256*1024)
+
+	var reports []int64
+	ctx := delivery.WithProgress(context.Background(), func(sent int64) {
+		reports = append(reports, sent)
+	})
+
+	d := New()
+	if err := d.Push(ctx, core.Device{ID: "test-device", Address: server.URL}, strings.NewReader(payload)); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatal("Expected progress reports")
+	}
+	if last := reports[len(reports)-1]; last != int64(len(payload)) {
+		t.Errorf("Expected final report of %d bytes, got %d", len(payload), last)
+	}
+}
+
+func TestPush_ProgressRestartsOnRetry(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		io.Copy(io.Discard, r.Body)
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var reports []int64
+	ctx := delivery.WithProgress(context.Background(), func(sent int64) {
+		reports = append(reports, sent)
+	})
+
+	d := New()
+	if err := d.Push(ctx, core.Device{ID: "test-device", Address: server.URL}, strings.NewReader("seekable payload")); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	// The retry restarts the count at 0, then reports the whole payload
+	// again, not twice the bytes
+	restarted := false
+	for _, sent := range reports {
+		if sent == 0 {
+			restarted = true
+		} else if sent != int64(len("seekable payload")) {
+			t.Errorf("Expected reports of %d bytes, got %v", len("seekable payload"), reports)
+			break
+		}
+	}
+	if !restarted {
+		t.Errorf("Expected the retry to restart the count at 0, got %v", reports)
+	}
+}
+
 // TestPush_CustomRetryConfig tests custom retry configuration
 func TestPush_CustomRetryConfig(t *testing.T) {
 	attemptCount := 0
@@ -602,3 +789,216 @@ func TestPush_CustomRetryConfig(t *testing.T) {
 		t.Errorf("Expected 5 attempts (custom config), got %d", attemptCount)
 	}
 }
+
+func TestPush_SendsPayloadDigest(t *testing.T) {
+	var digestHeader, shaHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digestHeader = r.Header.Get("Digest")
+		shaHeader = r.Header.Get("X-Payload-SHA256")
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// SHA-256 of "hello"
+	digest := delivery.Digest{
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Size:   5,
+	}
+	ctx := delivery.WithPayloadDigest(context.Background(), digest)
+
+	d := New()
+	if err := d.Push(ctx, core.Device{ID: "test-device", Address: server.URL}, strings.NewReader("hello")); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	if shaHeader != digest.SHA256 {
+		t.Errorf("Expected X-Payload-SHA256 %s, got %q", digest.SHA256, shaHeader)
+	}
+	if expected := "sha-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="; digestHeader != expected {
+		t.Errorf("Expected Digest %s, got %q", expected, digestHeader)
+	}
+}
+
+func TestPush_ForwardsManifest(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	data := []byte(`{"payload_sha256":"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}`)
+	signed := delivery.SignedManifest{Manifest: data, Signature: ed25519.Sign(priv, data)}
+
+	// The device verifies the manifest with its own copy of the key
+	device := mocks.NewDeviceServer("1.0.0")
+	defer device.Close()
+	device.SetManifestKey(pub)
+
+	d := New()
+	target := core.Device{ID: "test-device", Address: device.URL()}
+
+	if err := d.Push(context.Background(), target, strings.NewReader("hello")); err == nil {
+		t.Error("Expected the device to reject an update without a manifest")
+	}
+
+	ctx := delivery.WithManifest(context.Background(), signed)
+	if err := d.Push(ctx, target, strings.NewReader("hello")); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+	if string(device.GetLastManifest()) != string(data) {
+		t.Errorf("Expected the device to receive the manifest, got %q", device.GetLastManifest())
+	}
+}
+
+// resumableTestPayload returns a payload and a context carrying its digest.
+func resumableTestPayload() (string, context.Context) {
+	payload := strings.Repeat("firmware", 32*1024) // 256 KiB
+	sum := sha256.Sum256([]byte(payload))
+	ctx := delivery.WithPayloadDigest(context.Background(), delivery.Digest{
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   int64(len(payload)),
+	})
+	return payload, ctx
+}
+
+// newResumableDelivery returns a resumable delivery that retries quickly.
+func newResumableDelivery() *Delivery {
+	config := DefaultConfig()
+	config.Resumable = true
+	config.RetryConfig = &retry.Config{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1.0,
+	}
+	return NewWithConfig(config)
+}
+
+func TestPush_ResumesInterruptedUpload(t *testing.T) {
+	device := mocks.NewDeviceServer("1.0.0")
+	defer device.Close()
+	device.SetResumable(true)
+	device.SetInterruptAfter(100 * 1024)
+
+	payload, ctx := resumableTestPayload()
+	var reports []int64
+	ctx = delivery.WithProgress(ctx, func(sent int64) {
+		reports = append(reports, sent)
+	})
+
+	d := newResumableDelivery()
+	if err := d.Push(ctx, core.Device{ID: "test-device", Address: device.URL()}, strings.NewReader(payload)); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	// The retry only sends what the device did not already have
+	if received := device.GetBytesReceived(); received != int64(len(payload)) {
+		t.Errorf("Expected %d bytes on the wire, got %d", len(payload), received)
+	}
+	if device.GetUpdateCount() != 1 || device.GetLastUpdateSize() != int64(len(payload)) {
+		t.Errorf("Expected one complete update, got %d updates of %d bytes", device.GetUpdateCount(), device.GetLastUpdateSize())
+	}
+
+	// Progress restarts at the resume offset, not at 0
+	resumed := false
+	for _, sent := range reports {
+		if sent == 100*1024 {
+			resumed = true
+		}
+	}
+	if !resumed || reports[len(reports)-1] != int64(len(payload)) {
+		t.Errorf("Expected progress to resume at %d bytes, got %v", 100*1024, reports)
+	}
+}
+
+func TestPush_ResumeFallsBackToFullUpload(t *testing.T) {
+	// The device does not support resumable uploads
+	device := mocks.NewDeviceServer("1.0.0")
+	defer device.Close()
+	device.SetInterruptAfter(100 * 1024)
+
+	payload, ctx := resumableTestPayload()
+
+	d := newResumableDelivery()
+	if err := d.Push(ctx, core.Device{ID: "test-device", Address: device.URL()}, strings.NewReader(payload)); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	if received := device.GetBytesReceived(); received != int64(len(payload))+100*1024 {
+		t.Errorf("Expected the whole payload to be sent again, got %d bytes on the wire", received)
+	}
+	if device.GetLastUpdateSize() != int64(len(payload)) {
+		t.Errorf("Expected a complete update, got %d bytes", device.GetLastUpdateSize())
+	}
+}
+
+// deltaTestPush returns the images of versions 1.0.0 and 2.0.0 of a
+// firmware, a patch between them and a context for pushing the patch.
+func deltaTestPush(t *testing.T) (base, image, patch []byte, ctx context.Context) {
+	t.Helper()
+
+	base = []byte(strings.Repeat("firmware 1.0.0 ", 1024))
+	image = []byte(strings.Replace(string(base), "1.0.0", "2.0.0", 1))
+	patch, err := delta.Diff(base, image)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	target := sha256.Sum256(image)
+	ctx = delivery.WithDelta(context.Background(), delivery.DeltaPayload{
+		BaseVersion:   "1.0.0",
+		TargetVersion: "2.0.0",
+		Target:        delivery.Digest{SHA256: hex.EncodeToString(target[:]), Size: int64(len(image))},
+	})
+	return base, image, patch, ctx
+}
+
+func TestPush_DeltaPayload(t *testing.T) {
+	base, image, patch, ctx := deltaTestPush(t)
+
+	device := mocks.NewDeviceServer("1.0.0")
+	defer device.Close()
+	device.SetImage(base)
+	device.SetAcceptDeltas(true)
+
+	d := New()
+	if err := d.Push(ctx, core.Device{ID: "test-device", Address: device.URL()}, strings.NewReader(string(patch))); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	if device.GetDeltaCount() != 1 || string(device.GetImage()) != string(image) {
+		t.Error("Expected the device to patch its image to the target")
+	}
+	if device.GetBytesReceived() != int64(len(patch)) {
+		t.Errorf("Expected only the %d byte patch on the wire, got %d bytes", len(patch), device.GetBytesReceived())
+	}
+}
+
+func TestPush_DeltaRejected(t *testing.T) {
+	base, _, patch, ctx := deltaTestPush(t)
+
+	tests := []struct {
+		name   string
+		setup  func(device *mocks.DeviceServer)
+		status int
+	}{
+		{"deltas unsupported", func(device *mocks.DeviceServer) {}, http.StatusUnsupportedMediaType},
+		{"other base version", func(device *mocks.DeviceServer) {
+			device.SetAcceptDeltas(true)
+			device.SetImage([]byte("firmware 0.9.0"))
+		}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := mocks.NewDeviceServer("1.0.0")
+			defer device.Close()
+			device.SetImage(base)
+			tt.setup(device)
+
+			err := New().Push(ctx, core.Device{ID: "test-device", Address: device.URL()}, strings.NewReader(string(patch)))
+			if !errors.Is(err, delivery.ErrDeltaRejected) {
+				t.Errorf("Expected ErrDeltaRejected, got %v", err)
+			}
+			if !strings.Contains(err.Error(), fmt.Sprint(tt.status)) {
+				t.Errorf("Expected status %d, got %v", tt.status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package delivery
+
+import "context"
+
+// SignedManifest is an update manifest together with its signature, as
+// forwarded to devices so they can check the update independently.
+type SignedManifest struct {
+	Manifest  []byte // Encoded JSON manifest
+	Signature []byte // Ed25519 signature of Manifest
+}
+
+// manifestKey is the context key for the signed manifest.
+type manifestKey struct{}
+
+// WithManifest returns a context carrying the signed manifest of the update
+// being pushed. Push implementations forward it to the device.
+func WithManifest(ctx context.Context, manifest SignedManifest) context.Context {
+	return context.WithValue(ctx, manifestKey{}, manifest)
+}
+
+// Manifest returns the signed manifest carried by ctx, if any.
+func Manifest(ctx context.Context) (SignedManifest, bool) {
+	manifest, ok := ctx.Value(manifestKey{}).(SignedManifest)
+	return manifest, ok && len(manifest.Manifest) > 0
+}
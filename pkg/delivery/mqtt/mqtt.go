@@ -0,0 +1,558 @@
+// Package mqtt implements delivery to devices that speak MQTT. Payloads are
+// published to per-device topics in numbered chunks, announced by a
+// manifest. Devices acknowledge chunks and the final result on a reply
+// topic; chunks that are reported missing, or not acknowledged in time, are
+// sent again.
+//
+// Topics are <TopicPrefix>/<device ID>/<suffix>, with the suffixes below.
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+)
+
+// Topic suffixes.
+const (
+	ManifestTopic        = "update/manifest"  // Manifest announcing a transfer
+	ChunkTopic           = "update/chunk"     // Payload chunks (see ParseChunk)
+	AckTopic             = "update/ack"       // Acks from the device
+	VersionRequestTopic  = "version/request"  // VersionRequest to the device
+	VersionResponseTopic = "version/response" // VersionResponse from the device
+)
+
+// ChunkHeaderSize is the size of the header before each chunk's data: the
+// 16-byte transfer ID followed by the chunk's big-endian uint32 sequence
+// number.
+const ChunkHeaderSize = 20
+
+// Manifest announces a transfer. It is published before the chunks.
+type Manifest struct {
+	TransferID     string                 `json:"transfer_id"` // Hex-encoded
+	Size           int64                  `json:"size"`
+	ChunkSize      int                    `json:"chunk_size"`
+	Chunks         int                    `json:"chunks"`
+	SHA256         string                 `json:"sha256,omitempty"`
+	SignedManifest []byte                 `json:"signed_manifest,omitempty"` // Signed update manifest
+	Signature      []byte                 `json:"signature,omitempty"`       // Its signature
+	Delta          *delivery.DeltaPayload `json:"delta,omitempty"`           // Set if the payload is a patch
+}
+
+// Ack is a message from a device about a transfer. A device may
+// acknowledge chunks as they arrive, ask for missing ones, and must report
+// the result once it has applied (or failed to apply) the payload.
+type Ack struct {
+	TransferID    string   `json:"transfer_id"`
+	Received      []uint32 `json:"received,omitempty"` // Chunks received
+	Missing       []uint32 `json:"missing,omitempty"`  // Chunks to send again
+	Done          bool     `json:"done,omitempty"`     // The transfer is over; see Error
+	Error         string   `json:"error,omitempty"`    // Why the update failed
+	DeltaRejected bool     `json:"delta_rejected,omitempty"`
+	Version       string   `json:"version,omitempty"` // Firmware version the device now runs
+}
+
+// VersionRequest asks a device for its firmware version.
+type VersionRequest struct {
+	RequestID string `json:"request_id"`
+}
+
+// VersionResponse answers a VersionRequest.
+type VersionResponse struct {
+	RequestID string `json:"request_id"`
+	Version   string `json:"version"`
+}
+
+// Chunk is a decoded chunk message.
+type Chunk struct {
+	TransferID string // Hex-encoded
+	Seq        uint32
+	Data       []byte
+}
+
+// ParseChunk decodes a chunk message.
+func ParseChunk(message []byte) (Chunk, error) {
+	if len(message) < ChunkHeaderSize {
+		return Chunk{}, errors.New("chunk message too short")
+	}
+	return Chunk{
+		TransferID: hex.EncodeToString(message[:16]),
+		Seq:        binary.BigEndian.Uint32(message[16:ChunkHeaderSize]),
+		Data:       message[ChunkHeaderSize:],
+	}, nil
+}
+
+// Config holds MQTT delivery configuration.
+type Config struct {
+	// Broker is the broker URL (e.g., "tcp://broker:1883", "ssl://broker:8883").
+	Broker string
+
+	// ClientID identifies the orchestrator to the broker.
+	ClientID string
+
+	// Username and Password authenticate with the broker (optional).
+	Username string
+	Password string
+
+	// TopicPrefix is the first level of every device topic.
+	TopicPrefix string
+
+	// ChunkSize is the payload bytes per chunk message.
+	ChunkSize int
+
+	// QoS for messages to devices.
+	QoS byte
+
+	// AckTimeout is how long a device that acknowledges chunks may stay
+	// silent before its unacknowledged chunks are sent again, and how long
+	// Verify waits for its version.
+	AckTimeout time.Duration
+
+	// CompletionTimeout bounds how long Push waits for the device's final
+	// ack after the last chunk is sent (or sent again).
+	CompletionTimeout time.Duration
+
+	// MaxRetransmits bounds how many times chunks are sent again before the
+	// push fails.
+	MaxRetransmits int
+
+	// ConnectTimeout bounds connecting to the broker.
+	ConnectTimeout time.Duration
+}
+
+// DefaultConfig returns sensible defaults for MQTT delivery.
+func DefaultConfig() *Config {
+	return &Config{
+		Broker:            "tcp://localhost:1883",
+		ClientID:          "update-orchestrator",
+		TopicPrefix:       "devices",
+		ChunkSize:         16 * 1024,
+		QoS:               1,
+		AckTimeout:        10 * time.Second,
+		CompletionTimeout: 10 * time.Minute,
+		MaxRetransmits:    5,
+		ConnectTimeout:    10 * time.Second,
+	}
+}
+
+// Delivery publishes payloads to devices through an MQTT broker. It
+// connects on first use, and reconnects if the connection is lost.
+type Delivery struct {
+	config *Config
+
+	mu            sync.Mutex
+	client        paho.Client
+	subscriptions map[string]paho.MessageHandler // Active, restored on reconnect
+}
+
+// New creates an MQTT delivery with default config.
+func New() *Delivery {
+	return NewWithConfig(DefaultConfig())
+}
+
+// NewWithConfig creates an MQTT delivery with custom config.
+func NewWithConfig(config *Config) *Delivery {
+	return &Delivery{
+		config:        config,
+		subscriptions: make(map[string]paho.MessageHandler),
+	}
+}
+
+// Close disconnects from the broker.
+func (d *Delivery) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client != nil {
+		d.client.Disconnect(250)
+		d.client = nil
+	}
+}
+
+// Push publishes the payload to the device in chunks and waits until the
+// device reports the result, for up to CompletionTimeout after the last
+// chunk is sent. Chunks the device reports missing are sent again, up to
+// MaxRetransmits times. So are chunks still unacknowledged after AckTimeout
+// of silence, if the device acknowledges chunks; devices that only send a
+// final ack are never resent chunks unprompted.
+func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
+	client, err := d.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, size, cleanup, err := readerAt(payload)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	manifest, err := d.newManifest(ctx, size)
+	if err != nil {
+		return err
+	}
+	transferID, _ := hex.DecodeString(manifest.TransferID)
+
+	acks := make(chan Ack, 16)
+	done := make(chan struct{})
+	defer close(done)
+	unsubscribe, err := d.subscribe(ctx, client, d.topic(device.ID, AckTopic), func(message []byte) {
+		var ack Ack
+		if json.Unmarshal(message, &ack) != nil || ack.TransferID != manifest.TransferID {
+			return
+		}
+		select {
+		case acks <- ack:
+		case <-done:
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := d.publish(ctx, client, d.topic(device.ID, ManifestTopic), body); err != nil {
+		return err
+	}
+
+	sendChunk := func(seq uint32) error {
+		offset := int64(seq) * int64(manifest.ChunkSize)
+		message := make([]byte, ChunkHeaderSize+int(min(int64(manifest.ChunkSize), size-offset)))
+		copy(message, transferID)
+		binary.BigEndian.PutUint32(message[16:ChunkHeaderSize], seq)
+		if _, err := data.ReadAt(message[ChunkHeaderSize:], offset); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read payload: %w", err)
+		}
+		return d.publish(ctx, client, d.topic(device.ID, ChunkTopic), message)
+	}
+
+	unacked := make(map[uint32]bool, manifest.Chunks)
+	for seq := range uint32(manifest.Chunks) {
+		unacked[seq] = true
+	}
+
+	retransmits := 0
+	resend := func(chunks []uint32) error {
+		if retransmits++; retransmits > d.config.MaxRetransmits {
+			return fmt.Errorf("device %s did not receive the payload after %d retransmits", device.ID, d.config.MaxRetransmits)
+		}
+		for _, seq := range chunks {
+			if int(seq) >= manifest.Chunks {
+				continue
+			}
+			if err := sendChunk(seq); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The device may acknowledge chunks or only send its final ack. Only
+	// devices seen acknowledging chunks are resent chunks on silence.
+	acksChunks := false
+	completion := time.NewTimer(d.config.CompletionTimeout)
+	defer completion.Stop()
+
+	// handleAck applies an ack, reporting whether the transfer is over
+	handleAck := func(ack Ack) (bool, error) {
+		if ack.Done {
+			return true, result(device, ack)
+		}
+		if len(ack.Received) > 0 {
+			acksChunks = true
+		}
+		for _, seq := range ack.Received {
+			delete(unacked, seq)
+		}
+		if len(ack.Missing) > 0 {
+			completion.Reset(d.config.CompletionTimeout)
+			return false, resend(ack.Missing)
+		}
+		return false, nil
+	}
+
+	report, _ := delivery.Progress(ctx)
+	for seq := range uint32(manifest.Chunks) {
+		if err := sendChunk(seq); err != nil {
+			return err
+		}
+		if report != nil {
+			report(min(int64(seq+1)*int64(manifest.ChunkSize), size))
+		}
+
+		// The device may give up early, e.g. by rejecting a delta
+		select {
+		case ack := <-acks:
+			if done, err := handleAck(ack); done || err != nil {
+				return err
+			}
+		default:
+		}
+	}
+	completion.Reset(d.config.CompletionTimeout)
+
+	silence := time.NewTimer(d.config.AckTimeout)
+	defer silence.Stop()
+	for {
+		select {
+		case ack := <-acks:
+			if done, err := handleAck(ack); done || err != nil {
+				return err
+			}
+
+		case <-silence.C:
+			if acksChunks && len(unacked) > 0 {
+				chunks := make([]uint32, 0, len(unacked))
+				for seq := range uint32(manifest.Chunks) {
+					if unacked[seq] {
+						chunks = append(chunks, seq)
+					}
+				}
+				if err := resend(chunks); err != nil {
+					return err
+				}
+				completion.Reset(d.config.CompletionTimeout)
+			}
+
+		case <-completion.C:
+			return fmt.Errorf("device %s did not report the result within %v", device.ID, d.config.CompletionTimeout)
+
+		case <-ctx.Done():
+			return fmt.Errorf("device %s did not complete the update: %w", device.ID, ctx.Err())
+		}
+		silence.Reset(d.config.AckTimeout)
+	}
+}
+
+// Verify asks the device for its firmware version and compares it with the
+// expected version carried by the context, if any (see
+// delivery.WithTargetVersion). Without one, an answer is enough.
+func (d *Delivery) Verify(ctx context.Context, device core.Device) error {
+	client, err := d.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := randomID()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.AckTimeout)
+	defer cancel()
+
+	versions := make(chan string, 1)
+	unsubscribe, err := d.subscribe(ctx, client, d.topic(device.ID, VersionResponseTopic), func(message []byte) {
+		var resp VersionResponse
+		if json.Unmarshal(message, &resp) != nil || resp.RequestID != requestID {
+			return
+		}
+		select {
+		case versions <- resp.Version:
+		default:
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+
+	body, err := json.Marshal(VersionRequest{RequestID: requestID})
+	if err != nil {
+		return fmt.Errorf("failed to encode version request: %w", err)
+	}
+	if err := d.publish(ctx, client, d.topic(device.ID, VersionRequestTopic), body); err != nil {
+		return err
+	}
+
+	var reported string
+	select {
+	case reported = <-versions:
+	case <-ctx.Done():
+		return fmt.Errorf("device %s did not report its version: %w", device.ID, ctx.Err())
+	}
+
+	if expected, ok := delivery.TargetVersion(ctx); ok {
+		return delivery.CheckVersion(device.ID, expected, reported)
+	}
+	return nil
+}
+
+// result converts a device's final ack into the push result.
+func result(device core.Device, ack Ack) error {
+	switch {
+	case ack.Error == "" && !ack.DeltaRejected:
+		return nil
+	case ack.DeltaRejected:
+		return fmt.Errorf("%w: %s", delivery.ErrDeltaRejected, ack.Error)
+	}
+	return fmt.Errorf("device %s failed to apply the update: %s", device.ID, ack.Error)
+}
+
+// newManifest describes a transfer of size bytes from the push context.
+func (d *Delivery) newManifest(ctx context.Context, size int64) (*Manifest, error) {
+	if d.config.ChunkSize <= 0 {
+		return nil, errors.New("ChunkSize must be positive")
+	}
+	transferID, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := int64(d.config.ChunkSize)
+	m := &Manifest{
+		TransferID: transferID,
+		Size:       size,
+		ChunkSize:  d.config.ChunkSize,
+		Chunks:     int((size + chunkSize - 1) / chunkSize),
+	}
+	if digest, ok := delivery.PayloadDigest(ctx); ok {
+		m.SHA256 = digest.SHA256
+	}
+	if signed, ok := delivery.Manifest(ctx); ok {
+		m.SignedManifest = signed.Manifest
+		m.Signature = signed.Signature
+	}
+	if deltaPayload, ok := delivery.Delta(ctx); ok {
+		m.Delta = &deltaPayload
+	}
+	return m, nil
+}
+
+// topic returns a device topic.
+func (d *Delivery) topic(deviceID, suffix string) string {
+	return d.config.TopicPrefix + "/" + deviceID + "/" + suffix
+}
+
+// connect returns the broker connection, connecting if needed.
+func (d *Delivery) connect(ctx context.Context) (paho.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(d.config.Broker).
+		SetClientID(d.config.ClientID).
+		SetUsername(d.config.Username).
+		SetPassword(d.config.Password).
+		SetConnectTimeout(d.config.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(d.resubscribe)
+	client := paho.NewClient(options)
+	if err := wait(ctx, client.Connect()); err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker %s: %w", d.config.Broker, err)
+	}
+
+	d.client = client
+	return client, nil
+}
+
+// resubscribe restores the active subscriptions when the connection is
+// (re-)established. The session is clean, so the broker forgets them when
+// the connection is lost.
+func (d *Delivery) resubscribe(client paho.Client) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for topic, handler := range d.subscriptions {
+		client.Subscribe(topic, d.config.QoS, handler)
+	}
+}
+
+// subscribe calls handle with each message on a topic until the returned
+// function is called.
+func (d *Delivery) subscribe(ctx context.Context, client paho.Client, topic string, handle func([]byte)) (func(), error) {
+	handler := func(_ paho.Client, message paho.Message) {
+		handle(message.Payload())
+	}
+	d.mu.Lock()
+	d.subscriptions[topic] = handler
+	d.mu.Unlock()
+
+	unsubscribe := func() {
+		d.mu.Lock()
+		delete(d.subscriptions, topic)
+		d.mu.Unlock()
+		client.Unsubscribe(topic)
+	}
+	if err := wait(ctx, client.Subscribe(topic, d.config.QoS, handler)); err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+	return unsubscribe, nil
+}
+
+// publish sends a message and waits for the broker to accept it.
+func (d *Delivery) publish(ctx context.Context, client paho.Client, topic string, message []byte) error {
+	if err := wait(ctx, client.Publish(topic, d.config.QoS, false, message)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// wait waits for an MQTT operation to complete or the context to be done.
+func wait(ctx context.Context, token paho.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// randomID returns a random 16-byte hex-encoded identifier.
+func randomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// readerAt returns the payload as an io.ReaderAt with its size, so that
+// chunks can be sent again, copying it to a temporary file if needed.
+func readerAt(payload io.Reader) (io.ReaderAt, int64, func(), error) {
+	type sizedReaderAt interface {
+		io.ReaderAt
+		Size() int64
+	}
+	if r, ok := payload.(sizedReaderAt); ok {
+		return r, r.Size(), func() {}, nil
+	}
+
+	spool, err := os.CreateTemp("", "mqtt-*.tmp")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	size, err := io.Copy(spool, payload)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("failed to spool payload: %w", err)
+	}
+	return spool, size, cleanup, nil
+}
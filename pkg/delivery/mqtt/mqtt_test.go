@@ -0,0 +1,394 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+// testDevice is a simulated MQTT device. It acknowledges each chunk,
+// reports chunks missing once the last one arrives, and installs the payload
+// when it has every chunk. With finalOnly set it sends only the final ack,
+// and with holdInstall set it waits for release before installing.
+type testDevice struct {
+	t       *testing.T
+	id      string
+	client  paho.Client
+	version string
+
+	mu            sync.Mutex
+	manifest      Manifest
+	chunks        map[uint32][]byte
+	image         []byte
+	rejectDeltas  bool
+	reportMissing bool
+	finalOnly     bool
+	holdInstall   bool
+}
+
+func newTestDevice(t *testing.T, brokerURL, id, version string) *testDevice {
+	t.Helper()
+
+	dev := &testDevice{t: t, id: id, version: version, chunks: make(map[uint32][]byte), reportMissing: true}
+
+	// Subscribe on every (re)connect, as the session is clean
+	prefix := DefaultConfig().TopicPrefix + "/" + id + "/"
+	subscriptions := map[string]byte{
+		prefix + ManifestTopic:       1,
+		prefix + ChunkTopic:          1,
+		prefix + VersionRequestTopic: 1,
+	}
+	subscribed := make(chan error, 1)
+	options := paho.NewClientOptions().AddBroker(brokerURL).SetClientID(id)
+	options.SetOnConnectHandler(func(client paho.Client) {
+		token := client.SubscribeMultiple(subscriptions, func(_ paho.Client, message paho.Message) {
+			switch strings.TrimPrefix(message.Topic(), prefix) {
+			case ManifestTopic:
+				dev.onManifest(message.Payload())
+			case ChunkTopic:
+				dev.onChunk(message.Payload())
+			case VersionRequestTopic:
+				dev.onVersionRequest(message.Payload())
+			}
+		})
+		token.Wait()
+		select {
+		case subscribed <- token.Error():
+		default:
+		}
+	})
+
+	dev.client = paho.NewClient(options)
+	if token := dev.client.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatalf("Device failed to connect: %v", token.Error())
+	}
+	t.Cleanup(func() { dev.client.Disconnect(0) })
+	if err := <-subscribed; err != nil {
+		t.Fatalf("Device failed to subscribe: %v", err)
+	}
+	return dev
+}
+
+func (dev *testDevice) onManifest(message []byte) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	json.Unmarshal(message, &dev.manifest)
+	dev.chunks = make(map[uint32][]byte)
+	if dev.manifest.Delta != nil && dev.rejectDeltas {
+		dev.send(Ack{TransferID: dev.manifest.TransferID, Done: true, DeltaRejected: true, Error: "patches not supported"})
+	}
+}
+
+func (dev *testDevice) onChunk(message []byte) {
+	chunk, err := ParseChunk(message)
+	if err != nil {
+		dev.t.Errorf("Invalid chunk: %v", err)
+		return
+	}
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if chunk.TransferID != dev.manifest.TransferID {
+		return
+	}
+	dev.chunks[chunk.Seq] = append([]byte(nil), chunk.Data...)
+	if !dev.finalOnly {
+		dev.send(Ack{TransferID: chunk.TransferID, Received: []uint32{chunk.Seq}})
+	}
+
+	var missing []uint32
+	for seq := range uint32(dev.manifest.Chunks) {
+		if _, ok := dev.chunks[seq]; !ok {
+			missing = append(missing, seq)
+		}
+	}
+	switch {
+	case len(missing) == 0:
+		if !dev.holdInstall {
+			dev.install()
+		}
+	case dev.reportMissing && !dev.finalOnly && int(chunk.Seq) == dev.manifest.Chunks-1:
+		dev.send(Ack{TransferID: chunk.TransferID, Missing: missing})
+	}
+}
+
+// install assembles and checks the payload. Must be called with dev.mu held.
+func (dev *testDevice) install() {
+	var image []byte
+	for seq := range uint32(dev.manifest.Chunks) {
+		image = append(image, dev.chunks[seq]...)
+	}
+
+	ack := Ack{TransferID: dev.manifest.TransferID, Done: true}
+	sum := sha256.Sum256(image)
+	if dev.manifest.SHA256 != "" && hex.EncodeToString(sum[:]) != dev.manifest.SHA256 {
+		ack.Error = "checksum mismatch"
+	} else {
+		dev.image = image
+		dev.version = "2.0.0"
+		ack.Version = dev.version
+	}
+	dev.send(ack)
+}
+
+// release installs a held payload.
+func (dev *testDevice) release() {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	dev.install()
+}
+
+// received returns the number of chunks received of the current transfer.
+func (dev *testDevice) received() int {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return len(dev.chunks)
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+}
+
+func (dev *testDevice) onVersionRequest(message []byte) {
+	var req VersionRequest
+	json.Unmarshal(message, &req)
+
+	dev.mu.Lock()
+	resp := VersionResponse{RequestID: req.RequestID, Version: dev.version}
+	dev.mu.Unlock()
+
+	body, _ := json.Marshal(resp)
+	dev.client.Publish(DefaultConfig().TopicPrefix+"/"+dev.id+"/"+VersionResponseTopic, 1, false, body)
+}
+
+func (dev *testDevice) send(ack Ack) {
+	body, _ := json.Marshal(ack)
+	dev.client.Publish(DefaultConfig().TopicPrefix+"/"+dev.id+"/"+AckTopic, 1, false, body)
+}
+
+func (dev *testDevice) getImage() []byte {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.image
+}
+
+// setup starts a broker and returns it with a delivery connected to it.
+func setup(t *testing.T) (*mocks.MQTTBroker, *Delivery) {
+	t.Helper()
+
+	broker, err := mocks.NewMQTTBroker()
+	if err != nil {
+		t.Fatalf("Failed to start broker: %v", err)
+	}
+	t.Cleanup(broker.Close)
+
+	config := DefaultConfig()
+	config.Broker = broker.URL()
+	config.ChunkSize = 1024
+	config.AckTimeout = 200 * time.Millisecond
+	d := NewWithConfig(config)
+	t.Cleanup(d.Close)
+	return broker, d
+}
+
+// firmware returns a payload and a context carrying its digest.
+func firmware() ([]byte, context.Context) {
+	payload := bytes.Repeat([]byte("firmware"), 1200) // 9600 bytes, 10 chunks
+	sum := sha256.Sum256(payload)
+	digest := delivery.Digest{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))}
+	return payload, delivery.WithPayloadDigest(context.Background(), digest)
+}
+
+func TestDelivery_PushAndVerify(t *testing.T) {
+	broker, d := setup(t)
+	dev := newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+	payload, ctx := firmware()
+
+	var sent atomic.Int64
+	ctx = delivery.WithProgress(ctx, func(n int64) { sent.Store(n) })
+
+	device := core.Device{ID: "sensor-1"}
+	if err := d.Push(ctx, device, bytes.NewReader(payload)); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if !bytes.Equal(dev.getImage(), payload) {
+		t.Errorf("Device installed %d bytes, expected the %d byte payload", len(dev.getImage()), len(payload))
+	}
+	if got := sent.Load(); got != int64(len(payload)) {
+		t.Errorf("Expected progress of %d bytes, got %d", len(payload), got)
+	}
+
+	if err := d.Verify(delivery.WithTargetVersion(context.Background(), "2.0.0"), device); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	var mismatch *delivery.VersionMismatchError
+	if err := d.Verify(delivery.WithTargetVersion(context.Background(), "3.0.0"), device); !errors.As(err, &mismatch) {
+		t.Errorf("Expected a version mismatch, got %v", err)
+	}
+}
+
+func TestDelivery_RetransmitsLostChunks(t *testing.T) {
+	for _, reportMissing := range []bool{true, false} {
+		broker, d := setup(t)
+		dev := newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+		dev.reportMissing = reportMissing
+		payload, ctx := firmware()
+
+		// Lose the first transmission of chunks 3 and 7
+		var dropped sync.Map
+		var chunksSent atomic.Int64
+		broker.SetDropFunc(func(topic string, message []byte) bool {
+			if !strings.HasSuffix(topic, ChunkTopic) {
+				return false
+			}
+			chunksSent.Add(1)
+			chunk, _ := ParseChunk(message)
+			if chunk.Seq != 3 && chunk.Seq != 7 {
+				return false
+			}
+			_, seen := dropped.LoadOrStore(chunk.Seq, true)
+			return !seen
+		})
+
+		if err := d.Push(ctx, core.Device{ID: "sensor-1"}, bytes.NewReader(payload)); err != nil {
+			t.Fatalf("Push failed (reportMissing=%v): %v", reportMissing, err)
+		}
+		if !bytes.Equal(dev.getImage(), payload) {
+			t.Errorf("Device did not install the payload (reportMissing=%v)", reportMissing)
+		}
+		if got := chunksSent.Load(); got != 12 {
+			t.Errorf("Expected 10 chunks and 2 retransmits, got %d chunk messages (reportMissing=%v)", got, reportMissing)
+		}
+	}
+}
+
+func TestDelivery_FailsAfterMaxRetransmits(t *testing.T) {
+	broker, d := setup(t)
+	newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+	payload, ctx := firmware()
+
+	// Chunk 0 never arrives
+	broker.SetDropFunc(func(topic string, message []byte) bool {
+		chunk, err := ParseChunk(message)
+		return strings.HasSuffix(topic, ChunkTopic) && err == nil && chunk.Seq == 0
+	})
+	d.config.MaxRetransmits = 2
+
+	err := d.Push(ctx, core.Device{ID: "sensor-1"}, bytes.NewReader(payload))
+	if err == nil || !strings.Contains(err.Error(), "retransmits") {
+		t.Errorf("Expected the push to fail after retransmits, got %v", err)
+	}
+}
+
+func TestDelivery_WaitsForFinalOnlyAck(t *testing.T) {
+	broker, d := setup(t)
+	dev := newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+	dev.finalOnly = true
+	dev.holdInstall = true
+	d.config.MaxRetransmits = 1
+	payload, ctx := firmware()
+
+	var chunksSent atomic.Int64
+	broker.SetDropFunc(func(topic string, _ []byte) bool {
+		if strings.HasSuffix(topic, ChunkTopic) {
+			chunksSent.Add(1)
+		}
+		return false
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- d.Push(ctx, core.Device{ID: "sensor-1"}, bytes.NewReader(payload)) }()
+
+	// The device stays silent for several AckTimeouts while installing
+	waitFor(t, "the chunks", func() bool { return dev.received() == 10 })
+	time.Sleep(4 * d.config.AckTimeout)
+	dev.release()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if got := chunksSent.Load(); got != 10 {
+		t.Errorf("Expected no retransmits to a final-ack-only device, got %d chunk messages", got)
+	}
+}
+
+func TestDelivery_FailsAfterCompletionTimeout(t *testing.T) {
+	broker, d := setup(t)
+	dev := newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+	dev.holdInstall = true
+	d.config.CompletionTimeout = 500 * time.Millisecond
+	payload, ctx := firmware()
+
+	err := d.Push(ctx, core.Device{ID: "sensor-1"}, bytes.NewReader(payload))
+	if err == nil || !strings.Contains(err.Error(), "did not report the result") {
+		t.Errorf("Expected the push to fail after CompletionTimeout, got %v", err)
+	}
+}
+
+func TestDelivery_ResubscribesAfterReconnect(t *testing.T) {
+	broker, d := setup(t)
+	dev := newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+	dev.holdInstall = true
+	payload, ctx := firmware()
+
+	done := make(chan error, 1)
+	go func() { done <- d.Push(ctx, core.Device{ID: "sensor-1"}, bytes.NewReader(payload)) }()
+	waitFor(t, "the chunks", func() bool { return dev.received() == 10 })
+
+	// The broker forgets subscriptions with the connection
+	ackTopic := d.topic("sensor-1", AckTopic)
+	broker.DisconnectAll()
+	waitFor(t, "the ack subscription to be restored", func() bool { return broker.Subscribed(ackTopic) })
+	dev.release()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if !bytes.Equal(dev.getImage(), payload) {
+		t.Errorf("Device did not install the payload")
+	}
+}
+
+func TestDelivery_DeviceRejectsDelta(t *testing.T) {
+	broker, d := setup(t)
+	dev := newTestDevice(t, broker.URL(), "sensor-1", "1.0.0")
+	dev.rejectDeltas = true
+	payload, ctx := firmware()
+
+	ctx = delivery.WithDelta(ctx, delivery.DeltaPayload{BaseVersion: "1.0.0", TargetVersion: "2.0.0"})
+	if err := d.Push(ctx, core.Device{ID: "sensor-1"}, bytes.NewReader(payload)); !errors.Is(err, delivery.ErrDeltaRejected) {
+		t.Errorf("Expected ErrDeltaRejected, got %v", err)
+	}
+}
+
+func TestDelivery_VerifyTimesOutWithoutDevice(t *testing.T) {
+	_, d := setup(t)
+
+	err := d.Verify(context.Background(), core.Device{ID: "sensor-1"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Verify to time out, got %v", err)
+	}
+}
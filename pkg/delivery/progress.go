@@ -0,0 +1,101 @@
+package delivery
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressInterval is how often a progress reader reports bytes sent.
+const ProgressInterval = 250 * time.Millisecond
+
+// ProgressFunc receives the number of payload bytes sent to a device so far.
+// When a push is retried the count restarts at the payload offset the retry
+// starts from (0, or further on if the upload is resumed), which is reported
+// right away.
+type ProgressFunc func(sent int64)
+
+// progressKey is the context key for the progress function.
+type progressKey struct{}
+
+// WithProgress returns a context carrying a function that Push
+// implementations call as payload bytes are sent (see NewProgressReader).
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// Progress returns the progress function carried by ctx, if any.
+func Progress(ctx context.Context) (ProgressFunc, bool) {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn, ok && fn != nil
+}
+
+// NewProgressReader wraps a payload so that the bytes read from it are
+// reported to the progress function carried by ctx, at most once per
+// ProgressInterval and once more at EOF. Seekable payloads stay seekable.
+// The payload is returned unchanged if ctx carries no progress function.
+func NewProgressReader(ctx context.Context, payload io.Reader) io.Reader {
+	fn, ok := Progress(ctx)
+	if !ok {
+		return payload
+	}
+
+	r := &progressReader{r: payload, report: fn}
+	if seeker, ok := payload.(io.Seeker); ok {
+		return &progressReadSeeker{progressReader: r, seeker: seeker}
+	}
+	return r
+}
+
+// progressReader counts the bytes read from a payload.
+type progressReader struct {
+	r      io.Reader
+	report ProgressFunc
+
+	mu       sync.Mutex
+	sent     int64
+	reported time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+
+	p.mu.Lock()
+	p.sent += int64(n)
+	sent := p.sent
+	due := err == io.EOF || time.Since(p.reported) >= ProgressInterval
+	if due {
+		p.reported = time.Now()
+	}
+	p.mu.Unlock()
+
+	if due && (n > 0 || err == io.EOF) {
+		p.report(sent)
+	}
+	return n, err
+}
+
+// progressReadSeeker is a progressReader over a seekable payload. Seeking
+// (e.g., to retry a push) moves the count to the new offset and reports it.
+type progressReadSeeker struct {
+	*progressReader
+	seeker io.Seeker
+}
+
+func (p *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.seeker.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+
+	p.mu.Lock()
+	moved := p.sent != pos
+	p.sent = pos
+	p.mu.Unlock()
+
+	if moved {
+		p.report(pos)
+	}
+	return pos, nil
+}
@@ -0,0 +1,473 @@
+// Package pull implements delivery to devices the orchestrator cannot reach,
+// such as devices behind NAT. Push publishes an assignment; the device polls
+// for it, downloads the payload from the orchestrator (with Range support, so
+// interrupted downloads can resume) and acknowledges the result, which
+// completes the push.
+package pull
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+)
+
+// Device API paths, served by Delivery.ServeHTTP.
+const (
+	AssignmentPath = "/api/pull/assignment" // GET ?device=<id>[&wait=<duration>]
+	PayloadPath    = "/api/pull/payload/"   // GET /api/pull/payload/<assignment id>
+	AckPath        = "/api/pull/ack"        // POST an Ack
+)
+
+// Assignment tells a device to download and apply a payload.
+type Assignment struct {
+	ID                string                 `json:"id"`
+	PayloadURL        string                 `json:"payload_url"` // Path on the orchestrator to download from
+	SHA256            string                 `json:"sha256,omitempty"`
+	Size              int64                  `json:"size"`
+	Manifest          []byte                 `json:"manifest,omitempty"`
+	ManifestSignature []byte                 `json:"manifest_signature,omitempty"`
+	Delta             *delivery.DeltaPayload `json:"delta,omitempty"` // Set if the payload is a patch
+}
+
+// Ack reports the result of an assignment.
+type Ack struct {
+	AssignmentID  string `json:"assignment_id"`
+	Success       bool   `json:"success"`
+	Error         string `json:"error,omitempty"`          // Why the update failed
+	DeltaRejected bool   `json:"delta_rejected,omitempty"` // The device cannot apply the patch
+	Version       string `json:"version,omitempty"`        // Firmware version the device now runs
+}
+
+// Config holds pull delivery configuration.
+type Config struct {
+	// AckTimeout bounds how long Push waits for the device to acknowledge,
+	// in addition to the push context's deadline (0 means no limit).
+	AckTimeout time.Duration
+
+	// MaxPollWait caps how long an assignment poll may wait for an
+	// assignment to be published (the wait query parameter).
+	MaxPollWait time.Duration
+
+	// SpoolDir holds copies of payloads that cannot be read at an offset
+	// (default: os.TempDir()).
+	SpoolDir string
+
+	// Token, if set, must be sent by devices as "Authorization: Bearer <Token>".
+	Token string
+}
+
+// DefaultConfig returns sensible defaults for pull delivery.
+func DefaultConfig() *Config {
+	return &Config{
+		AckTimeout:  1 * time.Hour,
+		MaxPollWait: 60 * time.Second,
+	}
+}
+
+// Delivery publishes payloads for devices to pull. It implements
+// delivery.Delivery for the orchestrator and http.Handler for devices; mount
+// it on the paths above (e.g., web.Config.PullDelivery).
+type Delivery struct {
+	config *Config
+
+	mu          sync.Mutex
+	assignments map[string]*assignment   // By device ID
+	byID        map[string]*assignment   // By assignment ID
+	published   map[string]chan struct{} // Closed when a device's assignment is published
+	versions    map[string]string        // Firmware version each device last reported
+}
+
+// assignment is a published payload waiting for its device.
+type assignment struct {
+	Assignment
+	deviceID string
+	ctx      context.Context // Push context, carrying the progress function
+	data     io.ReaderAt
+
+	acked     chan Ack
+	withdrawn chan struct{} // Closed when the push returns or is superseded
+	downloads sync.WaitGroup
+}
+
+// New creates a pull delivery with default config.
+func New() *Delivery {
+	return NewWithConfig(DefaultConfig())
+}
+
+// NewWithConfig creates a pull delivery with custom config.
+func NewWithConfig(config *Config) *Delivery {
+	return &Delivery{
+		config:      config,
+		assignments: make(map[string]*assignment),
+		byID:        make(map[string]*assignment),
+		published:   make(map[string]chan struct{}),
+		versions:    make(map[string]string),
+	}
+}
+
+// errSuperseded indicates a newer push to the same device replaced this one.
+var errSuperseded = errors.New("assignment superseded by a newer push")
+
+// Push publishes the payload for the device and waits until the device
+// acknowledges it, the context is done or AckTimeout elapses. Bytes the
+// device downloads are reported as progress.
+func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
+	data, size, cleanup, err := d.readerAt(payload)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if d.config.AckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.AckTimeout)
+		defer cancel()
+	}
+
+	a, err := newAssignment(ctx, device.ID, data, size)
+	if err != nil {
+		return err
+	}
+	d.publish(a)
+	defer d.withdraw(a)
+
+	select {
+	case ack := <-a.acked:
+		switch {
+		case ack.Success:
+			return nil
+		case ack.DeltaRejected:
+			return fmt.Errorf("%w: %s", delivery.ErrDeltaRejected, ack.Error)
+		}
+		return fmt.Errorf("device %s failed to apply the update: %s", device.ID, ack.Error)
+	case <-a.withdrawn:
+		return errSuperseded
+	case <-ctx.Done():
+		return fmt.Errorf("device %s did not acknowledge the update: %w", device.ID, ctx.Err())
+	}
+}
+
+// Verify checks the firmware version the device reported in its last
+// acknowledgement against the expected version carried by the context, if
+// any (see delivery.WithTargetVersion).
+func (d *Delivery) Verify(ctx context.Context, device core.Device) error {
+	d.mu.Lock()
+	reported, ok := d.versions[device.ID]
+	d.mu.Unlock()
+
+	expected, hasExpected := delivery.TargetVersion(ctx)
+	if !hasExpected {
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("device %s has not reported its firmware version", device.ID)
+	}
+	return delivery.CheckVersion(device.ID, expected, reported)
+}
+
+// newAssignment describes a payload for its device from the push context.
+func newAssignment(ctx context.Context, deviceID string, data io.ReaderAt, size int64) (*assignment, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate assignment ID: %w", err)
+	}
+
+	a := &assignment{
+		Assignment: Assignment{
+			ID:   hex.EncodeToString(id),
+			Size: size,
+		},
+		deviceID:  deviceID,
+		ctx:       ctx,
+		data:      data,
+		acked:     make(chan Ack, 1),
+		withdrawn: make(chan struct{}),
+	}
+	a.PayloadURL = PayloadPath + a.ID
+	if digest, ok := delivery.PayloadDigest(ctx); ok {
+		a.SHA256 = digest.SHA256
+	}
+	if manifest, ok := delivery.Manifest(ctx); ok {
+		a.Manifest = manifest.Manifest
+		a.ManifestSignature = manifest.Signature
+	}
+	if deltaPayload, ok := delivery.Delta(ctx); ok {
+		a.Delta = &deltaPayload
+	}
+	return a, nil
+}
+
+// publish makes an assignment visible to its device, superseding any
+// earlier one, and wakes pending polls.
+func (d *Delivery) publish(a *assignment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if previous, ok := d.assignments[a.deviceID]; ok {
+		d.removeLocked(previous)
+	}
+	d.assignments[a.deviceID] = a
+	d.byID[a.ID] = a
+	if ch, ok := d.published[a.deviceID]; ok {
+		close(ch)
+		delete(d.published, a.deviceID)
+	}
+}
+
+// withdraw removes an assignment once its push returns, and waits for
+// in-flight downloads of it to stop.
+func (d *Delivery) withdraw(a *assignment) {
+	d.mu.Lock()
+	if d.byID[a.ID] == a {
+		d.removeLocked(a)
+	}
+	d.mu.Unlock()
+
+	a.downloads.Wait()
+}
+
+// removeLocked unpublishes an assignment. Must be called with d.mu held.
+func (d *Delivery) removeLocked(a *assignment) {
+	if d.assignments[a.deviceID] == a {
+		delete(d.assignments, a.deviceID)
+	}
+	delete(d.byID, a.ID)
+	close(a.withdrawn)
+}
+
+// readerAt returns the payload as an io.ReaderAt with its size, copying it
+// to a spool file if it cannot be read at an offset.
+func (d *Delivery) readerAt(payload io.Reader) (io.ReaderAt, int64, func(), error) {
+	type sizedReaderAt interface {
+		io.ReaderAt
+		Size() int64
+	}
+	if r, ok := payload.(sizedReaderAt); ok {
+		return r, r.Size(), func() {}, nil
+	}
+
+	spool, err := os.CreateTemp(d.config.SpoolDir, "pull-*.tmp")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	size, err := io.Copy(spool, payload)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("failed to spool payload: %w", err)
+	}
+	return spool, size, cleanup, nil
+}
+
+// ServeHTTP implements the device API.
+func (d *Delivery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if d.config.Token != "" && r.Header.Get("Authorization") != "Bearer "+d.config.Token {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == AssignmentPath && r.Method == http.MethodGet:
+		d.handleAssignment(w, r)
+	case strings.HasPrefix(r.URL.Path, PayloadPath) && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		d.handlePayload(w, r)
+	case r.URL.Path == AckPath && r.Method == http.MethodPost:
+		d.handleAck(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleAssignment returns the device's assignment, or 204 if it has none.
+// With a wait parameter, the poll waits up to that long for one.
+func (d *Delivery) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.URL.Query().Get("device")
+	if deviceID == "" {
+		http.Error(w, "device is required", http.StatusBadRequest)
+		return
+	}
+
+	var wait time.Duration
+	if param := r.URL.Query().Get("wait"); param != "" {
+		var err error
+		if wait, err = time.ParseDuration(param); err != nil {
+			http.Error(w, "Invalid wait: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		wait = min(wait, d.config.MaxPollWait)
+	}
+
+	d.mu.Lock()
+	a, ok := d.assignments[deviceID]
+	if !ok && wait > 0 {
+		ch, exists := d.published[deviceID]
+		if !exists {
+			ch = make(chan struct{})
+			d.published[deviceID] = ch
+		}
+		d.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ch:
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+
+		d.mu.Lock()
+		a, ok = d.assignments[deviceID]
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(a.Assignment)
+}
+
+// handlePayload serves an assignment's payload, or the byte range requested.
+func (d *Delivery) handlePayload(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, PayloadPath)
+
+	d.mu.Lock()
+	a, ok := d.byID[id]
+	if ok {
+		a.downloads.Add(1)
+	}
+	d.mu.Unlock()
+	if !ok {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+	defer a.downloads.Done()
+
+	start, end, partial, err := parseRange(r.Header.Get("Range"), a.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", a.Size))
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+	if a.SHA256 != "" {
+		w.Header().Set("X-Payload-SHA256", a.SHA256)
+	}
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, a.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// Progress counts from the range start; reading to EOF reports the end
+	payload := delivery.NewProgressReader(a.ctx, io.NewSectionReader(a.data, 0, end))
+	if _, err := payload.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+		return
+	}
+	io.Copy(w, &withdrawableReader{r: payload, withdrawn: a.withdrawn})
+}
+
+// handleAck completes the push of the acknowledged assignment.
+func (d *Delivery) handleAck(w http.ResponseWriter, r *http.Request) {
+	var ack Ack
+	if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
+		http.Error(w, "Invalid ack: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d.mu.Lock()
+	a, ok := d.byID[ack.AssignmentID]
+	if ok && ack.Version != "" {
+		d.versions[a.deviceID] = ack.Version
+	}
+	d.mu.Unlock()
+	if !ok {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	select {
+	case a.acked <- ack:
+	default: // Already acknowledged
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// parseRange parses a single "bytes=" range over a payload of the given
+// size, returning [start, end). Without a range, or with several, the whole
+// payload is returned with partial unset.
+func parseRange(header string, size int64) (start, end int64, partial bool, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size, false, nil
+	}
+
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false, fmt.Errorf("invalid range %q", header)
+	}
+	switch {
+	case first == "":
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid range %q", header)
+		}
+		return max(size-n, 0), size, true, nil
+	default:
+		start, err = strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 || start >= size {
+			return 0, 0, false, fmt.Errorf("unsatisfiable range %q", header)
+		}
+		end = size
+		if last != "" {
+			lastByte, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || lastByte < start {
+				return 0, 0, false, fmt.Errorf("invalid range %q", header)
+			}
+			end = min(lastByte+1, size)
+		}
+		return start, end, true, nil
+	}
+}
+
+// withdrawableReader stops reading once its assignment is withdrawn, so that
+// the push can return while a download is still in flight.
+type withdrawableReader struct {
+	r         io.Reader
+	withdrawn chan struct{}
+}
+
+func (r *withdrawableReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.withdrawn:
+		return 0, errors.New("assignment withdrawn")
+	default:
+		return r.r.Read(p)
+	}
+}
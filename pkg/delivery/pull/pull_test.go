@@ -0,0 +1,207 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+)
+
+// poll waits for the device's assignment.
+func poll(t *testing.T, url, deviceID string) Assignment {
+	t.Helper()
+
+	resp, err := http.Get(url + AssignmentPath + "?device=" + deviceID + "&wait=5s")
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected an assignment, got status %d", resp.StatusCode)
+	}
+
+	var a Assignment
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		t.Fatalf("Invalid assignment: %v", err)
+	}
+	return a
+}
+
+// download fetches the assignment's payload from offset on.
+func download(t *testing.T, url string, a Assignment, offset int64) []byte {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url+a.PayloadURL, nil)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	return data
+}
+
+// ack reports the result of an assignment.
+func ack(t *testing.T, url string, result Ack) {
+	t.Helper()
+
+	body, _ := json.Marshal(result)
+	resp, err := http.Post(url+AckPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected ack to be accepted, got status %d", resp.StatusCode)
+	}
+}
+
+func TestDelivery_DevicePullsAndAcknowledges(t *testing.T) {
+	d := New()
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	payload := bytes.Repeat([]byte("firmware"), 4096)
+	sum := sha256.Sum256(payload)
+	digest := delivery.Digest{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))}
+
+	var sent atomic.Int64
+	ctx := delivery.WithPayloadDigest(context.Background(), digest)
+	ctx = delivery.WithProgress(ctx, func(n int64) { sent.Store(n) })
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Push(ctx, core.Device{ID: "till-1"}, bytes.NewReader(payload))
+	}()
+
+	// The device downloads the payload in two parts, as if resuming
+	a := poll(t, server.URL, "till-1")
+	if a.SHA256 != digest.SHA256 || a.Size != digest.Size {
+		t.Errorf("Expected the payload digest in the assignment, got %s (%d bytes)", a.SHA256, a.Size)
+	}
+	received := download(t, server.URL, a, 0)[:1000]
+	received = append(received, download(t, server.URL, a, 1000)...)
+	if !bytes.Equal(received, payload) {
+		t.Fatalf("Downloaded payload differs: got %d bytes", len(received))
+	}
+	ack(t, server.URL, Ack{AssignmentID: a.ID, Success: true, Version: "2.0.0"})
+
+	if err := <-done; err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if got := sent.Load(); got != int64(len(payload)) {
+		t.Errorf("Expected progress of %d bytes, got %d", len(payload), got)
+	}
+
+	device := core.Device{ID: "till-1"}
+	if err := d.Verify(delivery.WithTargetVersion(context.Background(), "2.0.0"), device); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	var mismatch *delivery.VersionMismatchError
+	if err := d.Verify(delivery.WithTargetVersion(context.Background(), "3.0.0"), device); !errors.As(err, &mismatch) {
+		t.Errorf("Expected a version mismatch, got %v", err)
+	}
+}
+
+func TestDelivery_PushHonoursDeadline(t *testing.T) {
+	d := New()
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := d.Push(ctx, core.Device{ID: "till-1"}, bytes.NewReader([]byte("firmware")))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to end the push, got %v", err)
+	}
+
+	// The assignment is withdrawn with the push
+	resp, err := http.Get(server.URL + AssignmentPath + "?device=till-1")
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected no assignment after the deadline, got status %d", resp.StatusCode)
+	}
+}
+
+func TestDelivery_DeviceRejectsDelta(t *testing.T) {
+	d := New()
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	ctx := delivery.WithDelta(context.Background(), delivery.DeltaPayload{BaseVersion: "1.0.0", TargetVersion: "2.0.0"})
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Push(ctx, core.Device{ID: "till-1"}, &onlyReader{bytes.NewReader([]byte("patch"))})
+	}()
+
+	a := poll(t, server.URL, "till-1")
+	if a.Delta == nil || a.Delta.BaseVersion != "1.0.0" {
+		t.Errorf("Expected the delta in the assignment, got %+v", a.Delta)
+	}
+	if got := download(t, server.URL, a, 0); string(got) != "patch" {
+		t.Errorf("Expected the spooled payload, got %q", got)
+	}
+	ack(t, server.URL, Ack{AssignmentID: a.ID, DeltaRejected: true, Error: "unknown base"})
+
+	if err := <-done; !errors.Is(err, delivery.ErrDeltaRejected) {
+		t.Errorf("Expected ErrDeltaRejected, got %v", err)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		start, end int64
+		partial    bool
+		wantErr    bool
+	}{
+		{"", 0, 100, false, false},
+		{"bytes=10-", 10, 100, true, false},
+		{"bytes=10-19", 10, 20, true, false},
+		{"bytes=90-200", 90, 100, true, false},
+		{"bytes=-30", 70, 100, true, false},
+		{"bytes=0-1,5-6", 0, 100, false, false},
+		{"bytes=100-", 0, 0, false, true},
+		{"bytes=20-10", 0, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		start, end, partial, err := parseRange(tt.header, 100)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error %v", tt.header, err)
+			continue
+		}
+		if !tt.wantErr && (start != tt.start || end != tt.end || partial != tt.partial) {
+			t.Errorf("%q: expected [%d, %d) partial=%v, got [%d, %d) partial=%v", tt.header, tt.start, tt.end, tt.partial, start, end, partial)
+		}
+	}
+}
+
+// onlyReader hides every method but Read, so the payload must be spooled.
+type onlyReader struct {
+	r io.Reader
+}
+
+func (r *onlyReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
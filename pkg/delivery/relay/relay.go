@@ -0,0 +1,278 @@
+// Package relay implements delivery through store-local relays (see
+// core.Relay and package pkg/relay). Devices in a location with a relay are
+// pushed by the relay: the payload is uploaded to the relay once per
+// location, and each device push then only crosses the location's network.
+// Devices without a relay are pushed directly.
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/pkg/registry"
+	relayapi "github.com/dovaclean/go-update-orchestrator/pkg/relay"
+)
+
+// Config holds relay delivery configuration.
+type Config struct {
+	// Timeout bounds each request to a relay, including a whole push
+	// (0 means no limit beyond the push context).
+	Timeout time.Duration
+
+	// Headers to include in all relay requests (e.g., Authorization)
+	Headers map[string]string
+}
+
+// DefaultConfig returns sensible defaults for relay delivery.
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout: 30 * time.Minute,
+		Headers: make(map[string]string),
+	}
+}
+
+// Delivery pushes payloads through the relay assigned to each device's
+// location, and directly to devices without one. Verify always goes
+// directly to the device.
+type Delivery struct {
+	config *Config
+	relays registry.RelayRegistry
+	direct delivery.Delivery
+	client *http.Client
+
+	mu      sync.Mutex
+	uploads map[uploadKey]*upload
+}
+
+// uploadKey identifies a payload upload to a relay.
+type uploadKey struct {
+	address string
+	sha256  string
+}
+
+// upload is a payload upload to a relay, shared by the pushes waiting on it.
+// done is closed once err is set.
+type upload struct {
+	done chan struct{}
+	err  error
+}
+
+// New creates a relay delivery with default config. Devices are pushed
+// directly with direct when their location has no relay.
+func New(relays registry.RelayRegistry, direct delivery.Delivery) *Delivery {
+	return NewWithConfig(DefaultConfig(), relays, direct)
+}
+
+// NewWithConfig creates a relay delivery with custom config.
+func NewWithConfig(config *Config, relays registry.RelayRegistry, direct delivery.Delivery) *Delivery {
+	return &Delivery{
+		config:  config,
+		relays:  relays,
+		direct:  direct,
+		client:  &http.Client{Timeout: config.Timeout},
+		uploads: make(map[uploadKey]*upload),
+	}
+}
+
+// Push delivers the payload to a device through its location's relay. The
+// payload digest must be in the context (see delivery.WithPayloadDigest);
+// without one, or without a relay, the device is pushed directly. The
+// upload to the relay is not reported as progress: only the bytes the
+// relay sends to the device are.
+func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
+	digest, hasDigest := delivery.PayloadDigest(ctx)
+	if device.Location == "" || !hasDigest {
+		return d.direct.Push(ctx, device, payload)
+	}
+
+	relay, err := d.relays.GetRelay(ctx, device.Location)
+	if errors.Is(err, core.ErrRelayNotFound) {
+		return d.direct.Push(ctx, device, payload)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up relay for %s: %w", device.Location, err)
+	}
+
+	if err := d.ensureUploaded(ctx, relay, digest, payload); err != nil {
+		return err
+	}
+
+	err = d.push(ctx, relay, device, digest)
+	if errors.Is(err, errNotStored) {
+		// The relay evicted the payload; upload it again
+		d.forget(relay, digest)
+		if err := d.ensureUploaded(ctx, relay, digest, payload); err != nil {
+			return err
+		}
+		err = d.push(ctx, relay, device, digest)
+	}
+	return err
+}
+
+// Verify checks the device directly.
+func (d *Delivery) Verify(ctx context.Context, device core.Device) error {
+	return d.direct.Verify(ctx, device)
+}
+
+// errNotStored indicates the relay does not hold the payload to push.
+var errNotStored = errors.New("payload not stored on relay")
+
+// ensureUploaded makes sure the relay holds the payload, uploading it if
+// needed. Concurrent pushes to the same location share one upload; if it
+// fails, the next push tries again.
+func (d *Delivery) ensureUploaded(ctx context.Context, relay *core.Relay, digest delivery.Digest, payload io.Reader) error {
+	key := uploadKey{relay.Address, digest.SHA256}
+
+	for {
+		d.mu.Lock()
+		u, exists := d.uploads[key]
+		if !exists {
+			u = &upload{done: make(chan struct{})}
+			d.uploads[key] = u
+		}
+		d.mu.Unlock()
+
+		if !exists {
+			u.err = d.upload(ctx, relay, digest, payload)
+			if u.err != nil {
+				d.forget(relay, digest)
+			}
+			close(u.done)
+			return u.err
+		}
+
+		select {
+		case <-u.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if u.err == nil {
+			return nil
+		}
+		// The push that uploaded failed (e.g., it was cancelled); try ourselves
+	}
+}
+
+// forget drops the record of an upload so that the next push uploads again.
+func (d *Delivery) forget(relay *core.Relay, digest delivery.Digest) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.uploads, uploadKey{relay.Address, digest.SHA256})
+}
+
+// upload sends the payload to the relay unless it already holds it.
+func (d *Delivery) upload(ctx context.Context, relay *core.Relay, digest delivery.Digest, payload io.Reader) error {
+	url := relay.Address + relayapi.PayloadsPath + digest.SHA256
+
+	resp, err := d.do(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reach relay %s: %w", relay.Address, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	// Upload from the start of this push's reader
+	if seeker, ok := payload.(io.Seeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind payload: %w", err)
+		}
+	}
+	resp, err = d.do(ctx, http.MethodPut, url, payload)
+	if err != nil {
+		return fmt.Errorf("failed to upload payload to relay %s: %w", relay.Address, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("payload upload to relay %s failed with status %d: %s", relay.Address, resp.StatusCode, body)
+	}
+	return nil
+}
+
+// push asks the relay to push the payload to the device, forwarding the
+// bytes it reports as progress.
+func (d *Delivery) push(ctx context.Context, relay *core.Relay, device core.Device, digest delivery.Digest) error {
+	req := relayapi.PushRequest{Device: device, SHA256: digest.SHA256}
+	if manifest, ok := delivery.Manifest(ctx); ok {
+		req.Manifest = manifest.Manifest
+		req.ManifestSignature = manifest.Signature
+	}
+	if deltaPayload, ok := delivery.Delta(ctx); ok {
+		req.Delta = &deltaPayload
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode push request: %w", err)
+	}
+
+	resp, err := d.do(ctx, http.MethodPost, relay.Address+relayapi.PushPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to reach relay %s: %w", relay.Address, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotStored
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("relay %s push failed with status %d: %s", relay.Address, resp.StatusCode, msg)
+	}
+
+	report, _ := delivery.Progress(ctx)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var status relayapi.PushStatus
+		if err := json.Unmarshal(scanner.Bytes(), &status); err != nil {
+			return fmt.Errorf("invalid status from relay %s: %w", relay.Address, err)
+		}
+
+		if !status.Done {
+			if report != nil {
+				report(status.BytesSent)
+			}
+			continue
+		}
+
+		switch {
+		case status.DeltaRejected:
+			return fmt.Errorf("%w: via relay %s: %s", delivery.ErrDeltaRejected, relay.Address, status.Error)
+		case status.Error != "":
+			return fmt.Errorf("relay %s failed to push to %s: %s", relay.Address, device.ID, status.Error)
+		}
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("lost connection to relay %s: %w", relay.Address, err)
+	}
+	return fmt.Errorf("relay %s ended the push to %s without a result", relay.Address, device.ID)
+}
+
+// do sends a request to a relay with the configured headers.
+func (d *Delivery) do(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range d.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return d.client.Do(req)
+}
@@ -0,0 +1,153 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	httpdelivery "github.com/dovaclean/go-update-orchestrator/pkg/delivery/http"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
+	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
+	relayapi "github.com/dovaclean/go-update-orchestrator/pkg/relay"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+// startRelay starts a relay server and returns its URL and a counter of the
+// payload uploads it received.
+func startRelay(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+
+	payloadCache, err := cache.New(&cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	server, err := relayapi.NewServer(&relayapi.Config{Cache: payloadCache, Delivery: httpdelivery.New()})
+	if err != nil {
+		t.Fatalf("Failed to create relay: %v", err)
+	}
+
+	var uploads atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			uploads.Add(1)
+		}
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL, &uploads
+}
+
+func TestDelivery_PushesThroughLocationRelay(t *testing.T) {
+	relayURL, uploads := startRelay(t)
+
+	ctx := context.Background()
+	registry := memory.New()
+	registry.SetRelay(ctx, core.Relay{Location: "store-1", Address: relayURL})
+
+	payload := bytes.Repeat([]byte("firmware"), 64*1024)
+	sum := sha256.Sum256(payload)
+	digest := delivery.Digest{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))}
+
+	var devices []*mocks.DeviceServer
+	for range 4 {
+		ds := mocks.NewDeviceServer("1.0.0")
+		t.Cleanup(ds.Close)
+		devices = append(devices, ds)
+	}
+
+	d := New(registry, httpdelivery.New())
+	sent := make([]atomic.Int64, len(devices))
+	var wg sync.WaitGroup
+	for i, ds := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			device := core.Device{ID: ds.URL(), Address: ds.URL(), Location: "store-1"}
+			pushCtx := delivery.WithPayloadDigest(ctx, digest)
+			pushCtx = delivery.WithProgress(pushCtx, func(n int64) { sent[i].Store(n) })
+			if err := d.Push(pushCtx, device, bytes.NewReader(payload)); err != nil {
+				t.Errorf("Push failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The payload crosses to the location once, then reaches every device
+	if got := uploads.Load(); got != 1 {
+		t.Errorf("Expected 1 upload to the relay, got %d", got)
+	}
+	for i, ds := range devices {
+		if ds.GetUpdateCount() != 1 || ds.GetLastUpdateSize() != int64(len(payload)) {
+			t.Errorf("Device %d: expected the payload once, got %d updates of %d bytes", i, ds.GetUpdateCount(), ds.GetLastUpdateSize())
+		}
+		if got := sent[i].Load(); got != int64(len(payload)) {
+			t.Errorf("Device %d: expected progress of %d bytes, got %d", i, len(payload), got)
+		}
+	}
+}
+
+func TestDelivery_PushesDirectlyWithoutRelay(t *testing.T) {
+	relayURL, uploads := startRelay(t)
+
+	ctx := context.Background()
+	registry := memory.New()
+	registry.SetRelay(ctx, core.Relay{Location: "store-1", Address: relayURL})
+
+	payload := []byte("firmware")
+	sum := sha256.Sum256(payload)
+	ctx = delivery.WithPayloadDigest(ctx, delivery.Digest{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))})
+
+	direct := mocks.NewMockDelivery()
+	d := New(registry, direct)
+	for _, location := range []string{"", "store-2"} {
+		if err := d.Push(ctx, core.Device{ID: "device-1", Location: location}, bytes.NewReader(payload)); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	if direct.GetPushCount() != 2 || uploads.Load() != 0 {
+		t.Errorf("Expected 2 direct pushes and no relay uploads, got %d and %d", direct.GetPushCount(), uploads.Load())
+	}
+}
+
+func TestDelivery_ReuploadsEvictedPayload(t *testing.T) {
+	relayURL, uploads := startRelay(t)
+
+	ctx := context.Background()
+	registry := memory.New()
+	registry.SetRelay(ctx, core.Relay{Location: "store-1", Address: relayURL})
+
+	payload := []byte("firmware")
+	sum := sha256.Sum256(payload)
+	ctx = delivery.WithPayloadDigest(ctx, delivery.Digest{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))})
+
+	ds := mocks.NewDeviceServer("1.0.0")
+	defer ds.Close()
+	device := core.Device{ID: "device-1", Address: ds.URL(), Location: "store-1"}
+
+	d := New(registry, httpdelivery.New())
+	// Pretend an earlier push uploaded the payload, though the relay lacks it
+	d.uploads[uploadKey{relayURL, hex.EncodeToString(sum[:])}] = &upload{done: closed()}
+
+	if err := d.Push(ctx, device, bytes.NewReader(payload)); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if uploads.Load() != 1 || ds.GetUpdateCount() != 1 {
+		t.Errorf("Expected the payload re-uploaded and pushed, got %d uploads and %d updates", uploads.Load(), ds.GetUpdateCount())
+	}
+}
+
+// closed returns a closed channel.
+func closed() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
@@ -2,17 +2,41 @@ package ssh
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 	"github.com/pkg/sftp"
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
 )
 
+// Signed update manifests are uploaded next to the payload, at RemotePath
+// with these suffixes.
+const (
+	ManifestSuffix  = ".manifest.json"
+	SignatureSuffix = ".manifest.sig"
+)
+
+// DeltaSuffix is appended to RemotePath for the file describing a delta
+// payload (see DeltaInfo). It is removed when a full payload is uploaded.
+const DeltaSuffix = ".delta.json"
+
+// DeltaInfo is the content of the delta description file.
+type DeltaInfo struct {
+	BaseVersion   string `json:"base_version"`   // Firmware version the patch applies to
+	TargetVersion string `json:"target_version"` // Firmware version the patched image installs
+	TargetSHA256  string `json:"target_sha256"`  // Hex-encoded SHA-256 digest of the patched image
+	TargetSize    int64  `json:"target_size"`    // Size of the patched image in bytes
+}
+
 // Config holds SSH delivery configuration.
 type Config struct {
 	// Username for SSH authentication
@@ -38,6 +62,16 @@ type Config struct {
 
 	// KnownHostsPath is the path to known_hosts file (optional)
 	KnownHostsPath string
+
+	// ChecksumCommand prints the SHA-256 digest of the uploaded file, which
+	// is passed as its argument (e.g., "sha256sum"). Empty, the default,
+	// disables the check.
+	ChecksumCommand string
+
+	// AcceptDeltas reports that devices apply delta payloads, described by
+	// the file at RemotePath+DeltaSuffix. Otherwise delta pushes are
+	// rejected with delivery.ErrDeltaRejected before connecting.
+	AcceptDeltas bool
 }
 
 // DefaultConfig returns SSH configuration with sensible defaults.
@@ -67,6 +101,11 @@ func NewWithConfig(config *Config) *Delivery {
 
 // Push delivers the update payload to a device via SFTP.
 func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
+	deltaPayload, isDelta := delivery.Delta(ctx)
+	if isDelta && !d.config.AcceptDeltas {
+		return fmt.Errorf("%w: delta payloads are not enabled", delivery.ErrDeltaRejected)
+	}
+
 	// Create SSH client config
 	sshConfig, err := d.createSSHConfig()
 	if err != nil {
@@ -126,7 +165,11 @@ func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Read
 	}
 	defer remoteFile.Close()
 
-	// Stream payload to remote file with context cancellation support
+	// Stream payload to remote file with context cancellation support,
+	// reporting bytes sent if the caller asked for progress and hashing
+	// what is sent
+	hash := sha256.New()
+	payload = io.TeeReader(delivery.NewProgressReader(ctx, payload), hash)
 	doneChan := make(chan error, 1)
 	go func() {
 		_, err := io.Copy(remoteFile, payload)
@@ -142,9 +185,132 @@ func (d *Delivery) Push(ctx context.Context, device core.Device, payload io.Read
 		return ctx.Err()
 	}
 
+	if err := remoteFile.Close(); err != nil {
+		return fmt.Errorf("failed to close remote file: %w", err)
+	}
+
+	// Check the device stored the intended payload
+	expected := hex.EncodeToString(hash.Sum(nil))
+	if digest, ok := delivery.PayloadDigest(ctx); ok {
+		expected = digest.SHA256
+	}
+	if err := d.verifyChecksum(ctx, client, expected); err != nil {
+		return err
+	}
+
+	// Leave the signed manifest next to the payload for the device to check
+	if manifest, ok := delivery.Manifest(ctx); ok {
+		if err := writeRemoteFile(sftpClient, d.config.RemotePath+ManifestSuffix, manifest.Manifest); err != nil {
+			return err
+		}
+		if err := writeRemoteFile(sftpClient, d.config.RemotePath+SignatureSuffix, manifest.Signature); err != nil {
+			return err
+		}
+	}
+
+	// Tell the device the payload is a patch, or that it no longer is
+	if isDelta {
+		info, err := json.Marshal(DeltaInfo{
+			BaseVersion:   deltaPayload.BaseVersion,
+			TargetVersion: deltaPayload.TargetVersion,
+			TargetSHA256:  deltaPayload.Target.SHA256,
+			TargetSize:    deltaPayload.Target.Size,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode delta info: %w", err)
+		}
+		if err := writeRemoteFile(sftpClient, d.config.RemotePath+DeltaSuffix, info); err != nil {
+			return err
+		}
+	} else if d.config.AcceptDeltas {
+		sftpClient.Remove(d.config.RemotePath + DeltaSuffix)
+	}
+
+	return nil
+}
+
+// writeRemoteFile creates or replaces a small file on the device.
+func writeRemoteFile(client *sftp.Client, path string, data []byte) error {
+	file, err := client.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file %s: %w", path, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write remote file %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close remote file %s: %w", path, err)
+	}
 	return nil
 }
 
+// verifyChecksum runs ChecksumCommand on the uploaded file and compares the
+// digest it prints with the expected one. Mismatches match
+// core.ErrVerificationFailed with errors.Is.
+func (d *Delivery) verifyChecksum(ctx context.Context, client *ssh.Client, expected string) error {
+	if d.config.ChecksumCommand == "" {
+		return nil
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	type result struct {
+		output []byte
+		err    error
+	}
+	cmdDone := make(chan result, 1)
+	go func() {
+		output, err := session.Output(d.config.ChecksumCommand + " " + shellQuote(d.config.RemotePath))
+		cmdDone <- result{output, err}
+	}()
+
+	var res result
+	select {
+	case res = <-cmdDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d.config.Timeout):
+		return fmt.Errorf("checksum timeout")
+	}
+	if res.err != nil {
+		return fmt.Errorf("checksum command failed: %w", res.err)
+	}
+
+	actual, err := parseChecksum(res.output)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: remote payload SHA-256 is %s, expected %s", core.ErrVerificationFailed, actual, expected)
+	}
+	return nil
+}
+
+// parseChecksum extracts the digest from sha256sum-style output
+// ("<digest>  <file>").
+func parseChecksum(output []byte) (string, error) {
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum command printed no digest")
+	}
+
+	sum := fields[0]
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("checksum command printed an invalid digest: %q", sum)
+	}
+	return sum, nil
+}
+
+// shellQuote quotes a path for use in a remote shell command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // Verify checks if the update was successfully applied via SSH command.
 func (d *Delivery) Verify(ctx context.Context, device core.Device) error {
 	if d.config.VerifyCommand == "" {
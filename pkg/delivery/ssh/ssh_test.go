@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"context"
+	"errors"
 
 	"net"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
 )
 
 // TestSSHDelivery_Push tests file transfer via SFTP
@@ -161,6 +163,21 @@ func TestSSHDelivery_ConfigValidation(t *testing.T) {
 	}
 }
 
+func TestSSHDelivery_RejectsDeltasUnlessEnabled(t *testing.T) {
+	config := DefaultConfig()
+	config.Password = "secret"
+	config.Timeout = time.Second
+
+	ctx := delivery.WithDelta(context.Background(), delivery.DeltaPayload{
+		BaseVersion:   "1.0.0",
+		TargetVersion: "2.0.0",
+	})
+	err := NewWithConfig(config).Push(ctx, core.Device{ID: "test-device", Address: "127.0.0.1:1"}, strings.NewReader("patch"))
+	if !errors.Is(err, delivery.ErrDeltaRejected) {
+		t.Errorf("Expected ErrDeltaRejected, got %v", err)
+	}
+}
+
 func TestHasPort(t *testing.T) {
 	tests := []struct {
 		address  string
@@ -182,6 +199,49 @@ func TestHasPort(t *testing.T) {
 	}
 }
 
+func TestParseChecksum(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	tests := []struct {
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{sum + "  /tmp/update.bin\n", sum, false},
+		{sum + "\n", sum, false},
+		{"", "", true},
+		{"sha256sum: /tmp/update.bin: No such file or directory", "", true},
+		{"abc123  /tmp/update.bin", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseChecksum([]byte(tt.output))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseChecksum(%q) error = %v, wantErr %v", tt.output, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseChecksum(%q) = %q, expected %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/tmp/update.bin", `'/tmp/update.bin'`},
+		{"/tmp/my update.bin", `'/tmp/my update.bin'`},
+		{"/tmp/it's.bin", `'/tmp/it'\''s.bin'`},
+	}
+
+	for _, tt := range tests {
+		if result := shellQuote(tt.path); result != tt.expected {
+			t.Errorf("shellQuote(%s) = %s, expected %s", tt.path, result, tt.expected)
+		}
+	}
+}
+
 // Mock SSH Server for testing
 // NOTE: Mock SSH server tests are disabled - they require complex setup
 // with valid SSH keys and server infrastructure. For real SSH testing,
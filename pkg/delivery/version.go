@@ -0,0 +1,62 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/version"
+)
+
+// targetVersionKey is the context key for the expected firmware version.
+type targetVersionKey struct{}
+
+// WithTargetVersion returns a context carrying the firmware version a device
+// is expected to report after the update. Verify implementations that can
+// read the device version compare against it with CheckVersion.
+func WithTargetVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, targetVersionKey{}, version)
+}
+
+// TargetVersion returns the expected firmware version carried by ctx, if any.
+func TargetVersion(ctx context.Context) (string, bool) {
+	version, ok := ctx.Value(targetVersionKey{}).(string)
+	return version, ok && version != ""
+}
+
+// CheckVersion returns a *VersionMismatchError if the version a device
+// reported is not the expected one. Versions are compared by precedence with
+// version.Compare, so "v2.0" matches "2.0.0" and build metadata is ignored;
+// versions that do not parse must match exactly.
+func CheckVersion(deviceID, expected, reported string) error {
+	if reported == expected {
+		return nil
+	}
+	if c, err := version.Compare(reported, expected); err == nil && c == 0 {
+		return nil
+	}
+	return &VersionMismatchError{
+		DeviceID: deviceID,
+		Expected: expected,
+		Reported: reported,
+	}
+}
+
+// VersionMismatchError is returned by Verify when a device reports a firmware
+// version other than the one the update targets. It matches
+// core.ErrVerificationFailed with errors.Is.
+type VersionMismatchError struct {
+	DeviceID string // Device that was verified
+	Expected string // Version the update targets
+	Reported string // Version the device reported
+}
+
+// Error implements the error interface.
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("device %s reports firmware version %q, expected %q", e.DeviceID, e.Reported, e.Expected)
+}
+
+// Unwrap allows errors.Is(err, core.ErrVerificationFailed).
+func (e *VersionMismatchError) Unwrap() error {
+	return core.ErrVerificationFailed
+}
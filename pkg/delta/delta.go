@@ -0,0 +1,227 @@
+// Package delta implements differential payloads: patches that turn the
+// firmware image a device already runs into a new one, so that only the
+// differences travel over the network. Patches are computed with the bsdiff
+// algorithm and compressed with gzip.
+//
+// A patch starts with an 8-byte magic and the size of the new image (int64,
+// little-endian), followed by a gzip stream of blocks. Each block is a
+// control triple of int64s (add, copy, seek), add bytes to be added to the
+// old image at the current old offset, and copy bytes to be copied as-is;
+// the old offset then moves by add+seek.
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// magic identifies a delta patch.
+const magic = "GODELTA1"
+
+// ErrCorruptPatch indicates a patch is malformed or does not apply.
+var ErrCorruptPatch = errors.New("corrupt delta patch")
+
+// Diff computes a patch that turns old into target.
+func Diff(old, target []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	binary.Write(&buf, binary.LittleEndian, int64(len(target)))
+
+	zw := gzip.NewWriter(&buf)
+	w := bufio.NewWriter(zw)
+	if err := diff(old, target, w); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// diff writes the blocks turning old into target, as in bsdiff.
+func diff(old, target []byte, w io.Writer) error {
+	if len(old) < math.MaxInt32 {
+		return diffIndexed[int32](old, target, w)
+	}
+	return diffIndexed[int](old, target, w)
+}
+
+// diffIndexed implements diff with suffix array entries of type T. The
+// suffix sort needs two arrays of len(old)+1 entries.
+func diffIndexed[T index](old, target []byte, w io.Writer) error {
+	I := make([]T, len(old)+1)
+	V := make([]T, len(old)+1)
+	qsufsort(I, V, old)
+	V = nil
+
+	oldSize, targetSize := len(old), len(target)
+	var scan, pos, length int
+	var lastScan, lastPos, lastOffset int
+
+	for scan < targetSize {
+		oldScore := 0
+		scan += length
+
+		for scsc := scan; scan < targetSize; scan++ {
+			length, pos = search(I, old, target[scan:], 0, oldSize)
+
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < oldSize && old[scsc+lastOffset] == target[scsc] {
+					oldScore++
+				}
+			}
+
+			if (length == oldScore && length != 0) || length > oldScore+8 {
+				break
+			}
+
+			if scan+lastOffset < oldSize && old[scan+lastOffset] == target[scan] {
+				oldScore--
+			}
+		}
+
+		if length == oldScore && scan != targetSize {
+			continue
+		}
+
+		// Extend the previous match forwards...
+		s, sf, lenf := 0, 0, 0
+		for i := 0; lastScan+i < scan && lastPos+i < oldSize; {
+			if old[lastPos+i] == target[lastScan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenf {
+				sf, lenf = s, i
+			}
+		}
+
+		// ...and the next one backwards
+		lenb := 0
+		if scan < targetSize {
+			s, sb := 0, 0
+			for i := 1; scan >= lastScan+i && pos >= i; i++ {
+				if old[pos-i] == target[scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenb {
+					sb, lenb = s, i
+				}
+			}
+		}
+
+		// Split any overlap between the two
+		if lastScan+lenf > scan-lenb {
+			overlap := (lastScan + lenf) - (scan - lenb)
+			s, ss, lens := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if target[lastScan+lenf-overlap+i] == old[lastPos+lenf-overlap+i] {
+					s++
+				}
+				if target[scan-lenb+i] == old[pos-lenb+i] {
+					s--
+				}
+				if s > ss {
+					ss, lens = s, i+1
+				}
+			}
+			lenf += lens - overlap
+			lenb -= lens
+		}
+
+		extra := (scan - lenb) - (lastScan + lenf)
+		seek := (pos - lenb) - (lastPos + lenf)
+		if err := binary.Write(w, binary.LittleEndian, [3]int64{int64(lenf), int64(extra), int64(seek)}); err != nil {
+			return err
+		}
+
+		add := make([]byte, lenf)
+		for i := range add {
+			add[i] = target[lastScan+i] - old[lastPos+i]
+		}
+		if _, err := w.Write(add); err != nil {
+			return err
+		}
+		if _, err := w.Write(target[lastScan+lenf : scan-lenb]); err != nil {
+			return err
+		}
+
+		lastScan = scan - lenb
+		lastPos = pos - lenb
+		lastOffset = pos - scan
+	}
+	return nil
+}
+
+// Patch applies a patch made by Diff to old and returns the new image.
+// Errors match ErrCorruptPatch if the patch is malformed.
+func Patch(old, patch []byte) ([]byte, error) {
+	if len(patch) < len(magic)+8 || string(patch[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: bad header", ErrCorruptPatch)
+	}
+	size := int64(binary.LittleEndian.Uint64(patch[len(magic):]))
+	if size < 0 {
+		return nil, fmt.Errorf("%w: bad size %d", ErrCorruptPatch, size)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(patch[len(magic)+8:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptPatch, err)
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+
+	target := make([]byte, 0, min(size, int64(len(old))+int64(len(patch))*8))
+	var oldPos int64
+	for int64(len(target)) < size {
+		var ctrl [3]int64
+		if err := binary.Read(r, binary.LittleEndian, &ctrl); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptPatch, err)
+		}
+		add, extra, seek := ctrl[0], ctrl[1], ctrl[2]
+		if add < 0 || extra < 0 || int64(len(target))+add+extra > size {
+			return nil, fmt.Errorf("%w: bad block", ErrCorruptPatch)
+		}
+
+		start := len(target)
+		if _, err := io.CopyN(sliceWriter{&target}, r, add); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptPatch, err)
+		}
+		for i := int64(0); i < add; i++ {
+			if p := oldPos + i; p >= 0 && p < int64(len(old)) {
+				target[start+int(i)] += old[p]
+			}
+		}
+		oldPos += add
+
+		if _, err := io.CopyN(sliceWriter{&target}, r, extra); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorruptPatch, err)
+		}
+		oldPos += seek
+	}
+
+	// Reading to the end checks the gzip trailer
+	if n, err := io.Copy(io.Discard, r); err != nil || n != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrCorruptPatch)
+	}
+	return target, nil
+}
+
+// sliceWriter appends to a byte slice.
+type sliceWriter struct {
+	b *[]byte
+}
+
+func (w sliceWriter) Write(p []byte) (int, error) {
+	*w.b = append(*w.b, p...)
+	return len(p), nil
+}
@@ -0,0 +1,100 @@
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// mutate returns a copy of b with a few edits, insertions and deletions.
+func mutate(rng *rand.Rand, b []byte) []byte {
+	out := append([]byte(nil), b...)
+	for i := 0; i < 10; i++ {
+		pos := rng.Intn(len(out))
+		switch rng.Intn(3) {
+		case 0:
+			out[pos] ^= 0xff
+		case 1:
+			insert := make([]byte, rng.Intn(64))
+			rng.Read(insert)
+			out = append(out[:pos], append(insert, out[pos:]...)...)
+		default:
+			end := min(pos+rng.Intn(64), len(out))
+			out = append(out[:pos], out[end:]...)
+		}
+	}
+	return out
+}
+
+func TestDiff_Roundtrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	old := make([]byte, 64*1024)
+	rng.Read(old)
+	target := mutate(rng, old)
+
+	patch, err := Diff(old, target)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(patch) > len(target)/10 {
+		t.Errorf("Expected a small patch, got %d bytes for a %d byte image", len(patch), len(target))
+	}
+
+	got, err := Patch(old, patch)
+	if err != nil {
+		t.Fatalf("Patch failed: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Error("Patched image does not match the target")
+	}
+}
+
+func TestDiff_EdgeCases(t *testing.T) {
+	tests := []struct {
+		name        string
+		old, target []byte
+	}{
+		{"empty", nil, nil},
+		{"from empty", nil, []byte("new firmware")},
+		{"to empty", []byte("old firmware"), nil},
+		{"identical", []byte("firmware v1"), []byte("firmware v1")},
+		{"unrelated", []byte("aaaaaaaaaaaaaaaa"), []byte("zyxwvutsrqponmlk")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Diff(tt.old, tt.target)
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			got, err := Patch(tt.old, patch)
+			if err != nil {
+				t.Fatalf("Patch failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.target) {
+				t.Errorf("Expected %q, got %q", tt.target, got)
+			}
+		})
+	}
+}
+
+func TestPatch_RejectsCorruptPatch(t *testing.T) {
+	old := []byte("firmware version 1.0.0")
+	patch, _ := Diff(old, []byte("firmware version 2.0.0"))
+
+	truncated := patch[:len(patch)-4]
+	badMagic := append([]byte("NOTDELTA"), patch[len(magic):]...)
+
+	for name, p := range map[string][]byte{
+		"truncated": truncated,
+		"bad magic": badMagic,
+		"empty":     nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Patch(old, p); !errors.Is(err, ErrCorruptPatch) {
+				t.Errorf("Expected ErrCorruptPatch, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package delta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+)
+
+// ErrNoDelta indicates no delta is available from a firmware version to an
+// update's image, so the full payload must be pushed.
+var ErrNoDelta = errors.New("no delta available")
+
+// Source provides delta payloads.
+type Source interface {
+	// Delta returns a patch that turns the image of baseVersion into
+	// target, or an error matching ErrNoDelta if there is none.
+	Delta(ctx context.Context, baseVersion string, target *payload.Shared) ([]byte, error)
+}
+
+// DefaultMaxRatio is the default Library.MaxRatio.
+const DefaultMaxRatio = 0.5
+
+// DefaultMaxBaseSize is the default Library.MaxBaseSize.
+const DefaultMaxBaseSize = 64 << 20
+
+// Library is a Source that holds the images of past firmware versions and
+// generates patches from them on demand. Patches are generated once per base
+// version and target image and then reused for every device; patches built
+// ahead of time can be added with AddPatch.
+type Library struct {
+	// MaxRatio is the largest patch size, relative to the target image,
+	// worth pushing instead of the full payload.
+	MaxRatio float64
+
+	// MaxBaseSize is the largest base image, in bytes, to generate patches
+	// from; larger bases get the full payload, unless a patch was added with
+	// AddPatch. Generating holds both images in memory, plus a suffix array
+	// of 8 bytes per base image byte (16 for bases of 2 GiB or more), so a
+	// 64 MiB base needs about 600 MiB. Zero means no limit.
+	MaxBaseSize int64
+
+	mu      sync.Mutex
+	bases   map[string]*payload.Shared
+	patches map[patchKey]*patchEntry
+}
+
+// patchKey identifies a patch by its base version and target image digest.
+type patchKey struct {
+	baseVersion  string
+	targetSHA256 string
+}
+
+// patchEntry is a patch, generated at most once. done is closed once patch
+// and err are set.
+type patchEntry struct {
+	done  chan struct{}
+	patch []byte
+	err   error
+}
+
+// NewLibrary creates an empty library.
+func NewLibrary() *Library {
+	return &Library{
+		MaxRatio:    DefaultMaxRatio,
+		MaxBaseSize: DefaultMaxBaseSize,
+		bases:       make(map[string]*payload.Shared),
+		patches:     make(map[patchKey]*patchEntry),
+	}
+}
+
+// AddBase registers the image of a firmware version that devices may run.
+func (l *Library) AddBase(version string, image *payload.Shared) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.bases[version] = image
+}
+
+// AddPatch registers a precomputed patch from baseVersion to the image with
+// the given hex-encoded SHA-256 digest.
+func (l *Library) AddPatch(baseVersion, targetSHA256 string, patch []byte) {
+	entry := &patchEntry{done: make(chan struct{}), patch: patch}
+	close(entry.done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.patches[patchKey{baseVersion, targetSHA256}] = entry
+}
+
+// Delta implements Source. Devices on the same base version share one
+// patch; if it is still being generated, Delta waits for it.
+func (l *Library) Delta(ctx context.Context, baseVersion string, target *payload.Shared) ([]byte, error) {
+	sum, err := target.SHA256()
+	if err != nil {
+		return nil, err
+	}
+	key := patchKey{baseVersion, sum}
+
+	l.mu.Lock()
+	entry, ok := l.patches[key]
+	if !ok {
+		base, hasBase := l.bases[baseVersion]
+		if !hasBase {
+			l.mu.Unlock()
+			return nil, fmt.Errorf("%w: no image for version %s", ErrNoDelta, baseVersion)
+		}
+		if l.MaxBaseSize > 0 && base.Size() > l.MaxBaseSize {
+			l.mu.Unlock()
+			return nil, fmt.Errorf("%w: image for version %s is %d bytes, over the %d byte limit", ErrNoDelta, baseVersion, base.Size(), l.MaxBaseSize)
+		}
+
+		entry = &patchEntry{done: make(chan struct{})}
+		l.patches[key] = entry
+		l.mu.Unlock()
+
+		entry.patch, entry.err = generate(ctx, base, target)
+		if entry.err != nil {
+			// Let the next device try again
+			l.mu.Lock()
+			delete(l.patches, key)
+			l.mu.Unlock()
+		}
+		close(entry.done)
+	} else {
+		l.mu.Unlock()
+	}
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	if l.MaxRatio > 0 && float64(len(entry.patch)) > l.MaxRatio*float64(target.Size()) {
+		return nil, fmt.Errorf("%w: patch from %s is %d bytes for a %d byte image", ErrNoDelta, baseVersion, len(entry.patch), target.Size())
+	}
+	return entry.patch, nil
+}
+
+// generate reads both images and diffs them.
+func generate(ctx context.Context, base, target *payload.Shared) ([]byte, error) {
+	old, err := io.ReadAll(base.Reader())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image: %w", err)
+	}
+	image, err := io.ReadAll(target.Reader())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read target image: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Diff(old, image)
+}
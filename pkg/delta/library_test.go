@@ -0,0 +1,91 @@
+package delta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+)
+
+// shared returns a shared payload over b.
+func shared(b []byte) *payload.Shared {
+	return payload.NewShared(bytes.NewReader(b), int64(len(b)))
+}
+
+func TestLibrary_Delta(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 16*1024)
+	rng.Read(base)
+	target := mutate(rng, base)
+
+	library := NewLibrary()
+	library.AddBase("1.0.0", shared(base))
+
+	// Concurrent requests share one patch
+	patches := make([][]byte, 8)
+	var wg sync.WaitGroup
+	for i := range patches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			patch, err := library.Delta(context.Background(), "1.0.0", shared(target))
+			if err != nil {
+				t.Errorf("Delta failed: %v", err)
+			}
+			patches[i] = patch
+		}()
+	}
+	wg.Wait()
+
+	for _, patch := range patches[1:] {
+		if &patch[0] != &patches[0][0] {
+			t.Fatal("Expected the patch to be generated once")
+		}
+	}
+	got, err := Patch(base, patches[0])
+	if err != nil || !bytes.Equal(got, target) {
+		t.Errorf("Patch did not produce the target: %v", err)
+	}
+}
+
+func TestLibrary_NoDelta(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 4096)
+	rng.Read(base)
+	unrelated := make([]byte, 4096)
+	rng.Read(unrelated)
+
+	library := NewLibrary()
+	library.AddBase("1.0.0", shared(base))
+
+	if _, err := library.Delta(context.Background(), "0.9.0", shared(unrelated)); !errors.Is(err, ErrNoDelta) {
+		t.Errorf("Expected ErrNoDelta for an unknown base, got %v", err)
+	}
+	if _, err := library.Delta(context.Background(), "1.0.0", shared(unrelated)); !errors.Is(err, ErrNoDelta) {
+		t.Errorf("Expected ErrNoDelta for a patch larger than MaxRatio, got %v", err)
+	}
+
+	library.MaxRatio = 0
+	library.MaxBaseSize = 1024
+	if _, err := library.Delta(context.Background(), "1.0.0", shared(mutate(rng, base))); !errors.Is(err, ErrNoDelta) {
+		t.Errorf("Expected ErrNoDelta for a base larger than MaxBaseSize, got %v", err)
+	}
+}
+
+func TestLibrary_AddPatch(t *testing.T) {
+	target := shared([]byte("firmware 2.0.0"))
+	sum, _ := target.SHA256()
+
+	library := NewLibrary()
+	library.MaxRatio = 0
+	library.AddPatch("1.0.0", sum, []byte("precomputed"))
+
+	patch, err := library.Delta(context.Background(), "1.0.0", target)
+	if err != nil || string(patch) != "precomputed" {
+		t.Errorf("Expected the precomputed patch, got %q, %v", patch, err)
+	}
+}
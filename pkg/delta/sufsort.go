@@ -0,0 +1,183 @@
+package delta
+
+import "bytes"
+
+// index is the type of suffix array entries. Images under 2 GiB use int32,
+// halving the memory the suffix sort needs.
+type index interface {
+	~int32 | ~int
+}
+
+// qsufsort builds the suffix array of buf into I (len(buf)+1 entries),
+// using V as scratch space, with the Larsson-Sadakane algorithm used by
+// bsdiff.
+func qsufsort[T index](I, V []T, buf []byte) {
+	n := len(buf)
+
+	var buckets [256]T
+	for _, b := range buf {
+		buckets[b]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+
+	for i, b := range buf {
+		buckets[b]++
+		I[buckets[b]] = T(i)
+	}
+	I[0] = T(n)
+	for i, b := range buf {
+		V[i] = buckets[b]
+	}
+	V[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			I[buckets[i]] = -1
+		}
+	}
+	I[0] = -1
+
+	for h := T(1); I[0] != -T(n+1); h += h {
+		length := T(0)
+		i := T(0)
+		for i < T(n+1) {
+			if I[i] < 0 {
+				length -= I[i]
+				i -= I[i]
+				continue
+			}
+			if length != 0 {
+				I[i-length] = -length
+			}
+			length = V[I[i]] + 1 - i
+			split(I, V, i, length, h)
+			i += length
+			length = 0
+		}
+		if length != 0 {
+			I[i-length] = -length
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		I[V[i]] = T(i)
+	}
+}
+
+// split refines the group of suffixes I[start:start+length], which share
+// their first h bytes, by the next h bytes.
+func split[T index](I, V []T, start, length, h T) {
+	if length < 16 {
+		for k := start; k < start+length; {
+			j := T(1)
+			x := V[I[k]+h]
+			for i := T(1); k+i < start+length; i++ {
+				if V[I[k+i]+h] < x {
+					x = V[I[k+i]+h]
+					j = 0
+				}
+				if V[I[k+i]+h] == x {
+					I[k+j], I[k+i] = I[k+i], I[k+j]
+					j++
+				}
+			}
+			for i := T(0); i < j; i++ {
+				V[I[k+i]] = k + j - 1
+			}
+			if j == 1 {
+				I[k] = -1
+			}
+			k += j
+		}
+		return
+	}
+
+	x := V[I[start+length/2]+h]
+	jj, kk := T(0), T(0)
+	for i := start; i < start+length; i++ {
+		if V[I[i]+h] < x {
+			jj++
+		}
+		if V[I[i]+h] == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k := start, T(0), T(0)
+	for i < jj {
+		switch {
+		case V[I[i]+h] < x:
+			i++
+		case V[I[i]+h] == x:
+			I[i], I[jj+j] = I[jj+j], I[i]
+			j++
+		default:
+			I[i], I[kk+k] = I[kk+k], I[i]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if V[I[jj+j]+h] == x {
+			j++
+		} else {
+			I[jj+j], I[kk+k] = I[kk+k], I[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		split(I, V, start, jj-start, h)
+	}
+	for i := T(0); i < kk-jj; i++ {
+		V[I[jj+i]] = kk - 1
+	}
+	if jj == kk-1 {
+		I[jj] = -1
+	}
+	if start+length > kk {
+		split(I, V, kk, start+length-kk, h)
+	}
+}
+
+// search finds the longest prefix of target that occurs in old, among the
+// suffixes I[st:en+1], and returns its length and position in old.
+func search[T index](I []T, old, target []byte, st, en int) (length, pos int) {
+	for en-st >= 2 {
+		x := st + (en-st)/2
+		if compare(old[I[x]:], target) < 0 {
+			st = x
+		} else {
+			en = x
+		}
+	}
+
+	x := matchLen(old[I[st]:], target)
+	y := matchLen(old[I[en]:], target)
+	if x > y {
+		return x, int(I[st])
+	}
+	return y, int(I[en])
+}
+
+// compare compares the common-length prefixes of a and b.
+func compare(a, b []byte) int {
+	n := min(len(a), len(b))
+	return bytes.Compare(a[:n], b[:n])
+}
+
+// matchLen returns the length of the common prefix of a and b.
+func matchLen(a, b []byte) int {
+	n := min(len(a), len(b))
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -10,10 +10,29 @@ const (
 	EventUpdateCompleted EventType = "update.completed"
 	EventUpdateFailed    EventType = "update.failed"
 	EventUpdateCancelled EventType = "update.cancelled"
+	EventUpdatePaused    EventType = "update.paused"
+	EventUpdateResumed   EventType = "update.resumed"
+
+	EventRollbackStarted EventType = "update.rollback_started"
+
+	EventRecurringFired   EventType = "update.recurring_fired"
+	EventRecurringSkipped EventType = "update.recurring_skipped"
+
+	EventPhaseStarted    EventType = "phase.started"
+	EventPhaseCompleted  EventType = "phase.completed"
+	EventPhaseGateFailed EventType = "phase.gate_failed"
+
+	EventPhaseAwaitingApproval EventType = "phase.awaiting_approval"
+	EventPhaseApproved         EventType = "phase.approved"
+	EventPhaseRejected         EventType = "phase.rejected"
 
 	EventDeviceStarted   EventType = "device.started"
 	EventDeviceCompleted EventType = "device.completed"
 	EventDeviceFailed    EventType = "device.failed"
+	EventDeviceConnected EventType = "device.connected"
+
+	EventDeviceVerified           EventType = "device.verified"
+	EventDeviceVerificationFailed EventType = "device.verification_failed"
 
 	EventProgressUpdate EventType = "progress.update"
 )
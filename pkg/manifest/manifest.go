@@ -0,0 +1,112 @@
+// Package manifest implements signed update manifests. A manifest is a JSON
+// document describing an update payload, signed with Ed25519 so that the
+// orchestrator and devices can check where the firmware came from.
+package manifest
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// Manifest describes an update payload.
+type Manifest struct {
+	KeyID          string    `json:"key_id,omitempty"`          // Keyring entry that signed the manifest
+	PayloadSHA256  string    `json:"payload_sha256"`            // Hex-encoded SHA-256 digest of the payload
+	PayloadSize    int64     `json:"payload_size,omitempty"`    // Payload size in bytes
+	TargetVersion  string    `json:"target_version,omitempty"`  // Firmware version the payload installs
+	HardwareModels []string  `json:"hardware_models,omitempty"` // Models the payload supports (empty = any)
+	ExpiresAt      time.Time `json:"expires_at,omitzero"`       // When the manifest stops being valid (zero = never)
+}
+
+// Parse decodes a manifest without checking its signature.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %w", core.ErrInvalidManifest, err)
+	}
+
+	sum, err := hex.DecodeString(m.PayloadSHA256)
+	if err != nil || len(sum) != 32 {
+		return nil, fmt.Errorf("%w: invalid payload digest %q", core.ErrInvalidManifest, m.PayloadSHA256)
+	}
+	return &m, nil
+}
+
+// Sign encodes a manifest and signs it with key. It returns the encoded
+// manifest and its signature, which must be kept together: the signature
+// covers the exact bytes of the encoding.
+func Sign(m *Manifest, key ed25519.PrivateKey) (data, signature []byte, err error) {
+	data, err = json.Marshal(m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return data, ed25519.Sign(key, data), nil
+}
+
+// Expired reports whether the manifest has expired at the given time.
+func (m *Manifest) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && now.After(m.ExpiresAt)
+}
+
+// Supports reports whether the payload can be installed on a hardware model.
+func (m *Manifest) Supports(model string) bool {
+	return len(m.HardwareModels) == 0 || slices.Contains(m.HardwareModels, model)
+}
+
+// Keyring holds the public keys trusted to sign manifests.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys map[string]ed25519.PublicKey
+}
+
+// NewKeyring creates an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]ed25519.PublicKey)}
+}
+
+// Add trusts a public key under the given key ID.
+func (k *Keyring) Add(keyID string, key ed25519.PublicKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys[keyID] = key
+}
+
+// Verify checks that signature is a valid signature of data by a trusted
+// key and returns the decoded manifest. A manifest naming a key ID must be
+// signed by that key; otherwise any trusted key is accepted. Expiry is not
+// checked. Errors match core.ErrInvalidManifest.
+func (k *Keyring) Verify(data, signature []byte) (*Manifest, error) {
+	m, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if m.KeyID != "" {
+		key, ok := k.keys[m.KeyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown signing key %q", core.ErrInvalidManifest, m.KeyID)
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return nil, fmt.Errorf("%w: signature does not verify with key %q", core.ErrInvalidManifest, m.KeyID)
+		}
+		return m, nil
+	}
+
+	for _, key := range k.keys {
+		if ed25519.Verify(key, data, signature) {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: signature does not verify with any trusted key", core.ErrInvalidManifest)
+}
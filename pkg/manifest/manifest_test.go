@@ -0,0 +1,115 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// testManifest returns a manifest for a payload with the given key ID.
+func testManifest(keyID string) *Manifest {
+	return &Manifest{
+		KeyID:          keyID,
+		PayloadSHA256:  strings.Repeat("ab", 32),
+		PayloadSize:    1024,
+		TargetVersion:  "2.0.0",
+		HardwareModels: []string{"pos-100", "pos-200"},
+		ExpiresAt:      time.Now().Add(time.Hour).UTC(),
+	}
+}
+
+func TestKeyring_Verify(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	keyring := NewKeyring()
+	keyring.Add("release", pub)
+
+	data, sig, err := Sign(testManifest("release"), priv)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	m, err := keyring.Verify(data, sig)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if m.TargetVersion != "2.0.0" || m.PayloadSize != 1024 || len(m.HardwareModels) != 2 {
+		t.Errorf("Unexpected manifest: %+v", m)
+	}
+}
+
+func TestKeyring_VerifyWithoutKeyID(t *testing.T) {
+	_, other, _ := ed25519.GenerateKey(nil)
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	keyring := NewKeyring()
+	keyring.Add("old", other.Public().(ed25519.PublicKey))
+	keyring.Add("new", pub)
+
+	data, sig, _ := Sign(testManifest(""), priv)
+	if _, err := keyring.Verify(data, sig); err != nil {
+		t.Errorf("Expected any trusted key to verify, got %v", err)
+	}
+}
+
+func TestKeyring_Rejects(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, untrusted, _ := ed25519.GenerateKey(nil)
+	keyring := NewKeyring()
+	keyring.Add("release", pub)
+
+	data, sig, _ := Sign(testManifest("release"), priv)
+	tampered := []byte(strings.Replace(string(data), "2.0.0", "9.9.9", 1))
+	untrustedData, untrustedSig, _ := Sign(testManifest(""), untrusted)
+	unknownData, unknownSig, _ := Sign(testManifest("staging"), priv)
+
+	tests := []struct {
+		name      string
+		data, sig []byte
+	}{
+		{"tampered manifest", tampered, sig},
+		{"missing signature", data, nil},
+		{"untrusted key", untrustedData, untrustedSig},
+		{"unknown key ID", unknownData, unknownSig},
+		{"malformed manifest", []byte("{"), sig},
+		{"invalid digest", []byte(`{"payload_sha256":"xyz"}`), sig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := keyring.Verify(tt.data, tt.sig)
+			if !errors.Is(err, core.ErrInvalidManifest) {
+				t.Errorf("Expected ErrInvalidManifest, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManifest_Expired(t *testing.T) {
+	m := testManifest("")
+	if m.Expired(time.Now()) {
+		t.Error("Expected manifest to be valid before its expiry")
+	}
+	if !m.Expired(m.ExpiresAt.Add(time.Second)) {
+		t.Error("Expected manifest to be expired after its expiry")
+	}
+
+	m.ExpiresAt = time.Time{}
+	if m.Expired(time.Now().AddDate(10, 0, 0)) {
+		t.Error("Expected manifest without expiry to never expire")
+	}
+}
+
+func TestManifest_Supports(t *testing.T) {
+	m := testManifest("")
+	if !m.Supports("pos-100") || m.Supports("kiosk-1") || m.Supports("") {
+		t.Errorf("Unexpected compatibility for models %v", m.HardwareModels)
+	}
+
+	m.HardwareModels = nil
+	if !m.Supports("kiosk-1") {
+		t.Error("Expected manifest without models to support any model")
+	}
+}
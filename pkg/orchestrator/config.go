@@ -1,6 +1,12 @@
 package orchestrator
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/delta"
+	"github.com/dovaclean/go-update-orchestrator/pkg/manifest"
+)
 
 // Config holds orchestrator configuration.
 type Config struct {
@@ -15,15 +21,43 @@ type Config struct {
 
 	// PayloadBufferSize is the buffer size for streaming payloads (bytes).
 	PayloadBufferSize int
+
+	// VerifyAfterPush enables calling Delivery.Verify after a successful push.
+	// It is off by default: devices whose delivery cannot verify them (e.g.,
+	// no version endpoint) would fail once the retries run out.
+	VerifyAfterPush bool
+
+	// VerifyTimeout bounds the whole verification stage for a device,
+	// including retries (0 means no limit beyond the update context).
+	VerifyTimeout time.Duration
+
+	// VerifyRetries is the number of additional Verify attempts after a failure.
+	VerifyRetries int
+
+	// VerifyPollInterval is the delay between Verify attempts, giving devices
+	// that reboot to apply the update time to come back online.
+	VerifyPollInterval time.Duration
+
+	// Keyring holds the keys trusted to sign update manifests. If set, only
+	// updates with a manifest signed by one of them are executed.
+	Keyring *manifest.Keyring
+
+	// Deltas provides delta payloads. If set, devices whose firmware
+	// version is known are sent a patch from that version when one is
+	// available, and the full payload otherwise.
+	Deltas delta.Source
 }
 
 // DefaultConfig returns a configuration with sensible defaults.
 func DefaultConfig() *Config {
 	return &Config{
-		MaxConcurrent:     100,
-		RetryAttempts:     3,
-		EventBufferSize:   1000,
-		PayloadBufferSize: 1024 * 1024, // 1MB
+		MaxConcurrent:      100,
+		RetryAttempts:      3,
+		EventBufferSize:    1000,
+		PayloadBufferSize:  1024 * 1024, // 1MB
+		VerifyTimeout:      5 * time.Minute,
+		VerifyRetries:      5,
+		VerifyPollInterval: 10 * time.Second,
 	}
 }
 
@@ -41,5 +75,14 @@ func (c *Config) Validate() error {
 	if c.PayloadBufferSize < 1024 {
 		return errors.New("PayloadBufferSize must be at least 1024 bytes")
 	}
+	if c.VerifyTimeout < 0 {
+		return errors.New("VerifyTimeout cannot be negative")
+	}
+	if c.VerifyRetries < 0 {
+		return errors.New("VerifyRetries cannot be negative")
+	}
+	if c.VerifyPollInterval < 0 {
+		return errors.New("VerifyPollInterval cannot be negative")
+	}
 	return nil
 }
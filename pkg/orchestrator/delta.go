@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+)
+
+// pushUpdate pushes an update to a device. If Config.Deltas has a patch from
+// the firmware version the device runs, the patch is pushed instead of the
+// full payload, which is still pushed if there is no patch or the device
+// rejects it. It returns the base version of the delta the device received,
+// or "" for the full payload or a failed push.
+func (o *Orchestrator) pushUpdate(ctx context.Context, update core.Update, device core.Device, shared *payload.Shared) (string, error) {
+	if patch := o.deltaPayload(ctx, update, device, shared); patch != nil {
+		err := o.push(o.deltaContext(ctx, update, device, patch), update, device, patch)
+		if err == nil {
+			return device.FirmwareVersion, nil
+		}
+		if !errors.Is(err, delivery.ErrDeltaRejected) || ctx.Err() != nil {
+			return "", err
+		}
+	}
+	return "", o.push(ctx, update, device, shared)
+}
+
+// push streams a payload to a device from its own reader, recording the
+// bytes the delivery reports. The delivery seeks the reader if it retries.
+func (o *Orchestrator) push(ctx context.Context, update core.Update, device core.Device, shared *payload.Shared) error {
+	report, stopReports := o.reportBytes(ctx, update, device, shared.Size())
+	defer stopReports()
+
+	return o.delivery.Push(delivery.WithProgress(ctx, report), device, shared.Reader())
+}
+
+// deltaPayload returns the patch from the device's firmware version to the
+// update's image, or nil if the full payload should be pushed. Failing to
+// get a patch is not an error: the full payload always works.
+func (o *Orchestrator) deltaPayload(ctx context.Context, update core.Update, device core.Device, shared *payload.Shared) *payload.Shared {
+	if o.config.Deltas == nil || update.TargetVersion == "" ||
+		device.FirmwareVersion == "" || device.FirmwareVersion == update.TargetVersion {
+		return nil
+	}
+
+	patch, err := o.config.Deltas.Delta(ctx, device.FirmwareVersion, shared)
+	if err != nil {
+		return nil
+	}
+	return payload.NewShared(bytes.NewReader(patch), int64(len(patch)))
+}
+
+// deltaContext returns the push context for a patch: the payload digest
+// describes the patch, and the delta description the image it produces.
+func (o *Orchestrator) deltaContext(ctx context.Context, update core.Update, device core.Device, patch *payload.Shared) context.Context {
+	target, _ := delivery.PayloadDigest(ctx)
+
+	// Patches are in memory, so hashing them cannot fail
+	sum, _ := patch.SHA256()
+	ctx = delivery.WithPayloadDigest(ctx, delivery.Digest{SHA256: sum, Size: patch.Size()})
+	return delivery.WithDelta(ctx, delivery.DeltaPayload{
+		BaseVersion:   device.FirmwareVersion,
+		TargetVersion: update.TargetVersion,
+		Target:        target,
+	})
+}
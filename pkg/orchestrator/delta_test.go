@@ -0,0 +1,132 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delta"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+// setupDeltaOrchestrator creates an orchestrator with a delta library holding
+// the image of version 1.0.0, and devices on 1.0.0, on a version the library
+// does not know, and on an unknown version. It returns the 2.0.0 image.
+func setupDeltaOrchestrator(t *testing.T) (*Orchestrator, *mocks.MockDelivery, []byte) {
+	t.Helper()
+
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 32*1024)
+	rng.Read(base)
+	image := append([]byte(nil), base...)
+	copy(image[1000:], "firmware 2.0.0")
+
+	library := delta.NewLibrary()
+	library.AddBase("1.0.0", payload.NewShared(bytes.NewReader(base), int64(len(base))))
+
+	ctx := context.Background()
+	registry := memory.New()
+	for id, version := range map[string]string{"device-1": "1.0.0", "device-2": "0.9.0", "device-3": ""} {
+		registry.Add(ctx, core.Device{ID: id, Status: core.DeviceOnline, FirmwareVersion: version})
+	}
+
+	delivery := mocks.NewMockDelivery()
+	config := DefaultConfig()
+	config.VerifyAfterPush = false
+	config.Deltas = library
+
+	orch, err := NewDefault(config, registry, delivery)
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	return orch, delivery, image
+}
+
+func TestOrchestrator_PushesDeltaFromDeviceVersion(t *testing.T) {
+	orch, delivery, image := setupDeltaOrchestrator(t)
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", TargetVersion: "2.0.0"}
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, bytes.NewReader(image)); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	// Only the device on a known base gets a delta; the others get the image
+	if delivery.GetPushCount() != 3 || delivery.GetDeltaCount() != 1 {
+		t.Errorf("Expected 3 pushes with 1 delta, got %d pushes with %d deltas", delivery.GetPushCount(), delivery.GetDeltaCount())
+	}
+
+	prog, err := orch.progress.GetProgress(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if sent := prog.DeviceProgress["device-1"].BytesTransferred; sent <= 0 || sent >= int64(len(image))/10 {
+		t.Errorf("Expected a small delta to be sent to device-1, got %d bytes", sent)
+	}
+	if sent := prog.DeviceProgress["device-2"].BytesTransferred; sent != int64(len(image)) {
+		t.Errorf("Expected the full image to be sent to device-2, got %d bytes", sent)
+	}
+	if prog.CompletedDevices != 3 {
+		t.Errorf("Expected 3 completed devices, got %d", prog.CompletedDevices)
+	}
+}
+
+func TestOrchestrator_FallsBackWhenDeltaRejected(t *testing.T) {
+	orch, delivery, image := setupDeltaOrchestrator(t)
+	delivery.RejectDeltas = true
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", TargetVersion: "2.0.0", DeviceIDs: []string{"device-1"}}
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, bytes.NewReader(image)); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	// The rejected delta is followed by the full image
+	if delivery.GetPushCount() != 2 || delivery.GetDeltaCount() != 0 {
+		t.Errorf("Expected a delta and a full push, got %d pushes with %d deltas", delivery.GetPushCount(), delivery.GetDeltaCount())
+	}
+
+	status, err := orch.GetStatus(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Status != core.StatusCompleted {
+		t.Errorf("Expected status %s, got %s", core.StatusCompleted, status.Status)
+	}
+}
+
+func TestOrchestrator_FailedDeltaHasNoBase(t *testing.T) {
+	orch, delivery, image := setupDeltaOrchestrator(t)
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", TargetVersion: "2.0.0"}
+	device := core.Device{ID: "device-1", FirmwareVersion: "1.0.0"}
+	shared := payload.NewShared(bytes.NewReader(image), int64(len(image)))
+
+	base, err := orch.pushUpdate(ctx, update, device, shared)
+	if err != nil || base != "1.0.0" {
+		t.Fatalf("Expected a delta from 1.0.0, got %q (%v)", base, err)
+	}
+
+	// A delta the device never received has no base
+	delivery.FailDevices = map[string]bool{"device-1": true}
+	base, err = orch.pushUpdate(ctx, update, device, shared)
+	if err == nil || base != "" {
+		t.Errorf("Expected a failed push without a base, got %q (%v)", base, err)
+	}
+}
+
+func TestOrchestrator_NoDeltaWithoutTargetVersion(t *testing.T) {
+	orch, delivery, image := setupDeltaOrchestrator(t)
+
+	if err := orch.ExecuteUpdateWithPayload(context.Background(), core.Update{ID: "update-1"}, bytes.NewReader(image)); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+	if delivery.GetDeltaCount() != 0 {
+		t.Errorf("Expected full pushes only, got %d deltas", delivery.GetDeltaCount())
+	}
+}
@@ -2,13 +2,18 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/dovaclean/go-update-orchestrator/internal/pool"
+	"github.com/dovaclean/go-update-orchestrator/internal/retry"
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
 	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
 	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
 	"github.com/dovaclean/go-update-orchestrator/pkg/progress"
 	"github.com/dovaclean/go-update-orchestrator/pkg/progress/memory"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry"
@@ -31,6 +36,7 @@ func NewWithTracker(
 		delivery: del,
 		events:   events.NewBus(config.EventBufferSize),
 		progress: tracker,
+		running:  make(map[string]*runningUpdate),
 	}, nil
 }
 
@@ -44,16 +50,30 @@ func NewDefault(
 }
 
 // ExecuteUpdateWithPayload executes an update with a provided payload reader.
-func (o *Orchestrator) ExecuteUpdateWithPayload(ctx context.Context, update core.Update, payload io.ReadSeeker) error {
+// Each device reads the payload independently from its own offset.
+func (o *Orchestrator) ExecuteUpdateWithPayload(ctx context.Context, update core.Update, r io.ReadSeeker) error {
 	// 1. Validate update
 	if update.ID == "" {
 		return fmt.Errorf("update ID is required")
 	}
 
+	update, err := o.VerifyManifest(update)
+	if err != nil {
+		return err
+	}
+
+	shared, err := payload.NewSharedFromReadSeeker(r)
+	if err != nil {
+		return err
+	}
+	if err := shared.Verify(update); err != nil {
+		return err
+	}
+
 	// 2. Fetch devices from registry
-	devices, err := o.registry.List(ctx, *update.DeviceFilter)
+	devices, err := o.ResolveDevices(ctx, update)
 	if err != nil {
-		return fmt.Errorf("failed to list devices: %w", err)
+		return err
 	}
 
 	if len(devices) == 0 {
@@ -61,7 +81,64 @@ func (o *Orchestrator) ExecuteUpdateWithPayload(ctx context.Context, update core
 	}
 
 	// 3. Start progress tracking
-	o.progress.Start(ctx, update.ID, len(devices))
+	runCtx, err := o.BeginUpdate(ctx, update, devices)
+	if err != nil {
+		return err
+	}
+
+	// 4. Push to all devices
+	execErr := o.ExecuteOnDevices(runCtx, update, devices, shared)
+
+	// 5. Mark update as complete (or cancelled)
+	o.FinishUpdate(ctx, update)
+
+	return execErr
+}
+
+// ResolveDevices returns the devices targeted by an update.
+// Explicit DeviceIDs take precedence over DeviceFilter; if neither is set,
+// all devices in the registry are targeted. Devices whose hardware model the
+// update manifest does not support are left out.
+func (o *Orchestrator) ResolveDevices(ctx context.Context, update core.Update) ([]core.Device, error) {
+	filter := core.Filter{}
+	if len(update.DeviceIDs) > 0 {
+		filter.IDs = update.DeviceIDs
+	} else if update.DeviceFilter != nil {
+		filter = *update.DeviceFilter
+	}
+
+	devices, err := o.registry.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	return compatibleDevices(update, devices)
+}
+
+// BeginUpdate registers an update as running, starts progress tracking and
+// emits the started event. The returned context is cancelled by Cancel and
+// must be passed to ExecuteOnDevices. Use it together with ExecuteOnDevices
+// and FinishUpdate when an update is delivered in several batches
+// (e.g., progressive rollout phases).
+func (o *Orchestrator) BeginUpdate(ctx context.Context, update core.Update, devices []core.Device) (context.Context, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+
+	o.mu.Lock()
+	if _, exists := o.running[update.ID]; exists {
+		o.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("%w: %s", core.ErrUpdateInProgress, update.ID)
+	}
+	o.running[update.ID] = &runningUpdate{
+		ctx:     runCtx,
+		cancel:  cancel,
+		devices: devices,
+		started: make(map[string]bool, len(devices)),
+	}
+	o.mu.Unlock()
+
+	totalDevices := len(devices)
+	o.progress.Start(ctx, update.ID, totalDevices)
 
 	// Emit update started event
 	o.events.Publish(ctx, events.Event{
@@ -70,48 +147,251 @@ func (o *Orchestrator) ExecuteUpdateWithPayload(ctx context.Context, update core
 		DeviceID:  "",
 		Timestamp: update.CreatedAt,
 		Data: map[string]interface{}{
-			"total_devices": len(devices),
+			"total_devices": totalDevices,
 			"strategy":      update.Strategy,
 		},
 	})
 
-	// 4. Create worker pool
+	return runCtx, nil
+}
+
+// ExecuteOnDevices pushes the payload to the given devices and waits for all
+// of them to finish. ctx must be the context returned by BeginUpdate.
+// Every push streams from its own reader over the shared payload, which
+// must match the digest and size the update expects.
+// Per-device failures are recorded in the progress tracker, not returned;
+// core.ErrCancelled is returned if the update was cancelled.
+func (o *Orchestrator) ExecuteOnDevices(ctx context.Context, update core.Update, devices []core.Device, shared *payload.Shared) error {
+	if err := shared.Verify(update); err != nil {
+		return err
+	}
+
+	// Let deliveries forward the digest so devices can check what they received
+	sum, err := shared.SHA256()
+	if err != nil {
+		return err
+	}
+	ctx = delivery.WithPayloadDigest(ctx, delivery.Digest{SHA256: sum, Size: shared.Size()})
+	if len(update.Manifest) > 0 {
+		ctx = delivery.WithManifest(ctx, delivery.SignedManifest{
+			Manifest:  update.Manifest,
+			Signature: update.ManifestSignature,
+		})
+	}
+
+	// Create worker pool
 	workerPool := pool.New(o.config.MaxConcurrent)
 	workerPool.Start(ctx)
-	defer workerPool.Stop()
 
-	// 5. Submit device update tasks
+	// Submit device update tasks, stopping as soon as the update is cancelled
 	for _, device := range devices {
+		if ctx.Err() != nil {
+			break
+		}
 		device := device // Capture for closure
 		workerPool.Submit(func(ctx context.Context) error {
-			return o.updateDevice(ctx, update, device, payload)
+			return o.updateDevice(ctx, update, device, shared)
 		})
 	}
 
-	// 6. Wait for all tasks to complete (handled by pool.Stop())
-	// Note: Stop() is called via defer above
+	// Wait for all tasks to complete
+	workerPool.Stop()
+
+	if ctx.Err() != nil {
+		return core.ErrCancelled
+	}
+	return nil
+}
+
+// RecheckDevices verifies again the given devices that completed an update,
+// marking those that no longer pass as failed. It is used to observe device
+// health for a while after a rollout phase. ctx must be the context returned
+// by BeginUpdate.
+func (o *Orchestrator) RecheckDevices(ctx context.Context, update core.Update, devices []core.Device) error {
+	prog, err := o.progress.GetProgress(ctx, update.ID)
+	if err != nil {
+		return err
+	}
+
+	workerPool := pool.New(o.config.MaxConcurrent)
+	workerPool.Start(ctx)
+
+	for _, device := range devices {
+		if ctx.Err() != nil {
+			break
+		}
+		if prog.DeviceProgress[device.ID].Status != core.StatusCompleted {
+			continue
+		}
+		device := device // Capture for closure
+		workerPool.Submit(func(ctx context.Context) error {
+			err := o.verifyDevice(ctx, update, device)
+			if err != nil && ctx.Err() == nil {
+				o.handleDeviceFailure(ctx, update, device, err)
+				return err
+			}
+			// Passed, or interrupted by cancellation: the update still stands
+			o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusCompleted), 0)
+			return err
+		})
+	}
+
+	workerPool.Stop()
+
+	if ctx.Err() != nil {
+		return core.ErrCancelled
+	}
+	return nil
+}
+
+// RestoreOutcomes records device outcomes from an earlier, interrupted run of
+// an update (e.g., one recovered after a restart), so the devices count
+// towards the update's status without being pushed again. Call it right
+// after BeginUpdate.
+func (o *Orchestrator) RestoreOutcomes(ctx context.Context, updateID string, outcomes map[string]core.UpdateStatus) error {
+	run := o.runningUpdate(updateID)
+	if run == nil {
+		return fmt.Errorf("%w: %s is not running", core.ErrUpdateNotFound, updateID)
+	}
+
+	run.mu.Lock()
+	for deviceID := range outcomes {
+		run.started[deviceID] = true
+	}
+	run.mu.Unlock()
+
+	for deviceID, status := range outcomes {
+		o.progress.UpdateDevice(ctx, updateID, deviceID, string(status), 0)
+	}
+	return nil
+}
+
+// DeviceGate decides, right before a device push starts, whether the device
+// may be updated now.
+type DeviceGate func(device core.Device) bool
+
+// deviceGateKey is the context key for the device gate.
+type deviceGateKey struct{}
+
+// WithDeviceGate returns a context for ExecuteOnDevices that consults gate
+// before each device push. Devices the gate rejects are neither started nor
+// failed, so they can be executed again later.
+func WithDeviceGate(ctx context.Context, gate DeviceGate) context.Context {
+	return context.WithValue(ctx, deviceGateKey{}, gate)
+}
+
+// DeviceOutcomeFunc is told the outcome of a device update: completed, or
+// failed with err.
+type DeviceOutcomeFunc func(device core.Device, status core.UpdateStatus, err error)
+
+// deviceOutcomeKey is the context key for the device outcome function.
+type deviceOutcomeKey struct{}
+
+// WithDeviceOutcome returns a context for ExecuteOnDevices and RecheckDevices
+// that calls record with each device outcome as it is reached, before the
+// corresponding event is published. Unlike event handlers, record runs
+// synchronously, so outcomes for a device arrive in order.
+func WithDeviceOutcome(ctx context.Context, record DeviceOutcomeFunc) context.Context {
+	return context.WithValue(ctx, deviceOutcomeKey{}, record)
+}
+
+// recordOutcome passes a device outcome to the caller's DeviceOutcomeFunc,
+// if any.
+func recordOutcome(ctx context.Context, device core.Device, status core.UpdateStatus, err error) {
+	if record, ok := ctx.Value(deviceOutcomeKey{}).(DeviceOutcomeFunc); ok {
+		record(device, status, err)
+	}
+}
+
+// FinishUpdate marks an update as complete and emits the completed event.
+// If the update was cancelled, devices that were never started are marked
+// cancelled and EventUpdateCancelled is emitted instead.
+func (o *Orchestrator) FinishUpdate(ctx context.Context, update core.Update) {
+	o.mu.Lock()
+	run := o.running[update.ID]
+	delete(o.running, update.ID)
+	o.mu.Unlock()
+
+	if run != nil {
+		cancelled := run.ctx.Err() != nil
+		run.cancel() // Release context resources
+		if cancelled {
+			o.finishCancelled(ctx, update, run)
+			return
+		}
+	}
 
-	// 7. Mark update as complete
 	o.progress.Complete(ctx, update.ID)
 
 	// Emit update completed event
 	prog, _ := o.progress.GetProgress(ctx, update.ID)
+	data := map[string]interface{}{}
+	if prog != nil {
+		data["completed"] = prog.CompletedDevices
+		data["failed"] = prog.FailedDevices
+	}
 	o.events.Publish(ctx, events.Event{
 		Type:      events.EventUpdateCompleted,
 		UpdateID:  update.ID,
 		DeviceID:  "",
 		Timestamp: update.CreatedAt,
-		Data: map[string]interface{}{
-			"completed": prog.CompletedDevices,
-			"failed":    prog.FailedDevices,
-		},
+		Data:      data,
 	})
+}
 
-	return nil
+// finishCancelled marks untouched devices as cancelled and emits the cancelled event.
+func (o *Orchestrator) finishCancelled(ctx context.Context, update core.Update, run *runningUpdate) {
+	run.mu.Lock()
+	skipped := 0
+	for _, device := range run.devices {
+		if !run.started[device.ID] {
+			o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusCancelled), 0)
+			skipped++
+		}
+	}
+	started := len(run.started)
+	run.mu.Unlock()
+
+	o.progress.Complete(ctx, update.ID)
+
+	data := map[string]interface{}{
+		"skipped": skipped,
+	}
+	if prog, err := o.progress.GetProgress(ctx, update.ID); err == nil {
+		// Started devices that neither completed nor failed were aborted mid-push
+		data["completed"] = prog.CompletedDevices
+		data["failed"] = prog.FailedDevices
+		data["aborted"] = started - prog.CompletedDevices - prog.FailedDevices
+	}
+
+	o.events.Publish(ctx, events.Event{
+		Type:      events.EventUpdateCancelled,
+		UpdateID:  update.ID,
+		DeviceID:  "",
+		Timestamp: time.Now(),
+		Data:      data,
+		Error:     core.ErrCancelled,
+	})
 }
 
 // updateDevice handles the update for a single device.
-func (o *Orchestrator) updateDevice(ctx context.Context, update core.Update, device core.Device, payload io.ReadSeeker) error {
+func (o *Orchestrator) updateDevice(ctx context.Context, update core.Update, device core.Device, shared *payload.Shared) error {
+	// Hold devices while the update is paused, and skip devices that were
+	// queued before the update was cancelled
+	run := o.runningUpdate(update.ID)
+	if run != nil && !run.markStarted(device.ID) {
+		return ctx.Err()
+	}
+
+	// Leave devices the caller's gate rejects untouched so they can be
+	// executed later (e.g., once their update window opens)
+	if gate, ok := ctx.Value(deviceGateKey{}).(DeviceGate); ok && !gate(device) {
+		if run != nil {
+			run.unmarkStarted(device.ID)
+		}
+		return nil
+	}
+
 	// Mark device as in progress
 	o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusInProgress), 0)
 
@@ -126,36 +406,205 @@ func (o *Orchestrator) updateDevice(ctx context.Context, update core.Update, dev
 		},
 	})
 
-	// Push update to device
-	// Note: The delivery mechanism will handle seeking if retries are needed
-	err := o.delivery.Push(ctx, device, payload)
+	// Push update to device, as a delta from its firmware if possible
+	deltaBase, err := o.pushUpdate(ctx, update, device, shared)
+
+	// Check the device actually applied the update
+	if err == nil && o.config.VerifyAfterPush {
+		err = o.verifyDevice(ctx, update, device)
+	}
 
 	if err != nil {
+		// A push interrupted by cancellation is aborted, not failed
+		if ctx.Err() != nil {
+			o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusCancelled), 0)
+			return ctx.Err()
+		}
 		o.handleDeviceFailure(ctx, update, device, err)
 		return err
 	}
 
 	// Mark device as completed
 	o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusCompleted), 0)
+	recordOutcome(ctx, device, core.StatusCompleted, nil)
 
 	// Emit device completed event
+	data := map[string]interface{}{
+		"success": true,
+	}
+	if deltaBase != "" {
+		data["delta_base"] = deltaBase
+	}
 	o.events.Publish(ctx, events.Event{
 		Type:      events.EventDeviceCompleted,
 		UpdateID:  update.ID,
 		DeviceID:  device.ID,
 		Timestamp: update.CreatedAt,
-		Data: map[string]interface{}{
-			"success": true,
-		},
+		Data:      data,
 	})
 
 	return nil
 }
 
+// reportBytes returns a delivery.ProgressFunc that records the payload bytes
+// sent to a device and publishes them as progress events, and a function
+// that stops the reports once the push has returned. total is the payload
+// size, or 0 if unknown.
+func (o *Orchestrator) reportBytes(ctx context.Context, update core.Update, device core.Device, total int64) (delivery.ProgressFunc, func()) {
+	var mu sync.Mutex
+	var last int64
+	stopped := false
+
+	report := func(sent int64) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// A retried push restarts the count at the offset it resumes from,
+		// so bytes are only counted again once they are sent again
+		delta := sent - last
+		last = sent
+		if stopped || delta <= 0 {
+			return
+		}
+
+		o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusInProgress), delta)
+
+		data := map[string]interface{}{
+			"bytes_sent": sent,
+		}
+		if total > 0 {
+			data["total_bytes"] = total
+			data["percent"] = sent * 100 / total
+		}
+		o.events.Publish(ctx, events.Event{
+			Type:      events.EventProgressUpdate,
+			UpdateID:  update.ID,
+			DeviceID:  device.ID,
+			Timestamp: time.Now(),
+			Data:      data,
+		})
+	}
+
+	stop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+	}
+
+	return report, stop
+}
+
+// verifyDevice runs the post-push verification stage for a device.
+// Verify is polled until it succeeds, the retries are exhausted or
+// VerifyTimeout elapses; failures are wrapped in core.ErrVerificationFailed.
+func (o *Orchestrator) verifyDevice(ctx context.Context, update core.Update, device core.Device) error {
+	o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusVerifying), 0)
+
+	verifyCtx := ctx
+	if update.TargetVersion != "" {
+		verifyCtx = delivery.WithTargetVersion(verifyCtx, update.TargetVersion)
+	}
+	if o.config.VerifyTimeout > 0 {
+		var cancel context.CancelFunc
+		verifyCtx, cancel = context.WithTimeout(verifyCtx, o.config.VerifyTimeout)
+		defer cancel()
+	}
+
+	// Poll at a constant interval rather than backing off exponentially
+	retryConfig := &retry.Config{
+		MaxAttempts:  o.config.VerifyRetries + 1,
+		InitialDelay: o.config.VerifyPollInterval,
+		MaxDelay:     o.config.VerifyPollInterval,
+		Multiplier:   1.0,
+	}
+
+	attempts := 0
+	err := retry.Do(verifyCtx, retryConfig, func() error {
+		attempts++
+		return o.delivery.Verify(verifyCtx, device)
+	})
+
+	// Cancellation of the update is not a verification failure
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+
+	if err != nil {
+		if !errors.Is(err, core.ErrVerificationFailed) {
+			err = fmt.Errorf("%w: %w", core.ErrVerificationFailed, err)
+		}
+
+		data := map[string]interface{}{
+			"attempts": attempts,
+			"error":    err.Error(),
+		}
+
+		// Record what the device is actually running
+		var mismatch *delivery.VersionMismatchError
+		if errors.As(err, &mismatch) {
+			data["expected_version"] = mismatch.Expected
+			data["reported_version"] = mismatch.Reported
+			o.recordFirmwareVersion(ctx, device.ID, mismatch.Reported)
+		}
+
+		o.events.Publish(ctx, events.Event{
+			Type:      events.EventDeviceVerificationFailed,
+			UpdateID:  update.ID,
+			DeviceID:  device.ID,
+			Timestamp: time.Now(),
+			Data:      data,
+			Error:     err,
+		})
+		return err
+	}
+
+	data := map[string]interface{}{
+		"attempts": attempts,
+	}
+	if update.TargetVersion != "" {
+		data["reported_version"] = update.TargetVersion
+		o.recordFirmwareVersion(ctx, device.ID, update.TargetVersion)
+	}
+
+	o.events.Publish(ctx, events.Event{
+		Type:      events.EventDeviceVerified,
+		UpdateID:  update.ID,
+		DeviceID:  device.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+
+	return nil
+}
+
+// recordFirmwareVersion stores the firmware version a device reported in the registry.
+// Registry errors are ignored: the update outcome does not depend on them.
+func (o *Orchestrator) recordFirmwareVersion(ctx context.Context, deviceID, version string) {
+	device, err := o.registry.Get(ctx, deviceID)
+	if err != nil || device.FirmwareVersion == version {
+		return
+	}
+
+	device.FirmwareVersion = version
+	o.registry.Update(ctx, *device)
+}
+
+// runningUpdate returns the in-flight state for an update, if any.
+func (o *Orchestrator) runningUpdate(updateID string) *runningUpdate {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.running[updateID]
+}
+
 // handleDeviceFailure handles a failed device update.
 func (o *Orchestrator) handleDeviceFailure(ctx context.Context, update core.Update, device core.Device, err error) {
 	// Mark device as failed
-	o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusFailed), 0)
+	if tracker, ok := o.progress.(progress.FailureTracker); ok {
+		tracker.FailDevice(ctx, update.ID, device.ID, err)
+	} else {
+		o.progress.UpdateDevice(ctx, update.ID, device.ID, string(core.StatusFailed), 0)
+	}
+	recordOutcome(ctx, device, core.StatusFailed, err)
 
 	// Emit device failed event
 	o.events.Publish(ctx, events.Event{
@@ -179,28 +628,39 @@ func (o *Orchestrator) GetStatus(ctx context.Context, updateID string) (*core.St
 
 	// Convert progress to status
 	status := &core.Status{
-		UpdateID:     prog.UpdateID,
-		TotalDevices: prog.TotalDevices,
-		Completed:    prog.CompletedDevices,
-		Failed:       prog.FailedDevices,
-		InProgress:   prog.InProgressDevices,
-		StartedAt:    prog.StartTime,
-		EstimatedEnd: prog.EstimatedEnd,
-		DeviceStatus: make(map[string]string),
+		UpdateID:          prog.UpdateID,
+		TotalDevices:      prog.TotalDevices,
+		Completed:         prog.CompletedDevices,
+		Failed:            prog.FailedDevices,
+		InProgress:        prog.InProgressDevices,
+		StartedAt:         prog.StartTime,
+		EstimatedEnd:      prog.EstimatedEnd,
+		EstimatedEarliest: prog.EstimatedEarliest,
+		EstimatedLatest:   prog.EstimatedLatest,
+		Throughput:        prog.Throughput,
+		DeviceStatus:      make(map[string]string),
 	}
 
 	// Convert device progress to device status map
+	cancelled := 0
 	for deviceID, deviceProg := range prog.DeviceProgress {
 		status.DeviceStatus[deviceID] = string(deviceProg.Status)
+		if deviceProg.Status == core.StatusCancelled {
+			cancelled++
+		}
 	}
 
 	// Determine overall status
-	if prog.CompletedDevices+prog.FailedDevices == prog.TotalDevices {
-		if prog.FailedDevices > 0 {
+	if prog.CompletedDevices+prog.FailedDevices+cancelled == prog.TotalDevices {
+		if cancelled > 0 {
+			status.Status = core.StatusCancelled
+		} else if prog.FailedDevices > 0 {
 			status.Status = core.StatusFailed
 		} else {
 			status.Status = core.StatusCompleted
 		}
+	} else if o.IsPaused(updateID) {
+		status.Status = core.StatusPaused
 	} else {
 		status.Status = core.StatusInProgress
 	}
@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/manifest"
+)
+
+// VerifyManifest checks the signed manifest of an update against the
+// configured keyring and returns the update with the payload digest, size
+// and target version the manifest specifies, so that staging checks the
+// payload against them. Updates are returned unchanged if no keyring is
+// configured. Errors match core.ErrInvalidManifest.
+func (o *Orchestrator) VerifyManifest(update core.Update) (core.Update, error) {
+	if o.config.Keyring == nil {
+		return update, nil
+	}
+	if len(update.Manifest) == 0 {
+		return update, fmt.Errorf("%w: update %s is not signed", core.ErrInvalidManifest, update.ID)
+	}
+
+	m, err := o.config.Keyring.Verify(update.Manifest, update.ManifestSignature)
+	if err != nil {
+		return update, err
+	}
+	if m.Expired(time.Now()) {
+		return update, fmt.Errorf("%w: manifest expired at %s", core.ErrInvalidManifest, m.ExpiresAt.Format(time.RFC3339))
+	}
+
+	// The update may only repeat what the manifest says
+	if update.PayloadSHA256 != "" && update.PayloadSHA256 != m.PayloadSHA256 {
+		return update, fmt.Errorf("%w: update payload digest %s differs from manifest %s", core.ErrInvalidManifest, update.PayloadSHA256, m.PayloadSHA256)
+	}
+	if update.PayloadSize > 0 && m.PayloadSize > 0 && update.PayloadSize != m.PayloadSize {
+		return update, fmt.Errorf("%w: update payload size %d differs from manifest %d", core.ErrInvalidManifest, update.PayloadSize, m.PayloadSize)
+	}
+	if update.TargetVersion != "" && m.TargetVersion != "" && update.TargetVersion != m.TargetVersion {
+		return update, fmt.Errorf("%w: update target version %s differs from manifest %s", core.ErrInvalidManifest, update.TargetVersion, m.TargetVersion)
+	}
+
+	update.PayloadSHA256 = m.PayloadSHA256
+	if m.PayloadSize > 0 {
+		update.PayloadSize = m.PayloadSize
+	}
+	if m.TargetVersion != "" {
+		update.TargetVersion = m.TargetVersion
+	}
+	return update, nil
+}
+
+// compatibleDevices drops the devices whose hardware model (see
+// core.MetadataHardwareModel) the update manifest does not support.
+func compatibleDevices(update core.Update, devices []core.Device) ([]core.Device, error) {
+	if len(update.Manifest) == 0 {
+		return devices, nil
+	}
+
+	m, err := manifest.Parse(update.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.HardwareModels) == 0 {
+		return devices, nil
+	}
+
+	compatible := make([]core.Device, 0, len(devices))
+	for _, device := range devices {
+		if m.Supports(device.Metadata[core.MetadataHardwareModel]) {
+			compatible = append(compatible, device)
+		}
+	}
+	return compatible, nil
+}
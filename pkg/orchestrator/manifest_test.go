@@ -0,0 +1,154 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/manifest"
+	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+const testFirmware = "firmware"
+
+// signedUpdate returns an update for testFirmware signed by key.
+func signedUpdate(t *testing.T, key ed25519.PrivateKey, edit func(m *manifest.Manifest)) core.Update {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(testFirmware))
+	m := &manifest.Manifest{
+		PayloadSHA256: hex.EncodeToString(sum[:]),
+		PayloadSize:   int64(len(testFirmware)),
+		TargetVersion: "2.0.0",
+		ExpiresAt:     time.Now().Add(time.Hour),
+	}
+	if edit != nil {
+		edit(m)
+	}
+
+	data, sig, err := manifest.Sign(m, key)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return core.Update{ID: "update-1", Manifest: data, ManifestSignature: sig}
+}
+
+// setupSigningOrchestrator creates an orchestrator that trusts the returned
+// key, with devices of the given hardware models.
+func setupSigningOrchestrator(t *testing.T, models ...string) (*Orchestrator, *mocks.MockDelivery, ed25519.PrivateKey) {
+	t.Helper()
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+
+	ctx := context.Background()
+	registry := memory.New()
+	for i, model := range models {
+		registry.Add(ctx, core.Device{
+			ID:       fmt.Sprintf("device-%d", i+1),
+			Status:   core.DeviceOnline,
+			Metadata: map[string]string{core.MetadataHardwareModel: model},
+		})
+	}
+
+	delivery := mocks.NewMockDelivery()
+	config := DefaultConfig()
+	config.VerifyAfterPush = false
+	config.Keyring = manifest.NewKeyring()
+	config.Keyring.Add("release", pub)
+
+	orch, err := NewDefault(config, registry, delivery)
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	return orch, delivery, priv
+}
+
+func TestOrchestrator_VerifyManifest(t *testing.T) {
+	orch, _, key := setupSigningOrchestrator(t)
+
+	update, err := orch.VerifyManifest(signedUpdate(t, key, nil))
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+
+	// The manifest fills in what staging checks
+	if update.PayloadSize != int64(len(testFirmware)) || update.TargetVersion != "2.0.0" || update.PayloadSHA256 == "" {
+		t.Errorf("Expected manifest fields on the update, got %+v", update)
+	}
+}
+
+func TestOrchestrator_VerifyManifestRejects(t *testing.T) {
+	orch, _, key := setupSigningOrchestrator(t)
+	_, untrusted, _ := ed25519.GenerateKey(nil)
+
+	expired := signedUpdate(t, key, func(m *manifest.Manifest) {
+		m.ExpiresAt = time.Now().Add(-time.Minute)
+	})
+	conflicting := signedUpdate(t, key, nil)
+	conflicting.TargetVersion = "3.0.0"
+	tampered := signedUpdate(t, key, nil)
+	tampered.Manifest = []byte(strings.Replace(string(tampered.Manifest), "2.0.0", "2.0.1", 1))
+
+	tests := []struct {
+		name   string
+		update core.Update
+	}{
+		{"unsigned", core.Update{ID: "update-1"}},
+		{"untrusted key", signedUpdate(t, untrusted, nil)},
+		{"tampered", tampered},
+		{"expired", expired},
+		{"conflicting target version", conflicting},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := orch.VerifyManifest(tt.update); !errors.Is(err, core.ErrInvalidManifest) {
+				t.Errorf("Expected ErrInvalidManifest, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteSignedUpdate(t *testing.T) {
+	orch, delivery, key := setupSigningOrchestrator(t, "pos-100", "pos-200", "kiosk-1")
+	ctx := context.Background()
+
+	update := signedUpdate(t, key, func(m *manifest.Manifest) {
+		m.HardwareModels = []string{"pos-100", "pos-200"}
+	})
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, strings.NewReader(testFirmware)); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	// The kiosk is not a supported model
+	status, err := orch.GetStatus(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.TotalDevices != 2 || delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 compatible devices pushed, got %d targeted and %d pushes", status.TotalDevices, delivery.GetPushCount())
+	}
+	if _, ok := status.DeviceStatus["device-3"]; ok {
+		t.Error("Expected the incompatible device to be left out")
+	}
+}
+
+func TestOrchestrator_SignedUpdateWithWrongPayload(t *testing.T) {
+	orch, delivery, key := setupSigningOrchestrator(t, "pos-100")
+
+	err := orch.ExecuteUpdateWithPayload(context.Background(), signedUpdate(t, key, nil), strings.NewReader("malware!"))
+	if !errors.Is(err, core.ErrVerificationFailed) {
+		t.Fatalf("Expected ErrVerificationFailed, got %v", err)
+	}
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes, got %d", delivery.GetPushCount())
+	}
+}
@@ -2,7 +2,11 @@ package orchestrator
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
 	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
 	"github.com/dovaclean/go-update-orchestrator/pkg/events"
 	"github.com/dovaclean/go-update-orchestrator/pkg/progress"
@@ -17,6 +21,78 @@ type Orchestrator struct {
 	events   *events.Bus
 	progress progress.Tracker
 	// TODO: Add scheduler when implemented
+
+	mu      sync.Mutex
+	running map[string]*runningUpdate
+}
+
+// runningUpdate tracks an in-flight update so it can be cancelled.
+type runningUpdate struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	devices []core.Device
+
+	mu       sync.Mutex
+	started  map[string]bool // Devices whose push has begun
+	paused   bool
+	resumeCh chan struct{} // Closed when a paused update is resumed
+}
+
+// markStarted records that a device push has begun, blocking while the
+// update is paused. It returns false if the update has been cancelled.
+func (r *runningUpdate) markStarted(deviceID string) bool {
+	for {
+		r.mu.Lock()
+		if r.ctx.Err() != nil {
+			r.mu.Unlock()
+			return false
+		}
+		if !r.paused {
+			r.started[deviceID] = true
+			r.mu.Unlock()
+			return true
+		}
+		resumeCh := r.resumeCh
+		r.mu.Unlock()
+
+		select {
+		case <-resumeCh:
+		case <-r.ctx.Done():
+			return false
+		}
+	}
+}
+
+// unmarkStarted records that a device push was not started after all.
+func (r *runningUpdate) unmarkStarted(deviceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.started, deviceID)
+}
+
+// setPaused changes the paused state and reports whether it changed.
+func (r *runningUpdate) setPaused(paused bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.paused == paused {
+		return false
+	}
+
+	r.paused = paused
+	if paused {
+		r.resumeCh = make(chan struct{})
+	} else {
+		close(r.resumeCh)
+	}
+	return true
+}
+
+// isPaused reports whether the update is paused.
+func (r *runningUpdate) isPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
 }
 
 // New creates a new orchestrator with the given configuration and components.
@@ -35,6 +111,7 @@ func New(
 		delivery: delivery,
 		events:   events.NewBus(config.EventBufferSize),
 		// TODO: Initialize progress tracker
+		running: make(map[string]*runningUpdate),
 	}, nil
 }
 
@@ -43,8 +120,82 @@ func (o *Orchestrator) Subscribe(eventType events.EventType, handler events.Hand
 	o.events.Subscribe(eventType, handler)
 }
 
+// Publish emits an event to subscribers. Components that drive updates
+// through the orchestrator (such as the scheduler) use it to report their
+// own lifecycle events on the same bus.
+func (o *Orchestrator) Publish(ctx context.Context, event events.Event) {
+	o.events.Publish(ctx, event)
+}
+
 // Cancel attempts to cancel a running update.
-// TODO: Implement cancellation logic
+// No new device pushes are started, in-flight pushes are aborted through their
+// context, and the update is finalized (with an EventUpdateCancelled event)
+// once the in-flight pushes have returned.
 func (o *Orchestrator) Cancel(ctx context.Context, updateID string) error {
+	o.mu.Lock()
+	run, exists := o.running[updateID]
+	o.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s is not running", core.ErrUpdateNotFound, updateID)
+	}
+
+	run.cancel()
+	return nil
+}
+
+// Pause holds a running update. Device pushes already in flight are allowed
+// to finish, but no further devices are started until Resume is called.
+func (o *Orchestrator) Pause(ctx context.Context, updateID string) error {
+	run := o.runningUpdate(updateID)
+	if run == nil {
+		return fmt.Errorf("%w: %s is not running", core.ErrUpdateNotFound, updateID)
+	}
+
+	if !run.setPaused(true) {
+		return fmt.Errorf("update %s is already paused", updateID)
+	}
+
+	o.events.Publish(ctx, events.Event{
+		Type:      events.EventUpdatePaused,
+		UpdateID:  updateID,
+		Timestamp: time.Now(),
+	})
+
+	return nil
+}
+
+// Resume continues a paused update from where it was held.
+func (o *Orchestrator) Resume(ctx context.Context, updateID string) error {
+	run := o.runningUpdate(updateID)
+	if run == nil {
+		return fmt.Errorf("%w: %s is not running", core.ErrUpdateNotFound, updateID)
+	}
+
+	if !run.setPaused(false) {
+		return fmt.Errorf("update %s is not paused", updateID)
+	}
+
+	o.events.Publish(ctx, events.Event{
+		Type:      events.EventUpdateResumed,
+		UpdateID:  updateID,
+		Timestamp: time.Now(),
+	})
+
 	return nil
 }
+
+// IsPaused reports whether a running update is currently paused.
+func (o *Orchestrator) IsPaused(updateID string) bool {
+	run := o.runningUpdate(updateID)
+	return run != nil && run.isPaused()
+}
+
+// IsRunning reports whether an update is currently executing.
+func (o *Orchestrator) IsRunning(updateID string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, exists := o.running[updateID]
+	return exists
+}
@@ -0,0 +1,513 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+func TestOrchestrator_ExecuteUpdateWithPayload(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 5, 2)
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", Name: "Firmware v2.0"}
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	if delivery.GetPushCount() != 5 {
+		t.Errorf("Expected 5 pushes, got %d", delivery.GetPushCount())
+	}
+
+	status, err := orch.GetStatus(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Status != core.StatusCompleted {
+		t.Errorf("Expected status %s, got %s", core.StatusCompleted, status.Status)
+	}
+
+	if orch.IsRunning("update-1") {
+		t.Error("Expected update to be unregistered after completion")
+	}
+}
+
+func TestOrchestrator_ExecuteUpdateWithDeviceIDs(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 5, 2)
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", DeviceIDs: []string{"device-1", "device-3"}}
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestOrchestrator_Cancel(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 10, 2)
+	delivery.PushDelay = 5 * time.Second
+	ctx := context.Background()
+
+	var (
+		mu        sync.Mutex
+		cancelled *events.Event
+	)
+	done := make(chan struct{})
+	orch.Subscribe(events.EventUpdateCancelled, events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		mu.Lock()
+		cancelled = &event
+		mu.Unlock()
+		close(done)
+	}))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware"))
+	}()
+
+	waitForRunning(t, orch, "update-1")
+	time.Sleep(50 * time.Millisecond) // Let the first pushes start
+
+	if err := orch.Cancel(ctx, "update-1"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, core.ErrCancelled) {
+			t.Errorf("Expected ErrCancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Cancel did not abort in-flight pushes")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected update.cancelled event")
+	}
+
+	mu.Lock()
+	data := cancelled.Data
+	mu.Unlock()
+
+	if data["completed"] != 0 {
+		t.Errorf("Expected 0 completed, got %v", data["completed"])
+	}
+	if data["aborted"] != 2 {
+		t.Errorf("Expected 2 aborted (MaxConcurrent), got %v", data["aborted"])
+	}
+	if data["skipped"] != 8 {
+		t.Errorf("Expected 8 skipped, got %v", data["skipped"])
+	}
+
+	status, err := orch.GetStatus(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Status != core.StatusCancelled {
+		t.Errorf("Expected status %s, got %s", core.StatusCancelled, status.Status)
+	}
+	for deviceID, deviceStatus := range status.DeviceStatus {
+		if deviceStatus != string(core.StatusCancelled) {
+			t.Errorf("Expected device %s to be cancelled, got %s", deviceID, deviceStatus)
+		}
+	}
+}
+
+func TestOrchestrator_ReportsBytesTransferred(t *testing.T) {
+	orch, _ := setupTestOrchestrator(t, 3, 3)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	percent := make(map[string]int64)
+	orch.Subscribe(events.EventProgressUpdate, events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Handlers run concurrently, so events may arrive out of order
+		if p := event.Data["percent"].(int64); p > percent[event.DeviceID] {
+			percent[event.DeviceID] = p
+		}
+	}))
+
+	payload := strings.Repeat("A", 64*1024)
+	if err := orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader(payload)); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	prog, err := orch.progress.GetProgress(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	// Every device receives the whole payload from its own reader
+	if prog.BytesTransferred != 3*int64(len(payload)) {
+		t.Errorf("Expected %d bytes transferred, got %d", 3*len(payload), prog.BytesTransferred)
+	}
+	for deviceID, deviceProg := range prog.DeviceProgress {
+		if deviceProg.BytesTransferred != int64(len(payload)) {
+			t.Errorf("Expected %d bytes for %s, got %d", len(payload), deviceID, deviceProg.BytesTransferred)
+		}
+		if deviceProg.Status != core.StatusCompleted {
+			t.Errorf("Expected %s to be %s, got %s", deviceID, core.StatusCompleted, deviceProg.Status)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		done := len(percent) == 3
+		for _, p := range percent {
+			done = done && p == 100
+		}
+		mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for 100%% progress events, got %v", percent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOrchestrator_RejectsPayloadDigestMismatch(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 2, 2)
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", PayloadSHA256: strings.Repeat("0", 64)}
+	err := orch.ExecuteUpdateWithPayload(ctx, update, strings.NewReader("firmware"))
+	if !errors.Is(err, core.ErrVerificationFailed) {
+		t.Fatalf("Expected ErrVerificationFailed, got %v", err)
+	}
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestOrchestrator_CancelNotRunning(t *testing.T) {
+	orch, _ := setupTestOrchestrator(t, 1, 1)
+
+	err := orch.Cancel(context.Background(), "missing")
+	if !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound, got %v", err)
+	}
+}
+
+func TestOrchestrator_BeginUpdateTwice(t *testing.T) {
+	orch, _ := setupTestOrchestrator(t, 1, 1)
+	ctx := context.Background()
+	update := core.Update{ID: "update-1"}
+
+	devices, _ := orch.ResolveDevices(ctx, update)
+	if _, err := orch.BeginUpdate(ctx, update, devices); err != nil {
+		t.Fatalf("BeginUpdate failed: %v", err)
+	}
+	defer orch.FinishUpdate(ctx, update)
+
+	if _, err := orch.BeginUpdate(ctx, update, devices); !errors.Is(err, core.ErrUpdateInProgress) {
+		t.Errorf("Expected ErrUpdateInProgress, got %v", err)
+	}
+}
+
+func TestOrchestrator_PauseResume(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 6, 2)
+	delivery.PushDelay = 100 * time.Millisecond
+	ctx := context.Background()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware"))
+	}()
+
+	waitForRunning(t, orch, "update-1")
+	time.Sleep(20 * time.Millisecond) // Let the first pushes start
+
+	if err := orch.Pause(ctx, "update-1"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if err := orch.Pause(ctx, "update-1"); err == nil {
+		t.Error("Expected error when pausing an already paused update")
+	}
+
+	// In-flight pushes finish, the rest of the queue is held
+	time.Sleep(300 * time.Millisecond)
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 pushes while paused, got %d", delivery.GetPushCount())
+	}
+
+	status, _ := orch.GetStatus(ctx, "update-1")
+	if status.Status != core.StatusPaused {
+		t.Errorf("Expected status %s, got %s", core.StatusPaused, status.Status)
+	}
+	if status.Completed != 2 {
+		t.Errorf("Expected 2 completed while paused, got %d", status.Completed)
+	}
+
+	if err := orch.Resume(ctx, "update-1"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Update did not finish after resume")
+	}
+
+	if delivery.GetPushCount() != 6 {
+		t.Errorf("Expected 6 pushes after resume, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestOrchestrator_CancelWhilePaused(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 4, 1)
+	delivery.PushDelay = 50 * time.Millisecond
+	ctx := context.Background()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware"))
+	}()
+
+	waitForRunning(t, orch, "update-1")
+	orch.Pause(ctx, "update-1")
+	orch.Cancel(ctx, "update-1")
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, core.ErrCancelled) {
+			t.Errorf("Expected ErrCancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Paused update was not cancelled")
+	}
+}
+
+func TestOrchestrator_VerifyAfterPush(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 3, 3)
+	ctx := context.Background()
+
+	var verified atomic.Int64
+	orch.Subscribe(events.EventDeviceVerified, mocks.EventCounter(&verified))
+
+	if err := orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	if delivery.GetVerifyCount() != 3 {
+		t.Errorf("Expected 3 verify calls, got %d", delivery.GetVerifyCount())
+	}
+
+	status, _ := orch.GetStatus(ctx, "update-1")
+	if status.Completed != 3 {
+		t.Errorf("Expected 3 completed, got %d", status.Completed)
+	}
+
+	waitForCount(t, &verified, 3)
+}
+
+func TestOrchestrator_VerifyPollsRebootingDevice(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 1, 1)
+	delivery.VerifyFailures = 2
+	ctx := context.Background()
+
+	if err := orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	if delivery.GetVerifyCount() != 3 {
+		t.Errorf("Expected 3 verify attempts, got %d", delivery.GetVerifyCount())
+	}
+
+	status, _ := orch.GetStatus(ctx, "update-1")
+	if status.Completed != 1 || status.Failed != 0 {
+		t.Errorf("Expected device to complete after polling, got %d completed, %d failed", status.Completed, status.Failed)
+	}
+}
+
+func TestOrchestrator_VerificationFailed(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 2, 2)
+	delivery.VerifyShouldFail = true
+	ctx := context.Background()
+
+	var (
+		mu     sync.Mutex
+		errs   []error
+		failed atomic.Int64
+	)
+	orch.Subscribe(events.EventDeviceVerificationFailed, events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		mu.Lock()
+		errs = append(errs, event.Error)
+		mu.Unlock()
+		failed.Add(1)
+	}))
+
+	if err := orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	status, _ := orch.GetStatus(ctx, "update-1")
+	if status.Failed != 2 {
+		t.Errorf("Expected 2 failed devices, got %d", status.Failed)
+	}
+	if status.Status != core.StatusFailed {
+		t.Errorf("Expected status %s, got %s", core.StatusFailed, status.Status)
+	}
+
+	// 1 attempt + VerifyRetries per device
+	if delivery.GetVerifyCount() != 2*(1+orch.config.VerifyRetries) {
+		t.Errorf("Expected %d verify calls, got %d", 2*(1+orch.config.VerifyRetries), delivery.GetVerifyCount())
+	}
+
+	waitForCount(t, &failed, 2)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, err := range errs {
+		if !errors.Is(err, core.ErrVerificationFailed) {
+			t.Errorf("Expected ErrVerificationFailed, got %v", err)
+		}
+	}
+}
+
+func TestOrchestrator_VerifyTimeout(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 1, 1)
+	delivery.VerifyShouldFail = true
+	orch.config.VerifyRetries = 100
+	orch.config.VerifyPollInterval = 20 * time.Millisecond
+	orch.config.VerifyTimeout = 100 * time.Millisecond
+	ctx := context.Background()
+
+	start := time.Now()
+	if err := orch.ExecuteUpdateWithPayload(ctx, core.Update{ID: "update-1"}, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Verification did not honour VerifyTimeout, took %v", elapsed)
+	}
+
+	status, _ := orch.GetStatus(ctx, "update-1")
+	if status.Failed != 1 {
+		t.Errorf("Expected device to fail verification, got %d failed", status.Failed)
+	}
+}
+
+func TestOrchestrator_VerifyDisabled(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 2, 2)
+	orch.config.VerifyAfterPush = false
+	delivery.VerifyShouldFail = true
+
+	if err := orch.ExecuteUpdateWithPayload(context.Background(), core.Update{ID: "update-1"}, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	if delivery.GetVerifyCount() != 0 {
+		t.Errorf("Expected no verify calls, got %d", delivery.GetVerifyCount())
+	}
+}
+
+func TestOrchestrator_VerifyRecordsFirmwareVersion(t *testing.T) {
+	orch, _ := setupTestOrchestrator(t, 1, 1)
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", TargetVersion: "2.0.0"}
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	device, _ := orch.registry.Get(ctx, "device-1")
+	if device.FirmwareVersion != "2.0.0" {
+		t.Errorf("Expected firmware version 2.0.0 in registry, got %q", device.FirmwareVersion)
+	}
+}
+
+func TestOrchestrator_VerifyVersionMismatch(t *testing.T) {
+	orch, delivery := setupTestOrchestrator(t, 1, 1)
+	delivery.ReportedVersion = "1.9.0"
+	ctx := context.Background()
+
+	update := core.Update{ID: "update-1", TargetVersion: "2.0.0"}
+	if err := orch.ExecuteUpdateWithPayload(ctx, update, strings.NewReader("firmware")); err != nil {
+		t.Fatalf("ExecuteUpdateWithPayload failed: %v", err)
+	}
+
+	status, _ := orch.GetStatus(ctx, "update-1")
+	if status.Failed != 1 {
+		t.Errorf("Expected device to fail verification, got %d failed", status.Failed)
+	}
+
+	// The version the device actually runs is recorded
+	device, _ := orch.registry.Get(ctx, "device-1")
+	if device.FirmwareVersion != "1.9.0" {
+		t.Errorf("Expected firmware version 1.9.0 in registry, got %q", device.FirmwareVersion)
+	}
+}
+
+// Helper functions
+
+func setupTestOrchestrator(t *testing.T, deviceCount, maxConcurrent int) (*Orchestrator, *mocks.MockDelivery) {
+	t.Helper()
+
+	ctx := context.Background()
+	registry := memory.New()
+	for i := 1; i <= deviceCount; i++ {
+		registry.Add(ctx, core.Device{
+			ID:      fmt.Sprintf("device-%d", i),
+			Name:    fmt.Sprintf("Device %d", i),
+			Address: fmt.Sprintf("10.0.0.%d", i),
+			Status:  core.DeviceOnline,
+		})
+	}
+
+	delivery := mocks.NewMockDelivery()
+	config := DefaultConfig()
+	config.MaxConcurrent = maxConcurrent
+	config.VerifyAfterPush = true
+	config.VerifyRetries = 3
+	config.VerifyPollInterval = time.Millisecond
+
+	orch, err := NewDefault(config, registry, delivery)
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	return orch, delivery
+}
+
+func waitForCount(t *testing.T, counter *atomic.Int64, expected int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for counter.Load() < expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d events, got %d", expected, counter.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitForRunning(t *testing.T, orch *Orchestrator, updateID string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !orch.IsRunning(updateID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for update %s to start", updateID)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,350 @@
+// Package cache implements a content-addressed on-disk store for update
+// payloads. Payloads are stored once per SHA-256 digest, however many
+// updates use them, and are evicted least recently used first when the
+// cache outgrows its size budget. Payloads that are in use are never
+// evicted.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrDigestMismatch indicates a payload given to PutExpect does not have the
+// expected digest.
+var ErrDigestMismatch = errors.New("payload digest mismatch")
+
+// Config holds cache configuration.
+type Config struct {
+	// Dir is the directory payloads are stored in. Payloads already there
+	// from an earlier run are kept.
+	Dir string
+
+	// MaxBytes is the size budget (0 means unlimited). Payloads in use are
+	// kept even if they exceed it.
+	MaxBytes int64
+}
+
+// DefaultConfig returns cache configuration with sensible defaults.
+func DefaultConfig() *Config {
+	return &Config{
+		Dir:      filepath.Join(os.TempDir(), "update-payloads"),
+		MaxBytes: 10 << 30, // 10GB
+	}
+}
+
+// Stats describes the cache contents and how well it is doing.
+type Stats struct {
+	Entries    int   `json:"entries"`    // Payloads stored
+	Bytes      int64 `json:"bytes"`      // Total size of stored payloads
+	MaxBytes   int64 `json:"max_bytes"`  // Size budget (0 = unlimited)
+	Referenced int   `json:"referenced"` // Payloads in use
+	Hits       int64 `json:"hits"`       // Lookups and stores that found the payload already cached
+	Misses     int64 `json:"misses"`     // Lookups that did not find the payload
+	Evictions  int64 `json:"evictions"`  // Payloads evicted to stay within the budget
+}
+
+// Cache is a content-addressed payload store. It is safe for concurrent use.
+type Cache struct {
+	config *Config
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	lru     *list.List // Of *entry, most recently used first
+	bytes   int64
+
+	hits, misses, evictions int64
+}
+
+// entry is a stored payload.
+type entry struct {
+	sha256 string
+	size   int64
+	path   string
+	file   *os.File
+	refs   int
+	elem   *list.Element
+}
+
+// New opens a cache in config.Dir, creating the directory if needed.
+func New(config *Config) (*Cache, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Dir == "" {
+		return nil, fmt.Errorf("cache directory is required")
+	}
+	if config.MaxBytes < 0 {
+		return nil, fmt.Errorf("MaxBytes cannot be negative")
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &Cache{
+		config:  config,
+		entries: make(map[string]*entry),
+		lru:     list.New(),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// load indexes the payloads stored by an earlier run, oldest first, and
+// removes files left behind by interrupted stores.
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.config.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	type stored struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var found []stored
+	for _, dirEntry := range dirEntries {
+		path := filepath.Join(c.config.Dir, dirEntry.Name())
+		if filepath.Ext(dirEntry.Name()) == ".tmp" {
+			os.Remove(path)
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() || !isDigest(dirEntry.Name()) {
+			continue
+		}
+		found = append(found, stored{dirEntry.Name(), info.Size(), info.ModTime()})
+	}
+	slices.SortFunc(found, func(a, b stored) int { return a.modTime.Compare(b.modTime) })
+
+	for _, s := range found {
+		e, err := c.open(s.name, s.size)
+		if err != nil {
+			return err
+		}
+		e.elem = c.lru.PushFront(e)
+		c.entries[e.sha256] = e
+		c.bytes += e.size
+	}
+	return nil
+}
+
+// open opens the stored payload with the given digest.
+func (c *Cache) open(sha256 string, size int64) (*entry, error) {
+	path := filepath.Join(c.config.Dir, sha256)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cached payload: %w", err)
+	}
+	return &entry{sha256: sha256, size: size, path: path, file: file}, nil
+}
+
+// Put stores the payload read from r and returns a handle to it. If the
+// payload is already cached, the stored copy is used and the new one
+// discarded. The handle must be released when no longer needed.
+func (c *Cache) Put(ctx context.Context, r io.Reader) (*Handle, error) {
+	return c.put(ctx, r, "")
+}
+
+// PutExpect is like Put, but only stores the payload if its hex-encoded
+// SHA-256 digest is sha256. Otherwise it is discarded and an error matching
+// ErrDigestMismatch is returned.
+func (c *Cache) PutExpect(ctx context.Context, r io.Reader, sha256 string) (*Handle, error) {
+	return c.put(ctx, r, sha256)
+}
+
+// put stores the payload read from r, if it has the expected digest (any
+// digest if expect is empty).
+func (c *Cache) put(ctx context.Context, r io.Reader, expect string) (*Handle, error) {
+	tmp, err := os.CreateTemp(c.config.Dir, "put-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), &contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store payload: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if expect != "" && sum != expect {
+		return nil, fmt.Errorf("%w: got %s, expected %s", ErrDigestMismatch, sum, expect)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[sum]; ok {
+		c.hits++
+		return c.acquireLocked(e), nil
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(c.config.Dir, sum)); err != nil {
+		return nil, fmt.Errorf("failed to store payload: %w", err)
+	}
+	e, err := c.open(sum, size)
+	if err != nil {
+		return nil, err
+	}
+	e.elem = c.lru.PushFront(e)
+	c.entries[sum] = e
+	c.bytes += size
+
+	handle := c.acquireLocked(e)
+	c.evictLocked()
+	return handle, nil
+}
+
+// Get returns a handle to the cached payload with the given hex-encoded
+// SHA-256 digest, if any. The handle must be released when no longer needed.
+func (c *Cache) Get(sha256 string) (*Handle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[sha256]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return c.acquireLocked(e), true
+}
+
+// Stats returns the current cache statistics.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	referenced := 0
+	for _, e := range c.entries {
+		if e.refs > 0 {
+			referenced++
+		}
+	}
+	return Stats{
+		Entries:    len(c.entries),
+		Bytes:      c.bytes,
+		MaxBytes:   c.config.MaxBytes,
+		Referenced: referenced,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+}
+
+// acquireLocked takes a reference to an entry and marks it recently used.
+// Must be called with c.mu held.
+func (c *Cache) acquireLocked(e *entry) *Handle {
+	e.refs++
+	c.lru.MoveToFront(e.elem)
+	return &Handle{cache: c, entry: e}
+}
+
+// release drops a reference to an entry, which may make it evictable.
+func (c *Cache) release(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.refs--
+	c.evictLocked()
+}
+
+// evictLocked removes least recently used, unreferenced payloads until the
+// cache fits its budget. Must be called with c.mu held.
+func (c *Cache) evictLocked() {
+	if c.config.MaxBytes == 0 {
+		return
+	}
+
+	for elem := c.lru.Back(); elem != nil && c.bytes > c.config.MaxBytes; {
+		e := elem.Value.(*entry)
+		elem = elem.Prev()
+		if e.refs > 0 {
+			continue
+		}
+
+		c.lru.Remove(e.elem)
+		delete(c.entries, e.sha256)
+		c.bytes -= e.size
+		c.evictions++
+		e.file.Close()
+		os.Remove(e.path)
+	}
+}
+
+// Handle is a reference to a cached payload, which keeps it from being
+// evicted until released. Reads through a handle may run concurrently.
+type Handle struct {
+	cache *Cache
+	entry *entry
+	once  sync.Once
+}
+
+// SHA256 returns the hex-encoded SHA-256 digest of the payload.
+func (h *Handle) SHA256() string {
+	return h.entry.sha256
+}
+
+// Size returns the payload size in bytes.
+func (h *Handle) Size() int64 {
+	return h.entry.size
+}
+
+// Path returns the location of the payload on disk.
+func (h *Handle) Path() string {
+	return h.entry.path
+}
+
+// ReadAt implements io.ReaderAt.
+func (h *Handle) ReadAt(p []byte, off int64) (int, error) {
+	return h.entry.file.ReadAt(p, off)
+}
+
+// Release drops the reference. It is safe to call more than once; the
+// handle must not be read from afterwards.
+func (h *Handle) Release() {
+	h.once.Do(func() {
+		h.cache.release(h.entry)
+	})
+}
+
+// contextReader stops reading once its context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// isDigest reports whether name is a hex-encoded SHA-256 digest.
+func isDigest(name string) bool {
+	sum, err := hex.DecodeString(name)
+	return err == nil && len(sum) == sha256.Size && hex.EncodeToString(sum) == name
+}
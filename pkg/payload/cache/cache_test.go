@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// put stores data and fails the test on error.
+func put(t *testing.T, c *Cache, data string) *Handle {
+	t.Helper()
+
+	handle, err := c.Put(context.Background(), strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	return handle
+}
+
+// digest returns the hex-encoded SHA-256 digest of data.
+func digest(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCache_PutDeduplicates(t *testing.T) {
+	c, err := New(&Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	first := put(t, c, "firmware v2.0")
+	second := put(t, c, "firmware v2.0")
+	if first.Path() != second.Path() || first.SHA256() != digest("firmware v2.0") {
+		t.Errorf("expected one payload stored under its digest, got %s and %s", first.Path(), second.Path())
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 1 || stats.Bytes != int64(len("firmware v2.0")) || stats.Hits != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCache_PutExpectDiscardsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(&Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	_, err = c.PutExpect(context.Background(), strings.NewReader("tampered"), digest("firmware v2.0"))
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("expected ErrDigestMismatch, got %v", err)
+	}
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("expected nothing cached, got %+v", stats)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("expected no files left behind, got %d", len(files))
+	}
+
+	handle, err := c.PutExpect(context.Background(), strings.NewReader("firmware v2.0"), digest("firmware v2.0"))
+	if err != nil {
+		t.Fatalf("PutExpect failed: %v", err)
+	}
+	handle.Release()
+}
+
+func TestCache_ConcurrentReaders(t *testing.T) {
+	c, _ := New(&Config{Dir: t.TempDir()})
+	payload := strings.Repeat("0123456789", 10000)
+	put(t, c, payload).Release()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handle, ok := c.Get(digest(payload))
+			if !ok {
+				t.Error("expected a cache hit")
+				return
+			}
+			defer handle.Release()
+
+			data, err := io.ReadAll(io.NewSectionReader(handle, 0, handle.Size()))
+			if err != nil || string(data) != payload {
+				t.Errorf("read %d bytes: %v", len(data), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Referenced != 0 || stats.Hits != 8 {
+		t.Errorf("expected all references released, got %+v", stats)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := New(&Config{Dir: t.TempDir(), MaxBytes: 20})
+
+	a := put(t, c, "aaaaaaaaaa")
+	b := put(t, c, "bbbbbbbbbb")
+	a.Release()
+	b.Release()
+
+	// Using a makes b the least recently used
+	if handle, ok := c.Get(digest("aaaaaaaaaa")); ok {
+		handle.Release()
+	}
+	put(t, c, "cccccccccc").Release()
+
+	if _, ok := c.Get(digest("bbbbbbbbbb")); ok {
+		t.Error("expected the least recently used payload to be evicted")
+	}
+	if _, err := os.Stat(b.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected the evicted file to be removed, got %v", err)
+	}
+	if _, ok := c.Get(digest("aaaaaaaaaa")); !ok {
+		t.Error("expected the recently used payload to be kept")
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Bytes != 20 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCache_NeverEvictsReferencedPayloads(t *testing.T) {
+	c, _ := New(&Config{Dir: t.TempDir(), MaxBytes: 10})
+
+	a := put(t, c, "aaaaaaaaaa")
+	b := put(t, c, "bbbbbbbbbb")
+
+	// Both are in use, so the cache stays over budget
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions != 0 {
+		t.Fatalf("expected referenced payloads to be kept, got %+v", stats)
+	}
+
+	// Releasing one lets it go
+	a.Release()
+	a.Release() // Releasing twice is a no-op
+	if _, ok := c.Get(digest("aaaaaaaaaa")); ok {
+		t.Error("expected the released payload to be evicted")
+	}
+	data, _ := io.ReadAll(io.NewSectionReader(b, 0, b.Size()))
+	if string(data) != "bbbbbbbbbb" {
+		t.Errorf("expected the referenced payload to stay readable, got %q", data)
+	}
+}
+
+func TestCache_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := New(&Config{Dir: dir})
+	put(t, c, "firmware v2.0").Release()
+	os.WriteFile(filepath.Join(dir, "put-123.tmp"), []byte("partial"), 0o644)
+
+	reopened, err := New(&Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, ok := reopened.Get(digest("firmware v2.0")); !ok {
+		t.Error("expected payloads from the earlier run to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "put-123.tmp")); !os.IsNotExist(err) {
+		t.Error("expected leftover temporary files to be removed")
+	}
+}
@@ -0,0 +1,21 @@
+package payload
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// Verify checks a payload's SHA-256 digest and size against the ones the
+// update expects. Expectations the update leaves empty are not checked.
+// Mismatches match core.ErrVerificationFailed with errors.Is.
+func Verify(update core.Update, sha256 string, size int64) error {
+	if update.PayloadSize > 0 && update.PayloadSize != size {
+		return fmt.Errorf("%w: payload is %d bytes, expected %d", core.ErrVerificationFailed, size, update.PayloadSize)
+	}
+	if update.PayloadSHA256 != "" && !strings.EqualFold(update.PayloadSHA256, sha256) {
+		return fmt.Errorf("%w: payload SHA-256 is %s, expected %s", core.ErrVerificationFailed, sha256, update.PayloadSHA256)
+	}
+	return nil
+}
@@ -0,0 +1,397 @@
+package payload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
+)
+
+// Source opens the raw payload stream for a URL.
+// Implementations handle a single URL scheme (file, http, s3, etc).
+type Source interface {
+	// Open returns a reader for the payload at the given URL.
+	// The caller is responsible for closing the returned reader.
+	Open(ctx context.Context, u *url.URL) (io.ReadCloser, error)
+}
+
+// SourceFunc is a function adapter for the Source interface.
+type SourceFunc func(ctx context.Context, u *url.URL) (io.ReadCloser, error)
+
+// Open implements the Source interface.
+func (f SourceFunc) Open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	return f(ctx, u)
+}
+
+// Config holds payload fetcher configuration.
+type Config struct {
+	// CacheDir is the directory where payloads are staged (default: os.TempDir())
+	CacheDir string
+
+	// HTTPTimeout is the timeout for downloading payloads over HTTP(S)
+	HTTPTimeout time.Duration
+
+	// Headers to include in HTTP(S) payload requests (e.g., Authorization)
+	Headers map[string]string
+
+	// Cache stores staged payloads by content, so updates with the same
+	// payload share one copy on disk (optional; CacheDir is used otherwise).
+	Cache *cache.Cache
+}
+
+// DefaultConfig returns payload fetcher configuration with sensible defaults.
+func DefaultConfig() *Config {
+	return &Config{
+		CacheDir:    "",
+		HTTPTimeout: 10 * time.Minute,
+		Headers:     make(map[string]string),
+	}
+}
+
+// Fetcher resolves payload URLs and stages them into a local cache.
+// Staged payloads are keyed by update ID so they can be shared across
+// rollout phases and released once the update is finished.
+type Fetcher struct {
+	config *Config
+
+	mu      sync.Mutex
+	sources map[string]Source
+	staged  map[string]*Staged
+}
+
+// NewFetcher creates a fetcher with file:// and http(s):// sources registered.
+func NewFetcher(config *Config) *Fetcher {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	f := &Fetcher{
+		config:  config,
+		sources: make(map[string]Source),
+		staged:  make(map[string]*Staged),
+	}
+
+	httpSource := &HTTPSource{
+		Client:  &http.Client{Timeout: config.HTTPTimeout},
+		Headers: config.Headers,
+	}
+
+	f.sources["file"] = FileSource{}
+	f.sources["http"] = httpSource
+	f.sources["https"] = httpSource
+
+	return f
+}
+
+// RegisterSource adds or replaces the source used for a URL scheme.
+func (f *Fetcher) RegisterSource(scheme string, source Source) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sources[strings.ToLower(scheme)] = source
+}
+
+// Stage fetches the payload for an update into the local cache.
+// If the update already has a staged payload, it is returned as-is.
+func (f *Fetcher) Stage(ctx context.Context, updateID, payloadURL string) (*Staged, error) {
+	if updateID == "" {
+		return nil, fmt.Errorf("update ID is required")
+	}
+	if strings.TrimSpace(payloadURL) == "" {
+		return nil, fmt.Errorf("payload URL is required")
+	}
+
+	f.mu.Lock()
+	if staged, exists := f.staged[updateID]; exists {
+		f.mu.Unlock()
+		return staged, nil
+	}
+	f.mu.Unlock()
+
+	u, err := parseURL(payloadURL)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	source, ok := f.sources[u.Scheme]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported payload URL scheme: %s", u.Scheme)
+	}
+
+	rc, err := source.Open(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payload %s: %w", payloadURL, err)
+	}
+	defer rc.Close()
+
+	var staged *Staged
+	if f.config.Cache != nil {
+		handle, err := f.config.Cache.Put(ctx, rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stage payload %s: %w", payloadURL, err)
+		}
+		staged = stagedFromCache(updateID, payloadURL, handle)
+	} else {
+		staged, err = f.stageFile(ctx, updateID, payloadURL, rc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f.add(staged), nil
+}
+
+// StageUpdate stages the payload of an update (see Stage). If the update
+// names its payload digest and the cache already holds that payload, it is
+// used without fetching the URL.
+func (f *Fetcher) StageUpdate(ctx context.Context, update core.Update) (*Staged, error) {
+	if f.config.Cache != nil && update.PayloadSHA256 != "" && update.ID != "" {
+		if staged, ok := f.Get(update.ID); ok {
+			return staged, nil
+		}
+		if handle, ok := f.config.Cache.Get(strings.ToLower(update.PayloadSHA256)); ok {
+			return f.add(stagedFromCache(update.ID, update.PayloadURL, handle)), nil
+		}
+	}
+	return f.Stage(ctx, update.ID, update.PayloadURL)
+}
+
+// stageFile copies a payload into a file of its own in CacheDir.
+func (f *Fetcher) stageFile(ctx context.Context, updateID, payloadURL string, r io.Reader) (*Staged, error) {
+	file, err := os.CreateTemp(f.config.CacheDir, "payload-*.bin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+
+	// Hash the payload as it is staged
+	hash := sha256.New()
+	size, err := copyWithContext(ctx, io.MultiWriter(file, hash), r)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to stage payload %s: %w", payloadURL, err)
+	}
+
+	return &Staged{
+		UpdateID: updateID,
+		URL:      payloadURL,
+		Size:     size,
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		path:     file.Name(),
+		data:     file,
+		release: func() error {
+			file.Close()
+			if err := os.Remove(file.Name()); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove staged payload: %w", err)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// stagedFromCache returns a staged payload holding a cache reference.
+func stagedFromCache(updateID, payloadURL string, handle *cache.Handle) *Staged {
+	return &Staged{
+		UpdateID: updateID,
+		URL:      payloadURL,
+		Size:     handle.Size(),
+		SHA256:   handle.SHA256(),
+		path:     handle.Path(),
+		data:     handle,
+		release: func() error {
+			handle.Release()
+			return nil
+		},
+	}
+}
+
+// add records a staged payload for its update. If another caller staged
+// the update meanwhile, that payload is kept and returned instead.
+func (f *Fetcher) add(staged *Staged) *Staged {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Another caller may have staged the same update while we were downloading
+	if existing, exists := f.staged[staged.UpdateID]; exists {
+		staged.remove()
+		return existing
+	}
+	f.staged[staged.UpdateID] = staged
+
+	return staged
+}
+
+// Get returns the staged payload for an update, if any.
+func (f *Fetcher) Get(updateID string) (*Staged, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	staged, ok := f.staged[updateID]
+	return staged, ok
+}
+
+// Release removes the staged payload for an update from the cache. Payloads
+// stored in Config.Cache stay there, unreferenced, until evicted.
+// Releasing an update with no staged payload is a no-op.
+func (f *Fetcher) Release(updateID string) error {
+	f.mu.Lock()
+	staged, exists := f.staged[updateID]
+	delete(f.staged, updateID)
+	f.mu.Unlock()
+
+	if !exists {
+		return nil
+	}
+	return staged.remove()
+}
+
+// Staged is a payload stored in the local cache.
+type Staged struct {
+	UpdateID string // Update the payload belongs to
+	URL      string // Original payload URL
+	Size     int64  // Payload size in bytes
+	SHA256   string // Hex-encoded SHA-256 digest of the payload
+
+	path    string
+	data    io.ReaderAt
+	release func() error // Deletes the payload or drops its cache reference
+}
+
+// Reader returns a new seekable reader over the staged payload.
+// Each reader has its own offset, so readers can be used independently.
+func (s *Staged) Reader() io.ReadSeeker {
+	return io.NewSectionReader(s.data, 0, s.Size)
+}
+
+// Shared returns the staged payload for pushing to many devices at once.
+func (s *Staged) Shared() *Shared {
+	shared := NewShared(s.data, s.Size)
+	shared.sha256 = s.SHA256
+	return shared
+}
+
+// Verify checks the staged payload against the digest and size the update
+// expects (see Verify).
+func (s *Staged) Verify(update core.Update) error {
+	return Verify(update, s.SHA256, s.Size)
+}
+
+// Path returns the location of the staged payload on disk.
+func (s *Staged) Path() string {
+	return s.path
+}
+
+// remove releases the staged payload's storage.
+func (s *Staged) remove() error {
+	return s.release()
+}
+
+// parseURL parses a payload URL, treating bare paths as file:// URLs.
+func parseURL(payloadURL string) (*url.URL, error) {
+	u, err := url.Parse(payloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload URL %q: %w", payloadURL, err)
+	}
+
+	// Bare paths (and Windows drive letters) are treated as local files
+	if u.Scheme == "" || len(u.Scheme) == 1 {
+		abs, err := filepath.Abs(payloadURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload path %q: %w", payloadURL, err)
+		}
+		return &url.URL{Scheme: "file", Path: abs}, nil
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	return u, nil
+}
+
+// copyWithContext copies src to dst, stopping early if the context is cancelled.
+func copyWithContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var written int64
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			w, werr := dst.Write(buf[:n])
+			written += int64(w)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// FileSource opens payloads from the local filesystem.
+type FileSource struct{}
+
+// Open implements the Source interface for file:// URLs.
+func (FileSource) Open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("remote file hosts are not supported: %s", u.Host)
+	}
+	return os.Open(path)
+}
+
+// HTTPSource downloads payloads over HTTP(S).
+type HTTPSource struct {
+	Client  *http.Client
+	Headers map[string]string
+}
+
+// Open implements the Source interface for http:// and https:// URLs.
+func (s *HTTPSource) Open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("payload download failed with status %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,242 @@
+package payload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
+)
+
+func TestFetcher_StageFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "firmware.bin")
+	if err := os.WriteFile(src, []byte("firmware v2.0"), 0o644); err != nil {
+		t.Fatalf("failed to write payload: %v", err)
+	}
+
+	fetcher := newTestFetcher(t)
+	ctx := context.Background()
+
+	for _, payloadURL := range []string{"file://" + src, src} {
+		staged, err := fetcher.Stage(ctx, "update-"+payloadURL, payloadURL)
+		if err != nil {
+			t.Fatalf("Stage(%s) failed: %v", payloadURL, err)
+		}
+
+		if staged.Size != int64(len("firmware v2.0")) {
+			t.Errorf("expected size %d, got %d", len("firmware v2.0"), staged.Size)
+		}
+
+		data, err := io.ReadAll(staged.Reader())
+		if err != nil {
+			t.Fatalf("failed to read staged payload: %v", err)
+		}
+		if string(data) != "firmware v2.0" {
+			t.Errorf("expected 'firmware v2.0', got %q", string(data))
+		}
+
+		sum := sha256.Sum256([]byte("firmware v2.0"))
+		if staged.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("expected SHA-256 %x, got %s", sum, staged.SHA256)
+		}
+	}
+}
+
+func TestFetcher_StageHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("expected Authorization header, got %q", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte("http payload"))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.CacheDir = t.TempDir()
+	config.Headers["Authorization"] = "Bearer token"
+	fetcher := NewFetcher(config)
+
+	staged, err := fetcher.Stage(context.Background(), "update-1", server.URL+"/firmware.bin")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	data, _ := io.ReadAll(staged.Reader())
+	if string(data) != "http payload" {
+		t.Errorf("expected 'http payload', got %q", string(data))
+	}
+}
+
+func TestFetcher_StageHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	fetcher := newTestFetcher(t)
+
+	if _, err := fetcher.Stage(context.Background(), "update-1", server.URL+"/missing.bin"); err == nil {
+		t.Fatal("expected error for 404 payload, got nil")
+	}
+
+	if _, ok := fetcher.Get("update-1"); ok {
+		t.Error("failed stage should not leave a cached payload")
+	}
+}
+
+func TestFetcher_CustomSource(t *testing.T) {
+	fetcher := newTestFetcher(t)
+	fetcher.RegisterSource("mem", SourceFunc(func(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("from " + u.Host)), nil
+	}))
+
+	staged, err := fetcher.Stage(context.Background(), "update-1", "mem://bucket/firmware.bin")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	data, _ := io.ReadAll(staged.Reader())
+	if string(data) != "from bucket" {
+		t.Errorf("expected 'from bucket', got %q", string(data))
+	}
+}
+
+func TestFetcher_UnsupportedScheme(t *testing.T) {
+	fetcher := newTestFetcher(t)
+
+	if _, err := fetcher.Stage(context.Background(), "update-1", "ftp://example.com/firmware.bin"); err == nil {
+		t.Fatal("expected error for unsupported scheme, got nil")
+	}
+}
+
+func TestFetcher_StageIsShared(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "firmware.bin")
+	os.WriteFile(src, []byte("shared"), 0o644)
+
+	fetcher := newTestFetcher(t)
+	ctx := context.Background()
+
+	first, err := fetcher.Stage(ctx, "update-1", src)
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	second, err := fetcher.Stage(ctx, "update-1", src)
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	if first != second {
+		t.Error("expected the same staged payload for the same update")
+	}
+
+	// Readers must not share an offset
+	r1, r2 := first.Reader(), second.Reader()
+	buf := make([]byte, 3)
+	r1.Read(buf)
+	data, _ := io.ReadAll(r2)
+	if string(data) != "shared" {
+		t.Errorf("expected independent reader to read 'shared', got %q", string(data))
+	}
+}
+
+func TestFetcher_Release(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "firmware.bin")
+	os.WriteFile(src, []byte("data"), 0o644)
+
+	fetcher := newTestFetcher(t)
+
+	staged, err := fetcher.Stage(context.Background(), "update-1", src)
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	if err := fetcher.Release("update-1"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	if _, err := os.Stat(staged.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected staged file to be removed, got %v", err)
+	}
+
+	if _, ok := fetcher.Get("update-1"); ok {
+		t.Error("expected staged payload to be forgotten after release")
+	}
+
+	// Releasing again is a no-op
+	if err := fetcher.Release("update-1"); err != nil {
+		t.Errorf("second Release failed: %v", err)
+	}
+}
+
+func TestFetcher_StageDeduplicatesInCache(t *testing.T) {
+	payloadCache, err := cache.New(&cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+
+	fetches := 0
+	config := DefaultConfig()
+	config.Cache = payloadCache
+	fetcher := NewFetcher(config)
+	fetcher.RegisterSource("mem", SourceFunc(func(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+		fetches++
+		return io.NopCloser(strings.NewReader("firmware v2.0")), nil
+	}))
+	ctx := context.Background()
+
+	// Two updates uploading the same payload share one cached copy
+	first, err := fetcher.Stage(ctx, "update-1", "mem://a/firmware.bin")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	second, err := fetcher.Stage(ctx, "update-2", "mem://b/firmware.bin")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if first.Path() != second.Path() {
+		t.Errorf("expected one cached copy, got %s and %s", first.Path(), second.Path())
+	}
+
+	// An update naming a cached digest is not fetched at all
+	third, err := fetcher.StageUpdate(ctx, core.Update{ID: "update-3", PayloadURL: "mem://c/firmware.bin", PayloadSHA256: first.SHA256})
+	if err != nil {
+		t.Fatalf("StageUpdate failed: %v", err)
+	}
+	if fetches != 2 || third.Path() != first.Path() {
+		t.Errorf("expected a cache hit without fetching, got %d fetches", fetches)
+	}
+
+	// Releasing an update keeps the payload for the others
+	for _, updateID := range []string{"update-1", "update-2"} {
+		if err := fetcher.Release(updateID); err != nil {
+			t.Fatalf("Release failed: %v", err)
+		}
+	}
+	data, _ := io.ReadAll(third.Reader())
+	if string(data) != "firmware v2.0" {
+		t.Errorf("expected 'firmware v2.0', got %q", string(data))
+	}
+	if stats := payloadCache.Stats(); stats.Entries != 1 || stats.Referenced != 1 {
+		t.Errorf("expected 1 cached payload in use, got %+v", stats)
+	}
+}
+
+func newTestFetcher(t *testing.T) *Fetcher {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.CacheDir = t.TempDir()
+	return NewFetcher(config)
+}
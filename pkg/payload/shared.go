@@ -0,0 +1,109 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// Shared is a payload that is pushed to many devices at once. It is backed
+// by an io.ReaderAt, and every push gets its own section reader, so pushes
+// never share a read offset and a retry only rewinds its own reader.
+type Shared struct {
+	r    io.ReaderAt
+	size int64
+
+	mu     sync.Mutex
+	sha256 string // Computed on first use unless known up front
+}
+
+// NewShared returns a shared payload over the first size bytes of r.
+func NewShared(r io.ReaderAt, size int64) *Shared {
+	return &Shared{r: r, size: size}
+}
+
+// NewSharedFromReadSeeker returns a shared payload over the whole of rs.
+// Payloads that implement io.ReaderAt (e.g., *os.File, *bytes.Reader,
+// *strings.Reader) are read concurrently; other payloads are read under a
+// lock, one chunk at a time, but readers still keep their own offsets.
+func NewSharedFromReadSeeker(rs io.ReadSeeker) (*Shared, error) {
+	if rs == nil {
+		return nil, errors.New("payload is required")
+	}
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure payload: %w", err)
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind payload: %w", err)
+	}
+
+	if ra, ok := rs.(io.ReaderAt); ok {
+		return NewShared(ra, size), nil
+	}
+	return NewShared(&seekerAt{rs: rs}, size), nil
+}
+
+// Reader returns a new reader over the payload with its own offset.
+func (s *Shared) Reader() *io.SectionReader {
+	return io.NewSectionReader(s.r, 0, s.size)
+}
+
+// Size returns the payload size in bytes.
+func (s *Shared) Size() int64 {
+	return s.size
+}
+
+// SHA256 returns the hex-encoded SHA-256 digest of the payload. It is
+// computed by reading the payload the first time it is needed.
+func (s *Shared) SHA256() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sha256 == "" {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, s.Reader()); err != nil {
+			return "", fmt.Errorf("failed to hash payload: %w", err)
+		}
+		s.sha256 = hex.EncodeToString(hash.Sum(nil))
+	}
+	return s.sha256, nil
+}
+
+// Verify checks the payload against the digest and size the update expects
+// (see Verify).
+func (s *Shared) Verify(update core.Update) error {
+	sum, err := s.SHA256()
+	if err != nil {
+		return err
+	}
+	return Verify(update, sum, s.size)
+}
+
+// seekerAt implements io.ReaderAt over an io.ReadSeeker by seeking before
+// each read.
+type seekerAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (s *seekerAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(s.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,121 @@
+package payload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// readSeekerOnly hides the io.ReaderAt of the wrapped reader.
+type readSeekerOnly struct {
+	io.ReadSeeker
+}
+
+func TestShared_ConcurrentReaders(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10*1024)
+
+	sources := map[string]io.ReadSeeker{
+		"ReaderAt":   bytes.NewReader(data),
+		"ReadSeeker": readSeekerOnly{bytes.NewReader(data)},
+	}
+	for name, rs := range sources {
+		t.Run(name, func(t *testing.T) {
+			shared, err := NewSharedFromReadSeeker(rs)
+			if err != nil {
+				t.Fatalf("NewSharedFromReadSeeker failed: %v", err)
+			}
+			if shared.Size() != int64(len(data)) {
+				t.Fatalf("Expected size %d, got %d", len(data), shared.Size())
+			}
+
+			var wg sync.WaitGroup
+			results := make([][]byte, 8)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					// Small reads interleave the readers as much as possible
+					var buf bytes.Buffer
+					io.CopyBuffer(&buf, struct{ io.Reader }{shared.Reader()}, make([]byte, 7))
+					results[i] = buf.Bytes()
+				}(i)
+			}
+			wg.Wait()
+
+			for i, got := range results {
+				if !bytes.Equal(got, data) {
+					t.Errorf("Reader %d got a corrupted payload (%d bytes)", i, len(got))
+				}
+			}
+		})
+	}
+}
+
+func TestShared_ReaderRewindsIndependently(t *testing.T) {
+	shared, err := NewSharedFromReadSeeker(strings.NewReader("firmware"))
+	if err != nil {
+		t.Fatalf("NewSharedFromReadSeeker failed: %v", err)
+	}
+
+	r1, r2 := shared.Reader(), shared.Reader()
+	io.ReadAll(r1)
+
+	// Rewinding one reader (e.g., for a retry) leaves the other alone
+	buf := make([]byte, 4)
+	r2.Read(buf)
+	r1.Seek(0, io.SeekStart)
+
+	rest, _ := io.ReadAll(r2)
+	if string(rest) != "ware" {
+		t.Errorf("Expected second reader to continue at its offset, got %q", rest)
+	}
+	again, _ := io.ReadAll(r1)
+	if string(again) != "firmware" {
+		t.Errorf("Expected rewound reader to read the whole payload, got %q", again)
+	}
+}
+
+func TestNewSharedFromReadSeeker_Nil(t *testing.T) {
+	if _, err := NewSharedFromReadSeeker(nil); err == nil {
+		t.Error("Expected an error for a nil payload")
+	}
+}
+
+func TestShared_Verify(t *testing.T) {
+	// SHA-256 of "hello"
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	tests := []struct {
+		name    string
+		update  core.Update
+		wantErr bool
+	}{
+		{"no expectations", core.Update{}, false},
+		{"matching digest and size", core.Update{PayloadSHA256: sum, PayloadSize: 5}, false},
+		{"uppercase digest", core.Update{PayloadSHA256: strings.ToUpper(sum)}, false},
+		{"wrong digest", core.Update{PayloadSHA256: strings.Repeat("0", 64)}, true},
+		{"wrong size", core.Update{PayloadSize: 6}, true},
+	}
+
+	shared, err := NewSharedFromReadSeeker(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("NewSharedFromReadSeeker failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := shared.Verify(tt.update)
+			if tt.wantErr && !errors.Is(err, core.ErrVerificationFailed) {
+				t.Errorf("Expected ErrVerificationFailed, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
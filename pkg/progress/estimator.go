@@ -1,28 +1,241 @@
 package progress
 
 import (
+	"math"
+	"sync"
 	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
 )
 
-// Estimator calculates estimated completion time for updates.
+// DefaultEstimateWindow is the moving window rates are measured over.
+const DefaultEstimateWindow = time.Minute
+
+// minWindowCompletions is the number of devices that must finish within the
+// window for its rate to be used; below it the whole run is measured.
+const minWindowCompletions = 3
+
+// confidenceZ is the z-score of the confidence bounds (about 95%).
+const confidenceZ = 1.96
+
+// Estimator calculates estimated completion time for updates. Rates are
+// measured over a moving window of recent progress, so estimates follow
+// changes in throughput (e.g., a rollout phase with more concurrency).
 type Estimator struct {
-	// TODO: Add fields for rate calculation
+	window time.Duration
+
+	mu      sync.Mutex
+	samples map[string][]byteSample // Byte counters per update, oldest first
+}
+
+// byteSample is an update's transferred byte count at a point in time.
+type byteSample struct {
+	time  time.Time
+	bytes int64
+}
+
+// Estimation holds the rates and completion estimate of an update.
+type Estimation struct {
+	DeviceRate  float64    // Devices finished per second
+	Throughput  float64    // Bytes transferred per second
+	Concurrency int        // Devices currently updating
+	Remaining   int        // Devices not yet finished
+	End         *time.Time // Estimated completion time (nil if unknown)
+	EarliestEnd *time.Time // Lower confidence bound of End
+	LatestEnd   *time.Time // Upper confidence bound of End
 }
 
 // NewEstimator creates a new time estimator.
 func NewEstimator() *Estimator {
-	return &Estimator{}
+	return NewEstimatorWithWindow(DefaultEstimateWindow)
+}
+
+// NewEstimatorWithWindow creates an estimator measuring rates over window.
+func NewEstimatorWithWindow(window time.Duration) *Estimator {
+	return &Estimator{
+		window:  window,
+		samples: make(map[string][]byteSample),
+	}
 }
 
 // Estimate calculates the estimated completion time based on current progress.
 func (e *Estimator) Estimate(progress *Progress) *time.Time {
-	// TODO: Implement time estimation logic
-	// Consider: average device time, remaining devices, concurrent updates
-	return nil
+	return e.Calculate(progress).End
 }
 
 // CalculateRate returns the current transfer rate in bytes per second.
 func (e *Estimator) CalculateRate(progress *Progress) float64 {
-	// TODO: Implement rate calculation
-	return 0.0
+	return e.Calculate(progress).Throughput
+}
+
+// Apply fills in the estimate fields of progress.
+func (e *Estimator) Apply(progress *Progress) {
+	est := e.Calculate(progress)
+	progress.EstimatedEnd = est.End
+	progress.EstimatedEarliest = est.EarliestEnd
+	progress.EstimatedLatest = est.LatestEnd
+	progress.DeviceRate = est.DeviceRate
+	progress.Throughput = est.Throughput
+}
+
+// Calculate measures an update's rates and estimates when it will finish.
+//
+// The remaining devices are divided by the device completion rate of the
+// window. Once every remaining device is in flight, the estimate is instead
+// the time the slowest of them needs to reach the average device duration.
+// The bounds widen with the spread of device durations and narrow as more
+// devices finish.
+//
+// A finished update is not sampled: its samples are dropped, and its
+// throughput is measured over the whole run.
+func (e *Estimator) Calculate(progress *Progress) Estimation {
+	if progress.EndTime != nil {
+		e.Forget(progress.UpdateID)
+
+		var est Estimation
+		if elapsed := progress.EndTime.Sub(progress.StartTime); elapsed > 0 {
+			est.Throughput = float64(progress.BytesTransferred) / elapsed.Seconds()
+		}
+		return est
+	}
+
+	now := time.Now()
+
+	est := Estimation{
+		Throughput:  e.observe(progress, now),
+		Concurrency: progress.InProgressDevices,
+	}
+
+	var inFlight []time.Time // Start times of the devices updating
+	for _, device := range progress.DeviceProgress {
+		if device.Status == core.StatusInProgress || device.Status == core.StatusVerifying {
+			inFlight = append(inFlight, device.StartTime)
+		}
+	}
+
+	finished := progress.CompletedDevices + progress.FailedDevices
+	est.Remaining = progress.TotalDevices - finished
+	if finished == 0 || est.Remaining <= 0 {
+		return est
+	}
+
+	// Measure over the window, or the whole run if too few devices finished in it
+	start := now.Add(-e.window)
+	if start.Before(progress.StartTime) {
+		start = progress.StartTime
+	}
+	durations := finishedDurations(progress, start)
+	count := len(durations)
+	if count < minWindowCompletions {
+		start = progress.StartTime
+		durations = finishedDurations(progress, start)
+		count = finished
+	}
+
+	elapsed := now.Sub(start)
+	if elapsed <= 0 {
+		return est
+	}
+	est.DeviceRate = float64(count) / elapsed.Seconds()
+
+	remaining := time.Duration(float64(est.Remaining) / est.DeviceRate * float64(time.Second))
+
+	mean, stddev := meanAndStddev(durations)
+	if est.Concurrency > 0 && est.Remaining <= est.Concurrency && mean > 0 {
+		// Only in-flight devices are left: wait for the slowest of them
+		remaining = 0
+		for _, started := range inFlight {
+			if left := mean - now.Sub(started); left > remaining {
+				remaining = left
+			}
+		}
+	}
+
+	// Spread of the estimate: the standard error of the mean device duration
+	spread := 0.5
+	if len(durations) > 1 && mean > 0 {
+		spread = confidenceZ * (float64(stddev) / float64(mean)) / math.Sqrt(float64(len(durations)))
+	}
+
+	end := now.Add(remaining)
+	earliest := now.Add(time.Duration(float64(remaining) * math.Max(0, 1-spread)))
+	latest := now.Add(time.Duration(float64(remaining) * (1 + spread)))
+	est.End = &end
+	est.EarliestEnd = &earliest
+	est.LatestEnd = &latest
+
+	return est
+}
+
+// Forget drops the samples kept for an update (e.g., once it has finished).
+func (e *Estimator) Forget(updateID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.samples, updateID)
+}
+
+// observe records an update's byte count and returns its throughput over
+// the window.
+func (e *Estimator) observe(progress *Progress, now time.Time) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	samples := e.samples[progress.UpdateID]
+	if n := len(samples); n > 0 && samples[n-1].bytes > progress.BytesTransferred {
+		samples = nil // The update was started again
+	}
+	samples = append(samples, byteSample{time: now, bytes: progress.BytesTransferred})
+
+	// Drop samples that left the window, keeping the newest of them as a baseline
+	cutoff := now.Add(-e.window)
+	for len(samples) > 2 && !samples[1].time.After(cutoff) {
+		samples = samples[1:]
+	}
+	e.samples[progress.UpdateID] = samples
+
+	base := byteSample{time: progress.StartTime}
+	if len(samples) > 1 {
+		base = samples[0]
+	}
+
+	elapsed := now.Sub(base.time)
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(progress.BytesTransferred-base.bytes) / elapsed.Seconds()
+}
+
+// finishedDurations returns how long the devices that finished after start took.
+func finishedDurations(progress *Progress, start time.Time) []time.Duration {
+	var durations []time.Duration
+	for _, device := range progress.DeviceProgress {
+		if device.EndTime == nil || device.EndTime.Before(start) {
+			continue
+		}
+		if device.Status == core.StatusCompleted || device.Status == core.StatusFailed {
+			durations = append(durations, device.EndTime.Sub(device.StartTime))
+		}
+	}
+	return durations
+}
+
+// meanAndStddev returns the mean and standard deviation of durations.
+func meanAndStddev(durations []time.Duration) (time.Duration, time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, d := range durations {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(durations))
+
+	var variance float64
+	for _, d := range durations {
+		variance += (float64(d) - mean) * (float64(d) - mean)
+	}
+	variance /= float64(len(durations))
+
+	return time.Duration(mean), time.Duration(math.Sqrt(variance))
 }
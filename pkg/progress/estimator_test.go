@@ -0,0 +1,204 @@
+package progress
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// testProgress builds the progress of an update that started at start, with
+// devices finishing at the given offsets after start, each taking duration.
+func testProgress(start time.Time, total int, duration time.Duration, offsets ...time.Duration) *Progress {
+	prog := &Progress{
+		UpdateID:       "update-1",
+		TotalDevices:   total,
+		StartTime:      start,
+		DeviceProgress: make(map[string]DeviceProgress),
+	}
+	for i, offset := range offsets {
+		end := start.Add(offset)
+		id := fmt.Sprintf("device-%d", i)
+		prog.DeviceProgress[id] = DeviceProgress{
+			DeviceID:  id,
+			Status:    core.StatusCompleted,
+			StartTime: end.Add(-duration),
+			EndTime:   &end,
+		}
+		prog.CompletedDevices++
+	}
+	return prog
+}
+
+func TestEstimator_NoEstimateBeforeFirstDevice(t *testing.T) {
+	estimator := NewEstimator()
+
+	prog := testProgress(time.Now().Add(-time.Minute), 10, 0)
+	if end := estimator.Estimate(prog); end != nil {
+		t.Errorf("Expected no estimate before a device finishes, got %v", end)
+	}
+}
+
+func TestEstimator_NoEstimateWhenFinished(t *testing.T) {
+	estimator := NewEstimator()
+
+	prog := testProgress(time.Now().Add(-time.Minute), 2, time.Second, 10*time.Second, 20*time.Second)
+	if end := estimator.Estimate(prog); end != nil {
+		t.Errorf("Expected no estimate for a finished update, got %v", end)
+	}
+}
+
+func TestEstimator_UsesMovingWindow(t *testing.T) {
+	estimator := NewEstimatorWithWindow(10 * time.Second)
+	now := time.Now()
+
+	// Slow start: 2 devices in the first 50s, then 10 devices in the last 10s
+	offsets := []time.Duration{20 * time.Second, 40 * time.Second}
+	for i := 0; i < 10; i++ {
+		offsets = append(offsets, 51*time.Second+time.Duration(i)*900*time.Millisecond)
+	}
+	prog := testProgress(now.Add(-time.Minute), 22, 5*time.Second, offsets...)
+
+	est := estimator.Calculate(prog)
+
+	// About 1 device per second over the window (not 12 per minute overall)
+	if est.DeviceRate < 0.9 || est.DeviceRate > 1.1 {
+		t.Errorf("Expected window rate of about 1 device/s, got %.2f", est.DeviceRate)
+	}
+	if est.Remaining != 10 {
+		t.Errorf("Expected 10 remaining devices, got %d", est.Remaining)
+	}
+	if est.End == nil {
+		t.Fatal("Expected an estimate")
+	}
+	if left := est.End.Sub(now); left < 8*time.Second || left > 12*time.Second {
+		t.Errorf("Expected about 10s left, got %v", left)
+	}
+}
+
+func TestEstimator_FallsBackToWholeRun(t *testing.T) {
+	estimator := NewEstimatorWithWindow(10 * time.Second)
+	now := time.Now()
+
+	// Nothing finished recently: measure the whole run (2 devices in 60s)
+	prog := testProgress(now.Add(-time.Minute), 4, 5*time.Second, 10*time.Second, 20*time.Second)
+
+	est := estimator.Calculate(prog)
+	if est.End == nil {
+		t.Fatal("Expected an estimate")
+	}
+	if left := est.End.Sub(now); left < 55*time.Second || left > 65*time.Second {
+		t.Errorf("Expected about 60s left, got %v", left)
+	}
+}
+
+func TestEstimator_ConfidenceBounds(t *testing.T) {
+	estimator := NewEstimator()
+	now := time.Now()
+
+	prog := testProgress(now.Add(-30*time.Second), 20, 0,
+		5*time.Second, 10*time.Second, 15*time.Second, 20*time.Second, 25*time.Second)
+
+	// Vary the device durations
+	for i, id := range []string{"device-0", "device-1", "device-2", "device-3", "device-4"} {
+		device := prog.DeviceProgress[id]
+		device.StartTime = device.EndTime.Add(-time.Duration(i+1) * time.Second)
+		prog.DeviceProgress[id] = device
+	}
+
+	est := estimator.Calculate(prog)
+	if est.End == nil || est.EarliestEnd == nil || est.LatestEnd == nil {
+		t.Fatalf("Expected an estimate with bounds, got %+v", est)
+	}
+	if !est.EarliestEnd.Before(*est.End) || !est.LatestEnd.After(*est.End) {
+		t.Errorf("Expected earliest < end < latest, got %v / %v / %v", est.EarliestEnd, est.End, est.LatestEnd)
+	}
+	if est.EarliestEnd.Before(now) {
+		t.Errorf("Expected earliest end in the future, got %v", est.EarliestEnd)
+	}
+}
+
+func TestEstimator_WaitsForInFlightDevices(t *testing.T) {
+	estimator := NewEstimator()
+	now := time.Now()
+
+	// 8 devices took 10s each; the last 2 started 4s ago
+	var offsets []time.Duration
+	for i := 0; i < 8; i++ {
+		offsets = append(offsets, time.Duration(10+i)*time.Second)
+	}
+	prog := testProgress(now.Add(-20*time.Second), 10, 10*time.Second, offsets...)
+	for _, id := range []string{"device-8", "device-9"} {
+		prog.DeviceProgress[id] = DeviceProgress{
+			DeviceID:  id,
+			Status:    core.StatusInProgress,
+			StartTime: now.Add(-4 * time.Second),
+		}
+		prog.InProgressDevices++
+	}
+
+	est := estimator.Calculate(prog)
+	if est.Concurrency != 2 {
+		t.Errorf("Expected concurrency 2, got %d", est.Concurrency)
+	}
+	if est.End == nil {
+		t.Fatal("Expected an estimate")
+	}
+	if left := est.End.Sub(now); left < 5*time.Second || left > 7*time.Second {
+		t.Errorf("Expected about 6s left for in-flight devices, got %v", left)
+	}
+}
+
+func TestEstimator_Throughput(t *testing.T) {
+	estimator := NewEstimator()
+	now := time.Now()
+
+	prog := testProgress(now.Add(-10*time.Second), 10, 0)
+	prog.BytesTransferred = 10 * 1024 * 1024
+
+	// First observation: measured from the start of the update
+	rate := estimator.CalculateRate(prog)
+	if rate < 0.9*1024*1024 || rate > 1.1*1024*1024 {
+		t.Errorf("Expected about 1 MiB/s, got %.0f", rate)
+	}
+
+	// Later observations are measured from the oldest sample in the window
+	time.Sleep(20 * time.Millisecond)
+	prog.BytesTransferred += 1024 * 1024
+	if rate := estimator.CalculateRate(prog); rate < 10*1024*1024 {
+		t.Errorf("Expected throughput over the recent samples, got %.0f", rate)
+	}
+
+	// A restarted update starts over
+	estimator.Forget("update-1")
+	prog.BytesTransferred = 0
+	if rate := estimator.CalculateRate(prog); rate != 0 {
+		t.Errorf("Expected no throughput, got %.0f", rate)
+	}
+}
+
+func TestEstimator_FinishedUpdateIsNotSampled(t *testing.T) {
+	estimator := NewEstimator()
+	now := time.Now()
+
+	prog := testProgress(now.Add(-20*time.Second), 10, 0)
+	prog.BytesTransferred = 10 * 1024 * 1024
+	estimator.CalculateRate(prog)
+
+	// Polling a finished update measures the whole run and keeps no samples
+	end := now.Add(-10 * time.Second)
+	prog.EndTime = &end
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if rate := estimator.CalculateRate(prog); rate < 0.9*1024*1024 || rate > 1.1*1024*1024 {
+			t.Errorf("Poll %d: expected about 1 MiB/s over the run, got %.0f", i, rate)
+		}
+	}
+
+	estimator.mu.Lock()
+	defer estimator.mu.Unlock()
+	if len(estimator.samples) != 0 {
+		t.Errorf("Expected no samples kept for a finished update, got %d", len(estimator.samples))
+	}
+}
@@ -15,6 +15,7 @@ type Tracker struct {
 	mu        sync.RWMutex
 	updates   map[string]*updateState
 	publisher progress.Publisher // Optional event publisher
+	estimator *progress.Estimator
 }
 
 // updateState holds the internal state for an update
@@ -33,7 +34,8 @@ type updateState struct {
 // New creates a new in-memory progress tracker.
 func New() *Tracker {
 	return &Tracker{
-		updates: make(map[string]*updateState),
+		updates:   make(map[string]*updateState),
+		estimator: progress.NewEstimator(),
 	}
 }
 
@@ -42,6 +44,7 @@ func NewWithPublisher(publisher progress.Publisher) *Tracker {
 	return &Tracker{
 		updates:   make(map[string]*updateState),
 		publisher: publisher,
+		estimator: progress.NewEstimator(),
 	}
 }
 
@@ -98,7 +101,7 @@ func (t *Tracker) UpdateDevice(ctx context.Context, updateID, deviceID string, s
 
 	// Decrement old status count
 	switch oldStatus {
-	case core.StatusInProgress:
+	case core.StatusInProgress, core.StatusVerifying:
 		state.inProgressDevices--
 	case core.StatusCompleted:
 		state.completedDevices--
@@ -108,7 +111,7 @@ func (t *Tracker) UpdateDevice(ctx context.Context, updateID, deviceID string, s
 
 	// Increment new status count
 	switch newStatus {
-	case core.StatusInProgress:
+	case core.StatusInProgress, core.StatusVerifying:
 		state.inProgressDevices++
 	case core.StatusCompleted:
 		state.completedDevices++
@@ -122,7 +125,10 @@ func (t *Tracker) UpdateDevice(ctx context.Context, updateID, deviceID string, s
 
 	// Update device progress
 	deviceProg.Status = newStatus
-	deviceProg.BytesTransferred = bytesTransferred
+	deviceProg.BytesTransferred += bytesTransferred
+	if newStatus != core.StatusFailed {
+		deviceProg.Error = nil // A retried device starts over
+	}
 
 	// Update total bytes transferred
 	state.bytesTransferred += bytesTransferred
@@ -142,6 +148,20 @@ func (t *Tracker) UpdateDevice(ctx context.Context, updateID, deviceID string, s
 	}
 }
 
+// FailDevice marks a device as failed and records the error.
+func (t *Tracker) FailDevice(ctx context.Context, updateID, deviceID string, err error) {
+	t.UpdateDevice(ctx, updateID, deviceID, string(core.StatusFailed), 0)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state, exists := t.updates[updateID]; exists {
+		if deviceProg, exists := state.deviceProgress[deviceID]; exists {
+			deviceProg.Error = err
+		}
+	}
+}
+
 // Complete marks an update as completed.
 func (t *Tracker) Complete(ctx context.Context, updateID string) {
 	t.mu.Lock()
@@ -154,6 +174,7 @@ func (t *Tracker) Complete(ctx context.Context, updateID string) {
 
 	now := time.Now()
 	state.endTime = &now
+	t.estimator.Forget(updateID)
 
 	// Publish event if publisher is configured
 	if t.publisher != nil {
@@ -180,25 +201,13 @@ func (t *Tracker) GetProgress(ctx context.Context, updateID string) (*progress.P
 		return nil, fmt.Errorf("update not found: %s", updateID)
 	}
 
-	// Calculate estimated end time
-	var estimatedEnd *time.Time
-	if state.completedDevices+state.failedDevices > 0 && state.inProgressDevices+state.completedDevices+state.failedDevices < state.totalDevices {
-		elapsed := time.Since(state.startTime)
-		completed := state.completedDevices + state.failedDevices
-		avgTimePerDevice := elapsed / time.Duration(completed)
-		remaining := state.totalDevices - completed
-		estimatedRemaining := avgTimePerDevice * time.Duration(remaining)
-		est := time.Now().Add(estimatedRemaining)
-		estimatedEnd = &est
-	}
-
 	// Copy device progress map
 	deviceProgressMap := make(map[string]progress.DeviceProgress, len(state.deviceProgress))
 	for deviceID, dp := range state.deviceProgress {
 		deviceProgressMap[deviceID] = *dp
 	}
 
-	return &progress.Progress{
+	prog := &progress.Progress{
 		UpdateID:          state.updateID,
 		TotalDevices:      state.totalDevices,
 		CompletedDevices:  state.completedDevices,
@@ -206,7 +215,12 @@ func (t *Tracker) GetProgress(ctx context.Context, updateID string) (*progress.P
 		InProgressDevices: state.inProgressDevices,
 		BytesTransferred:  state.bytesTransferred,
 		StartTime:         state.startTime,
-		EstimatedEnd:      estimatedEnd,
+		EndTime:           state.endTime,
 		DeviceProgress:    deviceProgressMap,
-	}, nil
+	}
+
+	// Calculate estimated end time and rates
+	t.estimator.Apply(prog)
+
+	return prog, nil
 }
@@ -0,0 +1,386 @@
+// Package progresstest provides a test suite for progress.Tracker
+// implementations.
+package progresstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/progress"
+)
+
+// TestTracker runs the tracker suite against trackers created by newTracker.
+// Each subtest gets a fresh, empty tracker.
+func TestTracker(t *testing.T, newTracker func(t *testing.T) progress.Tracker) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, tracker progress.Tracker)
+	}{
+		{"StartAndGetProgress", testStartAndGetProgress},
+		{"UpdateDevice", testUpdateDevice},
+		{"DeviceCompletion", testDeviceCompletion},
+		{"DeviceFailure", testDeviceFailure},
+		{"FailDevice", testFailDevice},
+		{"Complete", testComplete},
+		{"EstimatedEndTime", testEstimatedEndTime},
+		{"MultipleUpdates", testMultipleUpdates},
+		{"GetProgress_NotFound", testGetProgressNotFound},
+		{"UpdateDevice_UnknownUpdate", testUpdateDeviceUnknownUpdate},
+		{"BytesAccumulation", testBytesAccumulation},
+		{"VerifyingCountsAsInProgress", testVerifyingCountsAsInProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newTracker(t))
+		})
+	}
+}
+
+func testStartAndGetProgress(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	totalDevices := 10
+
+	// Start tracking
+	tracker.Start(ctx, updateID, totalDevices)
+
+	// Get progress
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.UpdateID != updateID {
+		t.Errorf("Expected updateID %s, got %s", updateID, prog.UpdateID)
+	}
+
+	if prog.TotalDevices != totalDevices {
+		t.Errorf("Expected %d total devices, got %d", totalDevices, prog.TotalDevices)
+	}
+
+	if prog.CompletedDevices != 0 {
+		t.Errorf("Expected 0 completed devices, got %d", prog.CompletedDevices)
+	}
+
+	if prog.EstimatedEnd != nil {
+		t.Error("Expected no estimated end time initially")
+	}
+}
+
+func testUpdateDevice(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 3)
+
+	// Update device to in_progress
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 1024)
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.InProgressDevices != 1 {
+		t.Errorf("Expected 1 in-progress device, got %d", prog.InProgressDevices)
+	}
+
+	if prog.BytesTransferred != 1024 {
+		t.Errorf("Expected 1024 bytes transferred, got %d", prog.BytesTransferred)
+	}
+
+	// Check device progress
+	deviceProg, exists := prog.DeviceProgress["device-1"]
+	if !exists {
+		t.Fatal("Device progress not found for device-1")
+	}
+
+	if deviceProg.Status != core.StatusInProgress {
+		t.Errorf("Expected status %s, got %s", core.StatusInProgress, deviceProg.Status)
+	}
+
+	if deviceProg.BytesTransferred != 1024 {
+		t.Errorf("Expected 1024 bytes, got %d", deviceProg.BytesTransferred)
+	}
+}
+
+func testDeviceCompletion(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 2)
+
+	// Device 1: in_progress → completed
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 1024)
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusCompleted), 2048)
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.CompletedDevices != 1 {
+		t.Errorf("Expected 1 completed device, got %d", prog.CompletedDevices)
+	}
+
+	if prog.InProgressDevices != 0 {
+		t.Errorf("Expected 0 in-progress devices, got %d", prog.InProgressDevices)
+	}
+
+	// Check device has end time
+	deviceProg := prog.DeviceProgress["device-1"]
+	if deviceProg.EndTime == nil {
+		t.Error("Expected end time to be set for completed device")
+	}
+}
+
+func testDeviceFailure(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 2)
+
+	// Device fails
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 512)
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusFailed), 512)
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.FailedDevices != 1 {
+		t.Errorf("Expected 1 failed device, got %d", prog.FailedDevices)
+	}
+
+	if prog.InProgressDevices != 0 {
+		t.Errorf("Expected 0 in-progress devices, got %d", prog.InProgressDevices)
+	}
+}
+
+func testFailDevice(t *testing.T, tracker progress.Tracker) {
+	failures, ok := tracker.(progress.FailureTracker)
+	if !ok {
+		t.Skip("tracker does not record failure errors")
+	}
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 1)
+
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 0)
+	failures.FailDevice(ctx, updateID, "device-1", errors.New("connection refused"))
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.FailedDevices != 1 {
+		t.Errorf("Expected 1 failed device, got %d", prog.FailedDevices)
+	}
+
+	deviceProg := prog.DeviceProgress["device-1"]
+	if deviceProg.Error == nil || deviceProg.Error.Error() != "connection refused" {
+		t.Errorf("Expected error to be recorded, got %v", deviceProg.Error)
+	}
+	if deviceProg.EndTime == nil {
+		t.Error("Expected end time to be set for failed device")
+	}
+}
+
+func testComplete(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 2)
+
+	// Complete some devices
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusCompleted), 1024)
+	tracker.UpdateDevice(ctx, updateID, "device-2", string(core.StatusCompleted), 2048)
+
+	// Mark update as complete
+	tracker.Complete(ctx, updateID)
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.CompletedDevices != 2 {
+		t.Errorf("Expected 2 completed devices, got %d", prog.CompletedDevices)
+	}
+	if prog.EndTime == nil {
+		t.Error("Expected end time to be set for completed update")
+	}
+
+	// Polling a finished update keeps reporting its throughput
+	time.Sleep(10 * time.Millisecond)
+	again, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if again.Throughput <= 0 || again.Throughput != prog.Throughput {
+		t.Errorf("Expected a steady throughput after completion, got %.0f then %.0f", prog.Throughput, again.Throughput)
+	}
+}
+
+func testEstimatedEndTime(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	totalDevices := 10
+	tracker.Start(ctx, updateID, totalDevices)
+
+	// Complete 5 devices
+	for i := 0; i < 5; i++ {
+		deviceID := "device-" + string(rune('0'+i))
+		tracker.UpdateDevice(ctx, updateID, deviceID, string(core.StatusCompleted), 1024)
+	}
+
+	// Small delay to ensure time progresses
+	time.Sleep(10 * time.Millisecond)
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	// Should have estimated end time since we're 50% done
+	if prog.EstimatedEnd == nil {
+		t.Error("Expected estimated end time when partially complete")
+	}
+
+	if prog.EstimatedEnd != nil {
+		// Estimated end should be in the future
+		if prog.EstimatedEnd.Before(time.Now()) {
+			t.Error("Estimated end time should be in the future")
+		}
+	}
+
+	if prog.EstimatedEarliest == nil || prog.EstimatedLatest == nil {
+		t.Error("Expected confidence bounds with the estimated end time")
+	}
+
+	if prog.DeviceRate <= 0 {
+		t.Errorf("Expected a positive device rate, got %f", prog.DeviceRate)
+	}
+}
+
+func testMultipleUpdates(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	// Track two updates simultaneously
+	tracker.Start(ctx, "update-1", 5)
+	tracker.Start(ctx, "update-2", 10)
+
+	tracker.UpdateDevice(ctx, "update-1", "device-a", string(core.StatusCompleted), 1024)
+	tracker.UpdateDevice(ctx, "update-2", "device-b", string(core.StatusCompleted), 2048)
+
+	// Check update-1
+	prog1, err := tracker.GetProgress(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetProgress failed for update-1: %v", err)
+	}
+
+	if prog1.CompletedDevices != 1 {
+		t.Errorf("update-1: expected 1 completed device, got %d", prog1.CompletedDevices)
+	}
+
+	if prog1.BytesTransferred != 1024 {
+		t.Errorf("update-1: expected 1024 bytes, got %d", prog1.BytesTransferred)
+	}
+
+	// Check update-2
+	prog2, err := tracker.GetProgress(ctx, "update-2")
+	if err != nil {
+		t.Fatalf("GetProgress failed for update-2: %v", err)
+	}
+
+	if prog2.CompletedDevices != 1 {
+		t.Errorf("update-2: expected 1 completed device, got %d", prog2.CompletedDevices)
+	}
+
+	if prog2.BytesTransferred != 2048 {
+		t.Errorf("update-2: expected 2048 bytes, got %d", prog2.BytesTransferred)
+	}
+}
+
+func testGetProgressNotFound(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	_, err := tracker.GetProgress(ctx, "nonexistent")
+	if err == nil {
+		t.Fatal("Expected error for nonexistent update")
+	}
+}
+
+func testUpdateDeviceUnknownUpdate(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	// Should not panic when updating unknown update
+	tracker.UpdateDevice(ctx, "unknown", "device-1", string(core.StatusCompleted), 1024)
+
+	// Verify it doesn't create the update
+	_, err := tracker.GetProgress(ctx, "unknown")
+	if err == nil {
+		t.Error("Expected error, update should not be created by UpdateDevice")
+	}
+}
+
+func testBytesAccumulation(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 1)
+
+	// Update device multiple times with increasing bytes
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 1024)
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 2048)
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusCompleted), 4096)
+
+	prog, err := tracker.GetProgress(ctx, updateID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	// Total bytes = 1024 + 2048 + 4096
+	expectedBytes := int64(1024 + 2048 + 4096)
+	if prog.BytesTransferred != expectedBytes {
+		t.Errorf("Expected %d total bytes, got %d", expectedBytes, prog.BytesTransferred)
+	}
+}
+
+func testVerifyingCountsAsInProgress(t *testing.T, tracker progress.Tracker) {
+	ctx := context.Background()
+
+	updateID := "update-123"
+	tracker.Start(ctx, updateID, 1)
+
+	// Device 1: in_progress → verifying → completed
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusInProgress), 0)
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusVerifying), 0)
+
+	prog, _ := tracker.GetProgress(ctx, updateID)
+	if prog.InProgressDevices != 1 {
+		t.Errorf("Expected 1 in-progress device while verifying, got %d", prog.InProgressDevices)
+	}
+	if prog.DeviceProgress["device-1"].Status != core.StatusVerifying {
+		t.Errorf("Expected status %s, got %s", core.StatusVerifying, prog.DeviceProgress["device-1"].Status)
+	}
+
+	tracker.UpdateDevice(ctx, updateID, "device-1", string(core.StatusCompleted), 0)
+
+	prog, _ = tracker.GetProgress(ctx, updateID)
+	if prog.InProgressDevices != 0 {
+		t.Errorf("Expected 0 in-progress devices, got %d", prog.InProgressDevices)
+	}
+	if prog.CompletedDevices != 1 {
+		t.Errorf("Expected 1 completed device, got %d", prog.CompletedDevices)
+	}
+}
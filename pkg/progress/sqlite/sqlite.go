@@ -0,0 +1,510 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/progress"
+)
+
+// timeFormat is a fixed-width UTC timestamp format, so stored times sort
+// chronologically as text.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Tracker implements a SQLite-based progress tracker. Unlike the in-memory
+// tracker it keeps the progress of past updates and every device attempt.
+type Tracker struct {
+	mu        sync.Mutex // Serializes writes (status changes are read-modify-write)
+	db        *sql.DB
+	estimator *progress.Estimator
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS progress_updates (
+	id TEXT PRIMARY KEY,
+	run INTEGER NOT NULL, -- Incremented each time the update is started
+	total_devices INTEGER NOT NULL,
+	bytes_transferred INTEGER NOT NULL DEFAULT 0,
+	started_at TEXT NOT NULL,
+	ended_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS progress_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	update_id TEXT NOT NULL REFERENCES progress_updates(id) ON DELETE CASCADE,
+	run INTEGER NOT NULL,
+	device_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	bytes_transferred INTEGER NOT NULL DEFAULT 0,
+	started_at TEXT NOT NULL,
+	ended_at TEXT,
+	error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS progress_transitions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	attempt_id INTEGER NOT NULL REFERENCES progress_attempts(id) ON DELETE CASCADE,
+	status TEXT NOT NULL,
+	bytes_transferred INTEGER NOT NULL DEFAULT 0,
+	error TEXT,
+	changed_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_progress_attempts_update ON progress_attempts(update_id, run, device_id);
+CREATE INDEX IF NOT EXISTS idx_progress_attempts_device ON progress_attempts(device_id);
+CREATE INDEX IF NOT EXISTS idx_progress_transitions_attempt ON progress_transitions(attempt_id);
+`
+
+// New creates a new SQLite progress tracker.
+func New(dbPath string) (*Tracker, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Enable foreign keys and WAL mode for better concurrency
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	}
+
+	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	}
+
+	// Create schema
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &Tracker{db: db, estimator: progress.NewEstimator()}, nil
+}
+
+// Close closes the database connection.
+func (t *Tracker) Close() error {
+	return t.db.Close()
+}
+
+// Start begins tracking a new update. Starting an update again (e.g., after
+// a restart) begins a new run: attempts left open by the previous run are
+// closed as cancelled, and GetProgress only counts the new run.
+func (t *Tracker) Start(ctx context.Context, updateID string, totalDevices int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Record the change even if the run's context is already cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	now := formatTime(time.Now())
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO progress_updates (id, run, total_devices, bytes_transferred, started_at, ended_at)
+		VALUES (?, 1, ?, 0, ?, NULL)
+		ON CONFLICT(id) DO UPDATE SET
+			run = run + 1,
+			total_devices = excluded.total_devices,
+			bytes_transferred = 0,
+			started_at = excluded.started_at,
+			ended_at = NULL
+	`, updateID, totalDevices, now)
+	if err != nil {
+		return
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, status FROM progress_attempts WHERE update_id = ? AND ended_at IS NULL",
+		updateID,
+	)
+	if err != nil {
+		return
+	}
+	open := make(map[int64]core.UpdateStatus)
+	for rows.Next() {
+		var id int64
+		var status core.UpdateStatus
+		if err := rows.Scan(&id, &status); err != nil {
+			rows.Close()
+			return
+		}
+		open[id] = status
+	}
+	rows.Close()
+
+	for id, status := range open {
+		if err := setAttemptStatus(ctx, tx, id, status, core.StatusCancelled, 0, ""); err != nil {
+			return
+		}
+	}
+
+	tx.Commit()
+}
+
+// UpdateDevice records progress for a specific device.
+func (t *Tracker) UpdateDevice(ctx context.Context, updateID, deviceID string, status string, bytesTransferred int64) {
+	t.recordDevice(ctx, updateID, deviceID, core.UpdateStatus(status), bytesTransferred, "")
+}
+
+// FailDevice marks a device as failed and records the error.
+func (t *Tracker) FailDevice(ctx context.Context, updateID, deviceID string, err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	t.recordDevice(ctx, updateID, deviceID, core.StatusFailed, 0, msg)
+}
+
+// recordDevice records a device status change in the device's current
+// attempt. A device starts a new attempt when it goes back to pending or in
+// progress; any other change to a device without an attempt in progress
+// (e.g., a recheck, or an outcome restored after a restart) applies to its
+// latest attempt. Changes for unknown updates are ignored.
+func (t *Tracker) recordDevice(ctx context.Context, updateID, deviceID string, status core.UpdateStatus, bytesTransferred int64, errMsg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Record the change even if the run's context is already cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var run int
+	err = tx.QueryRowContext(ctx, "SELECT run FROM progress_updates WHERE id = ?", updateID).Scan(&run)
+	if err != nil {
+		return // Silently ignore updates for unknown updateID
+	}
+
+	var attemptID int64
+	var previous core.UpdateStatus
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, status FROM progress_attempts
+		WHERE update_id = ? AND run = ? AND device_id = ? AND ended_at IS NULL
+		ORDER BY id DESC LIMIT 1
+	`, updateID, run, deviceID).Scan(&attemptID, &previous)
+	switch {
+	case err == nil:
+	case !errors.Is(err, sql.ErrNoRows):
+		return
+	case status == core.StatusPending || status == core.StatusInProgress:
+		if attemptID, err = insertAttempt(ctx, tx, updateID, run, deviceID, status); err != nil {
+			return
+		}
+	default:
+		attemptID, previous, err = latestAttempt(ctx, tx, updateID, run, deviceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			if status == core.StatusCancelled {
+				return // Never attempted, so there is nothing to cancel
+			}
+			attemptID, err = insertAttempt(ctx, tx, updateID, run, deviceID, status)
+		}
+		if err != nil {
+			return
+		}
+		if previous == status && bytesTransferred == 0 && errMsg == "" {
+			tx.Commit() // Nothing changed, but the attempt may have moved runs
+			return
+		}
+	}
+
+	if err := setAttemptStatus(ctx, tx, attemptID, previous, status, bytesTransferred, errMsg); err != nil {
+		return
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE progress_updates SET bytes_transferred = bytes_transferred + ? WHERE id = ?",
+		bytesTransferred, updateID,
+	)
+	if err != nil {
+		return
+	}
+
+	tx.Commit()
+}
+
+// insertAttempt opens a new attempt for a device in the given run.
+func insertAttempt(ctx context.Context, tx *sql.Tx, updateID string, run int, deviceID string, status core.UpdateStatus) (int64, error) {
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO progress_attempts (update_id, run, device_id, status, started_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, updateID, run, deviceID, status, formatTime(time.Now()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert attempt: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// latestAttempt returns a device's latest attempt at an update and its
+// status. An attempt from an earlier run (e.g., one whose outcome is restored
+// after a restart) is moved into the given run, so GetProgress counts it.
+// It returns sql.ErrNoRows if the device was never attempted.
+func latestAttempt(ctx context.Context, tx *sql.Tx, updateID string, run int, deviceID string) (int64, core.UpdateStatus, error) {
+	var attemptID int64
+	var attemptRun int
+	var status core.UpdateStatus
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, run, status FROM progress_attempts
+		WHERE update_id = ? AND device_id = ?
+		ORDER BY id DESC LIMIT 1
+	`, updateID, deviceID).Scan(&attemptID, &attemptRun, &status)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if attemptRun != run {
+		_, err = tx.ExecContext(ctx, "UPDATE progress_attempts SET run = ? WHERE id = ?", run, attemptID)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to move attempt: %w", err)
+		}
+	}
+	return attemptID, status, nil
+}
+
+// setAttemptStatus updates an attempt, adding bytesTransferred to its count,
+// and records the transition if its status changed. Terminal statuses end
+// the attempt.
+func setAttemptStatus(ctx context.Context, tx *sql.Tx, attemptID int64, previous, status core.UpdateStatus, bytesTransferred int64, errMsg string) error {
+	now := formatTime(time.Now())
+
+	var endedAt sql.NullString
+	switch status {
+	case core.StatusCompleted, core.StatusFailed, core.StatusCancelled:
+		endedAt = sql.NullString{String: now, Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE progress_attempts
+		SET status = ?, bytes_transferred = bytes_transferred + ?, ended_at = ?, error = ?
+		WHERE id = ?
+	`, status, bytesTransferred, endedAt, nullString(errMsg), attemptID)
+	if err != nil {
+		return fmt.Errorf("failed to update attempt: %w", err)
+	}
+
+	if previous == status && errMsg == "" {
+		return nil // Progress report within the same status
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO progress_transitions (attempt_id, status, bytes_transferred, error, changed_at)
+		SELECT id, status, bytes_transferred, error, ? FROM progress_attempts WHERE id = ?
+	`, now, attemptID)
+	if err != nil {
+		return fmt.Errorf("failed to record transition: %w", err)
+	}
+
+	return nil
+}
+
+// Complete marks an update as completed.
+func (t *Tracker) Complete(ctx context.Context, updateID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.db.ExecContext(context.WithoutCancel(ctx),
+		"UPDATE progress_updates SET ended_at = ? WHERE id = ?",
+		formatTime(time.Now()), updateID,
+	)
+	t.estimator.Forget(updateID)
+}
+
+// GetProgress returns the progress of the latest run of an update, which
+// may have finished long ago.
+func (t *Tracker) GetProgress(ctx context.Context, updateID string) (*progress.Progress, error) {
+	var run, totalDevices int
+	var bytesTransferred int64
+	var startedAt string
+	var endedAt sql.NullString
+	err := t.db.QueryRowContext(ctx,
+		"SELECT run, total_devices, bytes_transferred, started_at, ended_at FROM progress_updates WHERE id = ?",
+		updateID,
+	).Scan(&run, &totalDevices, &bytesTransferred, &startedAt, &endedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query update: %w", err)
+	}
+
+	prog := &progress.Progress{
+		UpdateID:         updateID,
+		TotalDevices:     totalDevices,
+		BytesTransferred: bytesTransferred,
+		DeviceProgress:   make(map[string]progress.DeviceProgress),
+	}
+	if prog.StartTime, err = time.Parse(timeFormat, startedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse started_at: %w", err)
+	}
+	if prog.EndTime, err = parseOptionalTime(endedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse ended_at: %w", err)
+	}
+
+	// The latest attempt of each device is its current progress
+	attempts, err := t.queryAttempts(ctx, "WHERE update_id = ? AND run = ?", updateID, run)
+	if err != nil {
+		return nil, err
+	}
+	for _, attempt := range attempts {
+		deviceProg := progress.DeviceProgress{
+			DeviceID:         attempt.DeviceID,
+			Status:           attempt.Status,
+			BytesTransferred: attempt.BytesTransferred,
+			StartTime:        attempt.StartTime,
+			EndTime:          attempt.EndTime,
+		}
+		if attempt.Error != "" {
+			deviceProg.Error = errors.New(attempt.Error)
+		}
+		prog.DeviceProgress[attempt.DeviceID] = deviceProg
+	}
+
+	for _, deviceProg := range prog.DeviceProgress {
+		switch deviceProg.Status {
+		case core.StatusInProgress, core.StatusVerifying:
+			prog.InProgressDevices++
+		case core.StatusCompleted:
+			prog.CompletedDevices++
+		case core.StatusFailed:
+			prog.FailedDevices++
+		}
+	}
+
+	// Calculate estimated end time and rates
+	t.estimator.Apply(prog)
+
+	return prog, nil
+}
+
+// DeviceAttempts returns every attempt to update a device, across all
+// updates, oldest first.
+func (t *Tracker) DeviceAttempts(ctx context.Context, deviceID string) ([]progress.Attempt, error) {
+	attempts, err := t.queryAttempts(ctx, "WHERE device_id = ?", deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[int64]int, len(attempts))
+	for i, attempt := range attempts {
+		index[attempt.id] = i
+	}
+
+	rows, err := t.db.QueryContext(ctx, `
+		SELECT t.attempt_id, t.status, t.bytes_transferred, t.error, t.changed_at
+		FROM progress_transitions t
+		JOIN progress_attempts a ON a.id = t.attempt_id
+		WHERE a.device_id = ?
+		ORDER BY t.id
+	`, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transitions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attemptID int64
+		var transition progress.Transition
+		var errStr sql.NullString
+		var changedAt string
+		if err := rows.Scan(&attemptID, &transition.Status, &transition.BytesTransferred, &errStr, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transition: %w", err)
+		}
+		transition.Error = errStr.String
+		if transition.Time, err = time.Parse(timeFormat, changedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse changed_at: %w", err)
+		}
+
+		if i, ok := index[attemptID]; ok {
+			attempts[i].Transitions = append(attempts[i].Transitions, transition)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transitions: %w", err)
+	}
+
+	result := make([]progress.Attempt, len(attempts))
+	for i, attempt := range attempts {
+		result[i] = attempt.Attempt
+	}
+	return result, nil
+}
+
+// attempt is a stored attempt with its row ID.
+type attempt struct {
+	progress.Attempt
+	id int64
+}
+
+// queryAttempts returns the attempts matching a WHERE clause, oldest first.
+func (t *Tracker) queryAttempts(ctx context.Context, where string, args ...interface{}) ([]attempt, error) {
+	rows, err := t.db.QueryContext(ctx,
+		"SELECT id, update_id, device_id, status, bytes_transferred, started_at, ended_at, error FROM progress_attempts "+where+" ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]attempt, 0)
+	for rows.Next() {
+		var a attempt
+		var startedAt string
+		var endedAt, errStr sql.NullString
+		err := rows.Scan(&a.id, &a.UpdateID, &a.DeviceID, &a.Status, &a.BytesTransferred, &startedAt, &endedAt, &errStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attempt: %w", err)
+		}
+		a.Error = errStr.String
+		if a.StartTime, err = time.Parse(timeFormat, startedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse started_at: %w", err)
+		}
+		if a.EndTime, err = parseOptionalTime(endedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse ended_at: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// formatTime formats a timestamp for storage.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// parseOptionalTime parses an optional stored timestamp.
+func parseOptionalTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(timeFormat, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/progress"
+	"github.com/dovaclean/go-update-orchestrator/pkg/progress/progresstest"
+)
+
+func TestTracker(t *testing.T) {
+	progresstest.TestTracker(t, func(t *testing.T) progress.Tracker {
+		return setupTestTracker(t, filepath.Join(t.TempDir(), "progress.db"))
+	})
+}
+
+func TestTracker_DeviceAttempts(t *testing.T) {
+	tracker := setupTestTracker(t, filepath.Join(t.TempDir(), "progress.db"))
+	ctx := context.Background()
+
+	// First update fails on device-1, then is retried within the same run
+	tracker.Start(ctx, "update-1", 2)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusInProgress), 0)
+	tracker.FailDevice(ctx, "update-1", "device-1", errors.New("connection refused"))
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusInProgress), 0)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusCompleted), 4096)
+	tracker.UpdateDevice(ctx, "update-1", "device-2", string(core.StatusCompleted), 4096)
+	tracker.Complete(ctx, "update-1")
+
+	// A later update succeeds
+	tracker.Start(ctx, "update-2", 1)
+	tracker.UpdateDevice(ctx, "update-2", "device-1", string(core.StatusInProgress), 0)
+	tracker.UpdateDevice(ctx, "update-2", "device-1", string(core.StatusInProgress), 1024) // Progress report
+	tracker.UpdateDevice(ctx, "update-2", "device-1", string(core.StatusVerifying), 1024)
+	tracker.UpdateDevice(ctx, "update-2", "device-1", string(core.StatusCompleted), 0)
+
+	attempts, err := tracker.DeviceAttempts(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("DeviceAttempts failed: %v", err)
+	}
+
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+
+	failed := attempts[0]
+	if failed.UpdateID != "update-1" || failed.Status != core.StatusFailed {
+		t.Errorf("Expected first attempt to be a failed update-1, got %+v", failed)
+	}
+	if failed.Error != "connection refused" {
+		t.Errorf("Expected error to be recorded, got %q", failed.Error)
+	}
+	if failed.EndTime == nil {
+		t.Error("Expected failed attempt to have an end time")
+	}
+	if len(failed.Transitions) != 2 || failed.Transitions[1].Error != "connection refused" {
+		t.Errorf("Expected in_progress → failed transitions, got %+v", failed.Transitions)
+	}
+
+	retried := attempts[1]
+	if retried.UpdateID != "update-1" || retried.Status != core.StatusCompleted || retried.Error != "" {
+		t.Errorf("Expected second attempt to complete update-1, got %+v", retried)
+	}
+	if retried.BytesTransferred != 4096 {
+		t.Errorf("Expected 4096 bytes, got %d", retried.BytesTransferred)
+	}
+
+	latest := attempts[2]
+	if latest.UpdateID != "update-2" || len(latest.Transitions) != 3 {
+		t.Fatalf("Expected update-2 attempt with 3 transitions, got %+v", latest)
+	}
+	if latest.BytesTransferred != 2048 || latest.Transitions[1].BytesTransferred != 2048 {
+		t.Errorf("Expected progress reports to add up to 2048 bytes, got %+v", latest)
+	}
+	expected := []core.UpdateStatus{core.StatusInProgress, core.StatusVerifying, core.StatusCompleted}
+	for i, status := range expected {
+		if latest.Transitions[i].Status != status {
+			t.Errorf("Transition %d: expected %s, got %s", i, status, latest.Transitions[i].Status)
+		}
+	}
+
+	// Devices without history have no attempts
+	attempts, err = tracker.DeviceAttempts(ctx, "device-9")
+	if err != nil {
+		t.Fatalf("DeviceAttempts failed: %v", err)
+	}
+	if len(attempts) != 0 {
+		t.Errorf("Expected no attempts, got %+v", attempts)
+	}
+}
+
+func TestTracker_HistorySurvivesReopen(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "progress.db")
+	ctx := context.Background()
+
+	tracker := setupTestTracker(t, dbPath)
+	tracker.Start(ctx, "update-1", 2)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusCompleted), 1024)
+	tracker.FailDevice(ctx, "update-1", "device-2", errors.New("disk full"))
+	tracker.Complete(ctx, "update-1")
+	tracker.Close()
+
+	tracker = setupTestTracker(t, dbPath)
+
+	prog, err := tracker.GetProgress(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+
+	if prog.CompletedDevices != 1 || prog.FailedDevices != 1 {
+		t.Errorf("Expected 1 completed and 1 failed device, got %d and %d", prog.CompletedDevices, prog.FailedDevices)
+	}
+	if prog.BytesTransferred != 1024 {
+		t.Errorf("Expected 1024 bytes transferred, got %d", prog.BytesTransferred)
+	}
+	if err := prog.DeviceProgress["device-2"].Error; err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected device-2 error to be kept, got %v", err)
+	}
+}
+
+func TestTracker_RestartBeginsNewRun(t *testing.T) {
+	tracker := setupTestTracker(t, filepath.Join(t.TempDir(), "progress.db"))
+	ctx := context.Background()
+
+	// The first run is interrupted with device-1 in flight
+	tracker.Start(ctx, "update-1", 2)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusInProgress), 512)
+	tracker.UpdateDevice(ctx, "update-1", "device-2", string(core.StatusCompleted), 1024)
+
+	// The update is started again
+	tracker.Start(ctx, "update-1", 1)
+
+	prog, err := tracker.GetProgress(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if prog.TotalDevices != 1 || len(prog.DeviceProgress) != 0 || prog.BytesTransferred != 0 {
+		t.Errorf("Expected a fresh run, got %+v", prog)
+	}
+
+	attempts, err := tracker.DeviceAttempts(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("DeviceAttempts failed: %v", err)
+	}
+	if len(attempts) != 1 || attempts[0].Status != core.StatusCancelled || attempts[0].EndTime == nil {
+		t.Errorf("Expected the interrupted attempt to be closed as cancelled, got %+v", attempts)
+	}
+}
+
+func TestTracker_FinalStatusKeepsAttempt(t *testing.T) {
+	tracker := setupTestTracker(t, filepath.Join(t.TempDir(), "progress.db"))
+	ctx := context.Background()
+
+	tracker.Start(ctx, "update-1", 2)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusInProgress), 0)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusCompleted), 1024)
+
+	// A recheck marks the device completed again; device-2 never started
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusCompleted), 0)
+	tracker.UpdateDevice(ctx, "update-1", "device-2", string(core.StatusCancelled), 0)
+
+	// The update is started again after a restart and its outcome restored
+	tracker.Start(ctx, "update-1", 2)
+	tracker.UpdateDevice(ctx, "update-1", "device-1", string(core.StatusCompleted), 0)
+
+	attempts, err := tracker.DeviceAttempts(ctx, "device-1")
+	if err != nil {
+		t.Fatalf("DeviceAttempts failed: %v", err)
+	}
+	if len(attempts) != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", len(attempts))
+	}
+	if len(attempts[0].Transitions) != 2 || attempts[0].BytesTransferred != 1024 {
+		t.Errorf("Expected in_progress → completed with 1024 bytes, got %+v", attempts[0])
+	}
+
+	prog, err := tracker.GetProgress(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if prog.CompletedDevices != 1 {
+		t.Errorf("Expected the restored outcome to count, got %d completed", prog.CompletedDevices)
+	}
+
+	if attempts, _ := tracker.DeviceAttempts(ctx, "device-2"); len(attempts) != 0 {
+		t.Errorf("Expected no attempts for a device that never started, got %+v", attempts)
+	}
+}
+
+func TestTracker_GetProgress_NotFoundError(t *testing.T) {
+	tracker := setupTestTracker(t, filepath.Join(t.TempDir(), "progress.db"))
+
+	_, err := tracker.GetProgress(context.Background(), "missing")
+	if !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound, got %v", err)
+	}
+}
+
+// setupTestTracker opens a tracker that is closed when the test ends.
+func setupTestTracker(t *testing.T, dbPath string) *Tracker {
+	t.Helper()
+
+	tracker, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+
+	return tracker
+}
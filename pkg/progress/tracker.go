@@ -12,7 +12,8 @@ type Tracker interface {
 	// Start begins tracking a new update.
 	Start(ctx context.Context, updateID string, totalDevices int)
 
-	// UpdateDevice records progress for a specific device.
+	// UpdateDevice records progress for a specific device. bytesTransferred
+	// is the number of payload bytes sent since the previous call.
 	UpdateDevice(ctx context.Context, updateID, deviceID string, status string, bytesTransferred int64)
 
 	// Complete marks an update as completed.
@@ -22,6 +23,40 @@ type Tracker interface {
 	GetProgress(ctx context.Context, updateID string) (*Progress, error)
 }
 
+// FailureTracker is implemented by trackers that record why a device failed.
+// The orchestrator calls FailDevice instead of UpdateDevice for failures.
+type FailureTracker interface {
+	// FailDevice marks a device as failed with the given error.
+	FailDevice(ctx context.Context, updateID, deviceID string, err error)
+}
+
+// History is implemented by trackers that keep the progress of past updates.
+type History interface {
+	// DeviceAttempts returns every attempt to update a device, oldest first.
+	DeviceAttempts(ctx context.Context, deviceID string) ([]Attempt, error)
+}
+
+// Attempt is one run of an update on a device, from its first status
+// change to its terminal status (completed, failed or cancelled).
+type Attempt struct {
+	UpdateID         string
+	DeviceID         string
+	Status           core.UpdateStatus
+	BytesTransferred int64
+	StartTime        time.Time
+	EndTime          *time.Time
+	Error            string
+	Transitions      []Transition // Status changes, oldest first
+}
+
+// Transition is a recorded device status change.
+type Transition struct {
+	Status           core.UpdateStatus
+	BytesTransferred int64
+	Error            string
+	Time             time.Time
+}
+
 // Progress represents the current progress of an update.
 type Progress struct {
 	UpdateID          string
@@ -31,7 +66,12 @@ type Progress struct {
 	InProgressDevices int
 	BytesTransferred  int64
 	StartTime         time.Time
+	EndTime           *time.Time // When the update finished (nil while it runs)
 	EstimatedEnd      *time.Time
+	EstimatedEarliest *time.Time // Lower confidence bound of EstimatedEnd
+	EstimatedLatest   *time.Time // Upper confidence bound of EstimatedEnd
+	DeviceRate        float64    // Devices finished per second (moving window)
+	Throughput        float64    // Bytes transferred per second (moving window)
 	DeviceProgress    map[string]DeviceProgress
 }
 
@@ -5,18 +5,21 @@ import (
 	"sync"
 
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/version"
 )
 
 // Registry implements an in-memory device registry.
 type Registry struct {
 	mu      sync.RWMutex
 	devices map[string]core.Device
+	relays  map[string]core.Relay // By location
 }
 
 // New creates a new in-memory registry.
 func New() *Registry {
 	return &Registry{
 		devices: make(map[string]core.Device),
+		relays:  make(map[string]core.Relay),
 	}
 }
 
@@ -94,8 +97,10 @@ func matchesFilter(device core.Device, filter core.Filter) bool {
 		}
 	}
 
-	// Note: Firmware version filtering (MinFirmware, MaxFirmware) would require
-	// semantic version comparison, which we'll skip for now
+	// Filter by firmware version range (semantic version comparison)
+	if !version.InRange(device.FirmwareVersion, filter.MinFirmware, filter.MaxFirmware) {
+		return false
+	}
 
 	return true
 }
@@ -144,3 +149,48 @@ func (r *Registry) Delete(ctx context.Context, id string) error {
 	delete(r.devices, id)
 	return nil
 }
+
+// SetRelay assigns a relay to its location, replacing any previous one.
+func (r *Registry) SetRelay(ctx context.Context, relay core.Relay) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.relays[relay.Location] = relay
+	return nil
+}
+
+// GetRelay returns the relay assigned to a location.
+func (r *Registry) GetRelay(ctx context.Context, location string) (*core.Relay, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	relay, ok := r.relays[location]
+	if !ok {
+		return nil, core.ErrRelayNotFound
+	}
+	return &relay, nil
+}
+
+// ListRelays returns all relay assignments.
+func (r *Registry) ListRelays(ctx context.Context) ([]core.Relay, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	relays := make([]core.Relay, 0, len(r.relays))
+	for _, relay := range r.relays {
+		relays = append(relays, relay)
+	}
+	return relays, nil
+}
+
+// RemoveRelay unassigns the relay of a location.
+func (r *Registry) RemoveRelay(ctx context.Context, location string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.relays[location]; !ok {
+		return core.ErrRelayNotFound
+	}
+	delete(r.relays, location)
+	return nil
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+func TestRegistry_List_FilterByFirmware(t *testing.T) {
+	registry := New()
+	ctx := context.Background()
+
+	// String comparison would sort "1.10.0" below "1.9.0"
+	devices := []core.Device{
+		{ID: "device-1", FirmwareVersion: "1.9.0"},
+		{ID: "device-2", FirmwareVersion: "1.10.0"},
+		{ID: "device-3", FirmwareVersion: "v2.3-r7"},
+		{ID: "device-4", FirmwareVersion: "unknown"},
+		{ID: "device-5"},
+	}
+
+	for _, device := range devices {
+		if err := registry.Add(ctx, device); err != nil {
+			t.Fatalf("Failed to add device: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter core.Filter
+		want   []string
+	}{
+		{core.Filter{MinFirmware: "1.10.0"}, []string{"device-2", "device-3"}},
+		{core.Filter{MaxFirmware: "1.10.0"}, []string{"device-1", "device-2"}},
+		{core.Filter{MinFirmware: "1.9.1", MaxFirmware: "2.3"}, []string{"device-2", "device-3"}},
+		{core.Filter{MinFirmware: "v2.3-r5"}, []string{"device-3"}},
+		{core.Filter{}, []string{"device-1", "device-2", "device-3", "device-4", "device-5"}},
+	}
+
+	for _, tt := range tests {
+		results, err := registry.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("Failed to list devices: %v", err)
+		}
+
+		got := make([]string, len(results))
+		for i, device := range results {
+			got[i] = device.ID
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("filter min=%q max=%q: expected %v, got %v", tt.filter.MinFirmware, tt.filter.MaxFirmware, tt.want, got)
+		}
+	}
+}
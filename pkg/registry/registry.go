@@ -24,3 +24,20 @@ type Registry interface {
 	// Delete removes a device from the registry.
 	Delete(ctx context.Context, id string) error
 }
+
+// RelayRegistry is implemented by registries that track which relay serves
+// each location (see core.Relay).
+type RelayRegistry interface {
+	// SetRelay assigns a relay to its location, replacing any previous one.
+	SetRelay(ctx context.Context, relay core.Relay) error
+
+	// GetRelay returns the relay assigned to a location, or an error
+	// matching core.ErrRelayNotFound.
+	GetRelay(ctx context.Context, location string) (*core.Relay, error)
+
+	// ListRelays returns all relay assignments.
+	ListRelays(ctx context.Context) ([]core.Relay, error)
+
+	// RemoveRelay unassigns the relay of a location.
+	RemoveRelay(ctx context.Context, location string) error
+}
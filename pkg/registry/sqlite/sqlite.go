@@ -9,10 +9,33 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/version"
 )
 
+// driverName is the SQLite driver with the semver_compare function registered.
+const driverName = "sqlite3_registry"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("semver_compare", semverCompare, true)
+		},
+	})
+}
+
+// semverCompare is the SQL function semver_compare(a, b). It returns -1, 0 or 1
+// like version.Compare, or NULL if either version cannot be parsed so that
+// range filters never match devices with unrecognisable firmware.
+func semverCompare(a, b string) interface{} {
+	c, err := version.Compare(a, b)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
 // Registry implements a SQLite-based device registry.
 type Registry struct {
 	db *sql.DB
@@ -38,11 +61,16 @@ CREATE INDEX IF NOT EXISTS idx_location ON devices(location);
 CREATE INDEX IF NOT EXISTS idx_firmware ON devices(firmware_version);
 CREATE INDEX IF NOT EXISTS idx_last_seen ON devices(last_seen);
 CREATE INDEX IF NOT EXISTS idx_updated_at ON devices(updated_at);
+
+CREATE TABLE IF NOT EXISTS relays (
+	location TEXT PRIMARY KEY,
+	address TEXT NOT NULL
+);
 `
 
 // New creates a new SQLite registry.
 func New(dbPath string) (*Registry, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(driverName, dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -131,14 +159,14 @@ func buildListQuery(filter core.Filter) (string, []interface{}) {
 		args = append(args, filter.Location)
 	}
 
-	// Filter by firmware version (simple string comparison for now)
+	// Filter by firmware version (semantic version comparison)
 	if filter.MinFirmware != "" {
-		query += " AND firmware_version >= ?"
+		query += " AND semver_compare(COALESCE(firmware_version, ''), ?) >= 0"
 		args = append(args, filter.MinFirmware)
 	}
 
 	if filter.MaxFirmware != "" {
-		query += " AND firmware_version <= ?"
+		query += " AND semver_compare(COALESCE(firmware_version, ''), ?) <= 0"
 		args = append(args, filter.MaxFirmware)
 	}
 
@@ -377,3 +405,66 @@ func (r *Registry) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+// SetRelay assigns a relay to its location, replacing any previous one.
+func (r *Registry) SetRelay(ctx context.Context, relay core.Relay) error {
+	query := `
+		INSERT INTO relays (location, address) VALUES (?, ?)
+		ON CONFLICT(location) DO UPDATE SET address = excluded.address
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, relay.Location, relay.Address); err != nil {
+		return fmt.Errorf("failed to set relay: %w", err)
+	}
+	return nil
+}
+
+// GetRelay returns the relay assigned to a location.
+func (r *Registry) GetRelay(ctx context.Context, location string) (*core.Relay, error) {
+	relay := core.Relay{Location: location}
+
+	err := r.db.QueryRowContext(ctx, "SELECT address FROM relays WHERE location = ?", location).Scan(&relay.Address)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, core.ErrRelayNotFound
+		}
+		return nil, fmt.Errorf("failed to get relay: %w", err)
+	}
+	return &relay, nil
+}
+
+// ListRelays returns all relay assignments.
+func (r *Registry) ListRelays(ctx context.Context) ([]core.Relay, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT location, address FROM relays ORDER BY location")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relays: %w", err)
+	}
+	defer rows.Close()
+
+	relays := make([]core.Relay, 0)
+	for rows.Next() {
+		var relay core.Relay
+		if err := rows.Scan(&relay.Location, &relay.Address); err != nil {
+			return nil, fmt.Errorf("failed to scan relay: %w", err)
+		}
+		relays = append(relays, relay)
+	}
+	return relays, rows.Err()
+}
+
+// RemoveRelay unassigns the relay of a location.
+func (r *Registry) RemoveRelay(ctx context.Context, location string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM relays WHERE location = ?", location)
+	if err != nil {
+		return fmt.Errorf("failed to remove relay: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return core.ErrRelayNotFound
+	}
+	return nil
+}
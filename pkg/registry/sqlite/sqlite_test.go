@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -214,6 +215,53 @@ func TestSQLiteRegistry_List_FilterByLocation(t *testing.T) {
 	}
 }
 
+func TestSQLiteRegistry_List_FilterByFirmware(t *testing.T) {
+	registry := setupTestRegistry(t)
+	defer cleanup(registry)
+
+	ctx := context.Background()
+
+	// String comparison would sort "1.10.0" below "1.9.0"
+	devices := []core.Device{
+		{ID: "device-1", Name: "Device 1", Address: "addr1", Status: core.DeviceOnline, FirmwareVersion: "1.9.0"},
+		{ID: "device-2", Name: "Device 2", Address: "addr2", Status: core.DeviceOnline, FirmwareVersion: "1.10.0"},
+		{ID: "device-3", Name: "Device 3", Address: "addr3", Status: core.DeviceOnline, FirmwareVersion: "v2.3-r7"},
+		{ID: "device-4", Name: "Device 4", Address: "addr4", Status: core.DeviceOnline, FirmwareVersion: "unknown"},
+		{ID: "device-5", Name: "Device 5", Address: "addr5", Status: core.DeviceOnline},
+	}
+
+	for _, device := range devices {
+		if err := registry.Add(ctx, device); err != nil {
+			t.Fatalf("Failed to add device: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter core.Filter
+		want   []string
+	}{
+		{core.Filter{MinFirmware: "1.10.0"}, []string{"device-2", "device-3"}},
+		{core.Filter{MaxFirmware: "1.10.0"}, []string{"device-1", "device-2"}},
+		{core.Filter{MinFirmware: "1.9.1", MaxFirmware: "2.3"}, []string{"device-2", "device-3"}},
+		{core.Filter{MinFirmware: "v2.3-r5"}, []string{"device-3"}},
+	}
+
+	for _, tt := range tests {
+		results, err := registry.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("Failed to list devices: %v", err)
+		}
+
+		got := make([]string, len(results))
+		for i, device := range results {
+			got[i] = device.ID
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("filter min=%q max=%q: expected %v, got %v", tt.filter.MinFirmware, tt.filter.MaxFirmware, tt.want, got)
+		}
+	}
+}
+
 func TestSQLiteRegistry_List_FilterByMetadata(t *testing.T) {
 	registry := setupTestRegistry(t)
 	defer cleanup(registry)
@@ -359,6 +407,46 @@ func TestSQLiteRegistry_GetNonExistent(t *testing.T) {
 	}
 }
 
+func TestSQLiteRegistry_Relays(t *testing.T) {
+	registry := setupTestRegistry(t)
+	defer cleanup(registry)
+
+	ctx := context.Background()
+
+	if _, err := registry.GetRelay(ctx, "store-1"); err != core.ErrRelayNotFound {
+		t.Errorf("Expected ErrRelayNotFound, got %v", err)
+	}
+
+	registry.SetRelay(ctx, core.Relay{Location: "store-1", Address: "http://10.1.0.5:8090"})
+	registry.SetRelay(ctx, core.Relay{Location: "store-2", Address: "http://10.2.0.5:8090"})
+
+	// Assigning a new relay replaces the old one
+	if err := registry.SetRelay(ctx, core.Relay{Location: "store-1", Address: "http://10.1.0.6:8090"}); err != nil {
+		t.Fatalf("Failed to set relay: %v", err)
+	}
+	relay, err := registry.GetRelay(ctx, "store-1")
+	if err != nil {
+		t.Fatalf("Failed to get relay: %v", err)
+	}
+	if relay.Address != "http://10.1.0.6:8090" {
+		t.Errorf("Expected the reassigned relay, got %s", relay.Address)
+	}
+
+	if err := registry.RemoveRelay(ctx, "store-2"); err != nil {
+		t.Fatalf("Failed to remove relay: %v", err)
+	}
+	relays, err := registry.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list relays: %v", err)
+	}
+	if len(relays) != 1 || relays[0].Location != "store-1" {
+		t.Errorf("Expected only store-1's relay, got %v", relays)
+	}
+	if err := registry.RemoveRelay(ctx, "store-2"); err != core.ErrRelayNotFound {
+		t.Errorf("Expected ErrRelayNotFound, got %v", err)
+	}
+}
+
 // Helper functions
 
 func setupTestRegistry(t *testing.T) *Registry {
@@ -0,0 +1,188 @@
+// Package relay implements the server side of store-local relays (see
+// core.Relay). The orchestrator uploads each payload to a location's relay
+// once, then asks the relay to push it to each device in the location. The
+// relay streams the bytes it sends back, so progress is recorded against the
+// device receiving them.
+package relay
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
+)
+
+// Relay API paths.
+const (
+	PayloadsPath = "/payloads/" // HEAD or PUT /payloads/<sha256>
+	PushPath     = "/push"      // POST a PushRequest
+)
+
+// PushRequest asks a relay to push a stored payload to a device.
+type PushRequest struct {
+	Device            core.Device            `json:"device"`
+	SHA256            string                 `json:"sha256"` // Payload to push
+	Manifest          []byte                 `json:"manifest,omitempty"`
+	ManifestSignature []byte                 `json:"manifest_signature,omitempty"`
+	Delta             *delivery.DeltaPayload `json:"delta,omitempty"` // Set if the payload is a patch
+}
+
+// PushStatus is one line of the newline-delimited JSON response to a push.
+// Lines report the bytes sent so far; the last line has Done set.
+type PushStatus struct {
+	BytesSent     int64  `json:"bytes_sent"`
+	Done          bool   `json:"done,omitempty"`
+	Error         string `json:"error,omitempty"`          // Why the push failed
+	DeltaRejected bool   `json:"delta_rejected,omitempty"` // The device rejected a patch
+}
+
+// Config holds relay server configuration.
+type Config struct {
+	// Cache stores the payloads uploaded to the relay.
+	Cache *cache.Cache
+
+	// Delivery pushes payloads to devices.
+	Delivery delivery.Delivery
+
+	// Token, if set, must be sent by clients as "Authorization: Bearer <Token>".
+	Token string
+}
+
+// Server is the relay HTTP API.
+type Server struct {
+	config *Config
+	mux    *http.ServeMux
+}
+
+// NewServer creates a relay server.
+func NewServer(config *Config) (*Server, error) {
+	if config == nil || config.Cache == nil {
+		return nil, errors.New("relay cache is required")
+	}
+	if config.Delivery == nil {
+		return nil, errors.New("relay delivery is required")
+	}
+
+	s := &Server{config: config, mux: http.NewServeMux()}
+	s.mux.HandleFunc(PayloadsPath, s.handlePayload)
+	s.mux.HandleFunc(PushPath, s.handlePush)
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.config.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.config.Token {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// handlePayload reports (HEAD) or stores (PUT) a payload by digest.
+func (s *Server) handlePayload(w http.ResponseWriter, r *http.Request) {
+	sha256 := strings.ToLower(strings.TrimPrefix(r.URL.Path, PayloadsPath))
+	if sum, err := hex.DecodeString(sha256); err != nil || len(sum) != 32 {
+		http.Error(w, "Invalid payload digest", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		handle, ok := s.config.Cache.Get(sha256)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		defer handle.Release()
+		w.Header().Set("Content-Length", fmt.Sprint(handle.Size()))
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodPut:
+		// A payload is only cached once it proves to have its digest
+		handle, err := s.config.Cache.PutExpect(r.Context(), r.Body, sha256)
+		if errors.Is(err, cache.ErrDigestMismatch) {
+			http.Error(w, "Payload digest mismatch", http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// The payload stays cached, unreferenced, until pushes need it
+		handle.Release()
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePush pushes a stored payload to a device, streaming PushStatus lines.
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid push request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	handle, ok := s.config.Cache.Get(strings.ToLower(req.SHA256))
+	if !ok {
+		http.Error(w, "Payload not stored", http.StatusNotFound)
+		return
+	}
+	defer handle.Release()
+
+	ctx := delivery.WithPayloadDigest(r.Context(), delivery.Digest{SHA256: handle.SHA256(), Size: handle.Size()})
+	if len(req.Manifest) > 0 {
+		ctx = delivery.WithManifest(ctx, delivery.SignedManifest{Manifest: req.Manifest, Signature: req.ManifestSignature})
+	}
+	if req.Delta != nil {
+		ctx = delivery.WithDelta(ctx, *req.Delta)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	stream := &statusStream{w: w, enc: json.NewEncoder(w)}
+
+	ctx = delivery.WithProgress(ctx, func(sent int64) {
+		stream.send(PushStatus{BytesSent: sent})
+	})
+	err := s.config.Delivery.Push(ctx, req.Device, io.NewSectionReader(handle, 0, handle.Size()))
+
+	status := PushStatus{Done: true}
+	if err != nil {
+		status.Error = err.Error()
+		status.DeltaRejected = errors.Is(err, delivery.ErrDeltaRejected)
+	}
+	stream.send(status)
+}
+
+// statusStream writes PushStatus lines, flushing each one.
+type statusStream struct {
+	mu  sync.Mutex
+	w   http.ResponseWriter
+	enc *json.Encoder
+}
+
+func (s *statusStream) send(status PushStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.enc.Encode(status)
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,137 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+// newTestServer creates a relay server over a mock delivery.
+func newTestServer(t *testing.T, token string) (*httptest.Server, *mocks.MockDelivery) {
+	t.Helper()
+
+	payloadCache, err := cache.New(&cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	mock := mocks.NewMockDelivery()
+	server, err := NewServer(&Config{Cache: payloadCache, Delivery: mock, Token: token})
+	if err != nil {
+		t.Fatalf("Failed to create relay: %v", err)
+	}
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts, mock
+}
+
+// request sends an authorized request to the relay.
+func request(t *testing.T, method, url, token string, body []byte) *http.Response {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestServer_StoresAndPushesPayload(t *testing.T) {
+	ts, mock := newTestServer(t, "secret")
+
+	payload := []byte("firmware")
+	sum := sha256.Sum256(payload)
+	sha := hex.EncodeToString(sum[:])
+
+	if resp := request(t, http.MethodHead, ts.URL+PayloadsPath+sha, "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the token, got %d", resp.StatusCode)
+	}
+	if resp := request(t, http.MethodHead, ts.URL+PayloadsPath+sha, "secret", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 before upload, got %d", resp.StatusCode)
+	}
+	if resp := request(t, http.MethodPut, ts.URL+PayloadsPath+sha, "secret", payload); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 for upload, got %d", resp.StatusCode)
+	}
+
+	body, _ := json.Marshal(PushRequest{Device: core.Device{ID: "device-1"}, SHA256: sha})
+	resp := request(t, http.MethodPost, ts.URL+PushPath, "secret", body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for push, got %d", resp.StatusCode)
+	}
+
+	var last PushStatus
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatalf("Invalid status line %q: %v", scanner.Text(), err)
+		}
+	}
+	if !last.Done || last.Error != "" {
+		t.Errorf("Expected a successful final status, got %+v", last)
+	}
+	if mock.GetPushCount() != 1 {
+		t.Errorf("Expected 1 push, got %d", mock.GetPushCount())
+	}
+}
+
+func TestServer_RejectsMismatchedPayload(t *testing.T) {
+	ts, _ := newTestServer(t, "")
+
+	sum := sha256.Sum256([]byte("firmware"))
+	sha := hex.EncodeToString(sum[:])
+
+	if resp := request(t, http.MethodPut, ts.URL+PayloadsPath+sha, "", []byte("tampered")); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a mismatched payload, got %d", resp.StatusCode)
+	}
+
+	// The mismatched payload is not cached under its own digest either
+	tampered := sha256.Sum256([]byte("tampered"))
+	if resp := request(t, http.MethodHead, ts.URL+PayloadsPath+hex.EncodeToString(tampered[:]), "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the mismatched payload to be discarded, got %d", resp.StatusCode)
+	}
+	body, _ := json.Marshal(PushRequest{Device: core.Device{ID: "device-1"}, SHA256: sha})
+	if resp := request(t, http.MethodPost, ts.URL+PushPath, "", body); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 pushing a payload not stored, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ReportsRejectedDelta(t *testing.T) {
+	ts, mock := newTestServer(t, "")
+	mock.RejectDeltas = true
+
+	payload := []byte("patch")
+	sum := sha256.Sum256(payload)
+	sha := hex.EncodeToString(sum[:])
+	request(t, http.MethodPut, ts.URL+PayloadsPath+sha, "", payload)
+
+	body, _ := json.Marshal(PushRequest{
+		Device: core.Device{ID: "device-1"},
+		SHA256: sha,
+		Delta:  &delivery.DeltaPayload{BaseVersion: "1.0.0", TargetVersion: "2.0.0"},
+	})
+	resp := request(t, http.MethodPost, ts.URL+PushPath, "", body)
+
+	var last PushStatus
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		json.Unmarshal(scanner.Bytes(), &last)
+	}
+	if !last.Done || !last.DeltaRejected {
+		t.Errorf("Expected a rejected delta status, got %+v", last)
+	}
+}
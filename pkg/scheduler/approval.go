@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+)
+
+// gateHold is a rollout held between phases until an operator releases it.
+type gateHold struct {
+	phase string        // Phase the hold applies to
+	done  chan struct{} // Closed when the hold is released
+	err   error         // Why the rollout must stop (nil to continue)
+}
+
+// Approve releases an update awaiting approval for the given phase, letting
+// the rollout continue. The decision is recorded in the update's history.
+func (s *Scheduler) Approve(ctx context.Context, updateID, phase, approver string) error {
+	return s.decide(ctx, updateID, phase, approver, true, "")
+}
+
+// Reject stops an update awaiting approval for the given phase. The update
+// fails with core.ErrUpdateRejected (and is rolled back if it has a rollback
+// payload). The decision is recorded in the update's history.
+func (s *Scheduler) Reject(ctx context.Context, updateID, phase, approver, reason string) error {
+	return s.decide(ctx, updateID, phase, approver, false, reason)
+}
+
+// History returns the approval decisions recorded for an update, oldest first.
+func (s *Scheduler) History(ctx context.Context, updateID string) ([]core.Approval, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scheduled, exists := s.updates[updateID]
+	if !exists {
+		return nil, fmt.Errorf("update %s not found", updateID)
+	}
+
+	return append([]core.Approval(nil), scheduled.approvals...), nil
+}
+
+// decide records an approval decision and releases the held rollout.
+func (s *Scheduler) decide(ctx context.Context, updateID, phase, approver string, approved bool, reason string) error {
+	if approver == "" {
+		return fmt.Errorf("approver is required")
+	}
+
+	s.mu.Lock()
+	scheduled, exists := s.updates[updateID]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("update %s not found", updateID)
+	}
+
+	if scheduled.status != core.StatusAwaitingApproval || scheduled.hold == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("update %s is not awaiting approval", updateID)
+	}
+	if scheduled.hold.phase != phase {
+		s.mu.Unlock()
+		return fmt.Errorf("update %s is awaiting approval for phase %q, not %q", updateID, scheduled.hold.phase, phase)
+	}
+
+	approval := core.Approval{
+		Phase:    phase,
+		Approver: approver,
+		Approved: approved,
+		Reason:   reason,
+		Time:     time.Now(),
+	}
+	scheduled.approvals = append(scheduled.approvals, approval)
+
+	var err error
+	eventType := events.EventPhaseApproved
+	if !approved {
+		err = fmt.Errorf("%w: phase %q rejected by %s", core.ErrUpdateRejected, phase, approver)
+		eventType = events.EventPhaseRejected
+	}
+	s.releaseHold(scheduled, err)
+	s.mu.Unlock()
+
+	data := map[string]interface{}{
+		"phase":    phase,
+		"approver": approver,
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	s.orchestrator.Publish(ctx, events.Event{
+		Type:      eventType,
+		UpdateID:  updateID,
+		Timestamp: approval.Time,
+		Data:      data,
+		Error:     err,
+	})
+
+	return nil
+}
+
+// awaitApproval holds a running update until an operator approves or rejects
+// the given phase.
+func (s *Scheduler) awaitApproval(ctx context.Context, update core.Update, index int, phase core.RolloutPhase) error {
+	s.publishPhase(ctx, events.EventPhaseAwaitingApproval, update.ID, index, phase, map[string]interface{}{})
+	return s.holdUpdate(ctx, update.ID, core.StatusAwaitingApproval, phase.Name)
+}
+
+// holdUpdate parks a running update in the given status (paused or awaiting
+// approval) until the hold is released. It returns the error the hold was
+// released with, or core.ErrCancelled if the update is cancelled or the
+// scheduler stops while held. A stopped scheduler leaves the update held in
+// the store, so it is held again once recovered.
+func (s *Scheduler) holdUpdate(ctx context.Context, updateID string, status core.UpdateStatus, phase string) error {
+	hold := &gateHold{
+		phase: phase,
+		done:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	scheduled := s.updates[updateID]
+	if scheduled.status != core.StatusPaused {
+		// An update already paused by an operator keeps its original pause
+		now := time.Now()
+		scheduled.pausedAt = &now
+		scheduled.pausedFrom = scheduled.status
+	}
+	scheduled.status = status
+	scheduled.hold = hold
+	s.save(scheduled)
+	s.mu.Unlock()
+
+	select {
+	case <-hold.done:
+		return hold.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		if scheduled.hold == hold {
+			scheduled.hold = nil
+		}
+		s.mu.Unlock()
+		return core.ErrCancelled
+	}
+}
+
+// releaseHold restores a paused or held update to the status it was held
+// from and wakes up a rollout waiting on a gate. The caller must hold s.mu.
+func (s *Scheduler) releaseHold(scheduled *scheduledUpdate, err error) {
+	if scheduled.hold != nil {
+		scheduled.hold.err = err
+		close(scheduled.hold.done)
+		scheduled.hold = nil
+	}
+
+	scheduled.status = scheduled.pausedFrom
+	scheduled.pausedAt = nil
+	s.save(scheduled)
+}
+
+// phaseApproved reports whether an operator already approved a phase (e.g.,
+// before a restart interrupted the rollout).
+func (s *Scheduler) phaseApproved(updateID, phase string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, approval := range s.updates[updateID].approvals {
+		if approval.Phase == phase && approval.Approved {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+func TestScheduler_ApproveContinuesRollout(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "approval",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20},
+			{Name: "Stores", Percentage: 80, RequiresApproval: true},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "approval", core.StatusAwaitingApproval)
+
+	status, err := scheduler.Status(ctx, "approval")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.Status != core.StatusAwaitingApproval || status.PendingApproval != "Stores" {
+		t.Errorf("Expected to await approval for 'Stores', got %s / %q", status.Status, status.PendingApproval)
+	}
+
+	// The gated phase must not start before approval
+	time.Sleep(50 * time.Millisecond)
+	if delivery.GetPushCount() != 2 {
+		t.Fatalf("Expected only 2 canary pushes before approval, got %d", delivery.GetPushCount())
+	}
+
+	if err := scheduler.Resume(ctx, "approval"); err == nil {
+		t.Error("Expected Resume to refuse an update awaiting approval")
+	}
+	if err := scheduler.Approve(ctx, "approval", "Canary", "ops@example.com"); err == nil {
+		t.Error("Expected error approving the wrong phase")
+	}
+
+	if err := scheduler.Approve(ctx, "approval", "Stores", "ops@example.com"); err != nil {
+		t.Fatalf("Failed to approve: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "approval", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 10 {
+		t.Errorf("Expected 10 pushes after approval, got %d", delivery.GetPushCount())
+	}
+
+	history, err := scheduler.History(ctx, "approval")
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 approval in history, got %d", len(history))
+	}
+	if history[0].Phase != "Stores" || history[0].Approver != "ops@example.com" || !history[0].Approved {
+		t.Errorf("Unexpected approval record: %+v", history[0])
+	}
+	if history[0].Time.IsZero() {
+		t.Error("Expected approval timestamp to be recorded")
+	}
+}
+
+func TestScheduler_RejectFailsAndRollsBack(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:                 "rejected",
+		PayloadURL:         writePayload(t, "firmware v2.0"),
+		RollbackPayloadURL: writePayload(t, "firmware v1.0"),
+		Strategy:           core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20},
+			{Name: "Stores", Percentage: 80, RequiresApproval: true},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "rejected", core.StatusAwaitingApproval)
+
+	if err := scheduler.Reject(ctx, "rejected", "Stores", "ops@example.com", "canary tills froze"); err != nil {
+		t.Fatalf("Failed to reject: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "rejected", core.StatusFailed)
+	waitForStatus(t, scheduler, "rejected-rollback", core.StatusCompleted)
+
+	// 2 canary pushes + 2 rollback pushes
+	if delivery.GetPushCount() != 4 {
+		t.Errorf("Expected 4 pushes, got %d", delivery.GetPushCount())
+	}
+
+	status, err := scheduler.Status(ctx, "rejected")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if len(status.Approvals) != 1 || status.Approvals[0].Approved || status.Approvals[0].Reason != "canary tills froze" {
+		t.Errorf("Expected rejection with reason in history, got %+v", status.Approvals)
+	}
+}
+
+func TestScheduler_StopWhileAwaitingApproval(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "stopped-approval",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20},
+			{Name: "Stores", Percentage: 80, RequiresApproval: true},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	waitForStatus(t, scheduler, "stopped-approval", core.StatusAwaitingApproval)
+
+	// Stopping releases the hold without deciding on the phase
+	stopWithin(t, scheduler, 5*time.Second)
+
+	scheduler.mu.RLock()
+	scheduled := scheduler.updates["stopped-approval"]
+	held := scheduled.hold != nil
+	approvals := len(scheduled.approvals)
+	scheduler.mu.RUnlock()
+	if held {
+		t.Error("Expected the hold to be released by Stop")
+	}
+	if approvals != 0 {
+		t.Errorf("Expected no approval decision, got %d", approvals)
+	}
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected only 2 canary pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_ApproveNotAwaiting(t *testing.T) {
+	scheduler := setupTestScheduler(t)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:       "not-awaiting",
+		Strategy: core.StrategyImmediate,
+	}
+	scheduler.Schedule(ctx, update)
+
+	if err := scheduler.Approve(ctx, "not-awaiting", "Canary", "ops@example.com"); err == nil {
+		t.Error("Expected error approving an update that is not awaiting approval")
+	}
+	if err := scheduler.Approve(ctx, "missing", "Canary", "ops@example.com"); err == nil {
+		t.Error("Expected error approving an unknown update")
+	}
+	if err := scheduler.Approve(ctx, "not-awaiting", "Canary", ""); err == nil {
+		t.Error("Expected error approving without an approver")
+	}
+}
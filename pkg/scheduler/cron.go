@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronHorizon bounds the search for the next firing, so expressions that can
+// never fire (e.g., "0 0 30 2 *") do not loop forever.
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// cronMacros are the predefined schedules accepted in place of an expression.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronSchedule is a parsed five-field cron expression.
+type cronSchedule struct {
+	minutes [60]bool
+	hours   [24]bool
+	doms    [32]bool // Days of the month, 1-31
+	months  [13]bool // 1-12
+	dows    [7]bool  // Days of the week, Sunday = 0
+
+	// A day matches if either day field matches, unless one of them is "*"
+	domAny bool
+	dowAny bool
+
+	loc *time.Location
+}
+
+// parseCron parses a cron expression evaluated in the given time zone.
+func parseCron(expr, zone string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression %q, got %d", expr, len(fields))
+	}
+
+	if zone == "" {
+		zone = "UTC"
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", zone, err)
+	}
+
+	c := &cronSchedule{
+		loc:    loc,
+		domAny: fields[2] == "*" || fields[2] == "?",
+		dowAny: fields[4] == "*" || fields[4] == "?",
+	}
+
+	if err := parseCronField(fields[0], 0, 59, nil, c.minutes[:]); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, nil, c.hours[:]); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, nil, c.doms[:]); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, monthNames, c.months[:]); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	// Day of week accepts 7 as Sunday
+	var dows [8]bool
+	if err := parseCronField(fields[4], 0, 7, dayNames, dows[:]); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	copy(c.dows[:], dows[:7])
+	c.dows[0] = c.dows[0] || dows[7]
+
+	return c, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges ("1-5"),
+// wildcards and steps ("*/15", "10-50/10") into set. names, if given, are
+// accepted in place of numbers starting at min.
+func parseCronField(field string, min, max int, names []string, set []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], min, names); err != nil {
+				return err
+			}
+			if hi, err = parseCronValue(bounds[1], min, names); err != nil {
+				return err
+			}
+		default:
+			value, err := parseCronValue(part, min, names)
+			if err != nil {
+				return err
+			}
+			lo = value
+			if step == 1 {
+				hi = value // A single value; "5/15" instead means every 15 from 5
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// parseCronValue parses a single number or name.
+func parseCronValue(s string, min int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// next returns the first firing strictly after t, or the zero time if the
+// schedule does not fire within cronHorizon.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	limit := t.Add(cronHorizon)
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		switch {
+		case !c.months[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case !c.hours[t.Hour()]:
+			// Absolute arithmetic so hours skipped or repeated by DST
+			// transitions cannot stall the search
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// dayMatches reports whether the schedule fires on t's day. As in cron, if
+// both day fields are restricted a day matching either one fires.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.doms[t.Day()]
+	dow := c.dows[t.Weekday()]
+
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
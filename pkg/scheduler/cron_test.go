@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	// Wednesday
+	from := time.Date(2026, 3, 4, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 15, 0, 0, time.UTC)},
+		{"0 2 * * 1-5", time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * SAT,SUN", time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 4 1 jan *", time.Date(2027, 1, 1, 4, 30, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches
+		{"0 0 15 * FRI", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2026, 3, 4, 10, 25, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := parseCron(tt.expr, "")
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+			}
+			if got := c.next(from); !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCron_TimeZone(t *testing.T) {
+	c, err := parseCron("0 2 * * *", "America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// 02:00 EST is 07:00 UTC
+	got := c.next(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	want := time.Date(2026, 1, 16, 7, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("next = %s, want %s", got, want)
+	}
+
+	// 02:30 does not exist on the spring-forward day, so it fires the next day
+	c, err = parseCron("30 2 * * *", "America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	got = c.next(time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC))
+	want = time.Date(2026, 3, 9, 6, 30, 0, 0, time.UTC) // 02:30 EDT
+	if !got.Equal(want) {
+		t.Errorf("next across DST = %s, want %s", got, want)
+	}
+}
+
+func TestCron_NeverFires(t *testing.T) {
+	c, err := parseCron("0 0 30 2 *", "")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if got := c.next(time.Now()); !got.IsZero() {
+		t.Errorf("Expected no firing for February 30th, got %s", got)
+	}
+}
+
+func TestCron_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * funday",
+		"@fortnightly",
+	}
+
+	for _, expr := range tests {
+		if _, err := parseCron(expr, ""); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+
+	if _, err := parseCron("@daily", "Mars/Olympus_Mons"); err == nil {
+		t.Error("Expected error for unknown time zone")
+	}
+}
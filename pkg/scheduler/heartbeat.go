@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+)
+
+// connectBuffer is how many check-ins an on-connect update queues before
+// dropping them (a dropped device is picked up on its next heartbeat).
+const connectBuffer = 64
+
+// onConnectRun is an active on-connect update waiting for its target
+// devices to check in.
+type onConnectRun struct {
+	targets  map[string]bool  // Devices the update targets
+	connects chan core.Device // Devices that checked in
+}
+
+// Heartbeat records that a device checked in. The device is marked online in
+// the registry (with its reported firmware version, if given), EventDeviceConnected
+// is emitted if it was not online before, and any active on-connect update
+// targeting the device is pushed to it.
+func (s *Scheduler) Heartbeat(ctx context.Context, deviceID, firmwareVersion string) error {
+	device, err := s.registry.Get(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get device %s: %w", deviceID, err)
+	}
+
+	wasOnline := device.Status == core.DeviceOnline
+	now := time.Now()
+	device.Status = core.DeviceOnline
+	device.LastSeen = &now
+	if firmwareVersion != "" {
+		device.FirmwareVersion = firmwareVersion
+	}
+
+	if err := s.registry.Update(ctx, *device); err != nil {
+		return fmt.Errorf("failed to update device %s: %w", deviceID, err)
+	}
+
+	if !wasOnline {
+		s.orchestrator.Publish(ctx, events.Event{
+			Type:      events.EventDeviceConnected,
+			DeviceID:  deviceID,
+			Timestamp: now,
+			Data: map[string]interface{}{
+				"device_address":   device.Address,
+				"firmware_version": device.FirmwareVersion,
+			},
+		})
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, scheduled := range s.updates {
+		run := scheduled.onConnect
+		if run == nil || !run.targets[deviceID] {
+			continue
+		}
+		select {
+		case run.connects <- *device:
+		default:
+			// Queue full; the device is picked up on its next heartbeat
+		}
+	}
+
+	return nil
+}
+
+// executeOnConnect pushes the update to target devices that are online
+// when it starts, and to the others as they check in. A device is updated at
+// most once; devices whose update fails are retried on their next check-in,
+// up to Config.MaxOnConnectAttempts pushes, and then left failed. The update
+// finishes once every target device has been updated or left failed.
+func (s *Scheduler) executeOnConnect(ctx context.Context, update core.Update) error {
+	devices, err := s.orchestrator.ResolveDevices(ctx, update)
+	if err != nil {
+		return err
+	}
+
+	if len(devices) == 0 {
+		return fmt.Errorf("no devices match filter")
+	}
+
+	staged, err := s.stagePayload(ctx, update)
+	if err != nil {
+		return err
+	}
+
+	runCtx, err := s.beginUpdate(ctx, update, devices)
+	if err != nil {
+		return err
+	}
+	defer s.orchestrator.FinishUpdate(ctx, update)
+
+	run := &onConnectRun{
+		targets:  make(map[string]bool, len(devices)),
+		connects: make(chan core.Device, connectBuffer),
+	}
+	devices = s.remainingDevices(update.ID, devices)
+	for _, device := range devices {
+		run.targets[device.ID] = true
+	}
+
+	// Devices that check in outside their update window are retried on
+	// a later check-in
+	if update.Window != nil {
+		gate, err := s.windowGate(update, devices)
+		if err != nil {
+			return err
+		}
+		runCtx = orchestrator.WithDeviceGate(runCtx, gate)
+	}
+
+	s.setOnConnect(update.ID, run)
+	defer s.setOnConnect(update.ID, nil)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	results := make(chan string)
+	attempted := make(map[string]bool, len(devices))
+	failures := make(map[string]int, len(devices))
+	remaining := len(devices)
+
+	push := func(device core.Device) {
+		if attempted[device.ID] {
+			return // Already updated or updating (e.g., a flapping device)
+		}
+		attempted[device.ID] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.orchestrator.ExecuteOnDevices(runCtx, update, []core.Device{device}, staged.Shared())
+			select {
+			case results <- device.ID:
+			case <-runCtx.Done():
+			}
+		}()
+	}
+
+	// Devices already online need not wait for their next heartbeat
+	for _, device := range devices {
+		if device.Status == core.DeviceOnline {
+			push(device)
+		}
+	}
+
+	for remaining > 0 {
+		select {
+		case device := <-run.connects:
+			push(device)
+
+		case deviceID := <-results:
+			switch s.deviceStatus(ctx, update.ID, deviceID) {
+			case core.StatusCompleted:
+				remaining--
+			case core.StatusFailed:
+				failures[deviceID]++
+				if limit := s.config.MaxOnConnectAttempts; limit > 0 && failures[deviceID] >= limit {
+					remaining-- // Left failed
+					continue
+				}
+				delete(attempted, deviceID) // Retry on the next check-in
+			default:
+				delete(attempted, deviceID) // Held by the window; retry on the next check-in
+			}
+
+		case <-runCtx.Done():
+			return core.ErrCancelled
+		}
+	}
+
+	return nil
+}
+
+// setOnConnect registers (or clears) the active on-connect run of an update.
+func (s *Scheduler) setOnConnect(updateID string, run *onConnectRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if scheduled, exists := s.updates[updateID]; exists {
+		scheduled.onConnect = run
+	}
+}
+
+// deviceStatus returns the status of a device's update, or "" if unknown.
+func (s *Scheduler) deviceStatus(ctx context.Context, updateID, deviceID string) core.UpdateStatus {
+	status, err := s.orchestrator.GetStatus(ctx, updateID)
+	if err != nil {
+		return ""
+	}
+	return core.UpdateStatus(status.DeviceStatus[deviceID])
+}
@@ -0,0 +1,318 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+)
+
+func TestScheduler_HeartbeatMarksDeviceOnline(t *testing.T) {
+	scheduler, _ := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+
+	device, _ := scheduler.registry.Get(ctx, "device-1")
+	device.Status = core.DeviceOffline
+	scheduler.registry.Update(ctx, *device)
+
+	var connected atomic.Int64
+	scheduler.orchestrator.Subscribe(events.EventDeviceConnected, events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		if event.DeviceID == "device-1" {
+			connected.Add(1)
+		}
+	}))
+
+	if err := scheduler.Heartbeat(ctx, "device-1", "v1.2.0"); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	// Already online: no second connected event
+	if err := scheduler.Heartbeat(ctx, "device-1", ""); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	device, _ = scheduler.registry.Get(ctx, "device-1")
+	if device.Status != core.DeviceOnline {
+		t.Errorf("Expected device online, got %s", device.Status)
+	}
+	if device.LastSeen == nil || time.Since(*device.LastSeen) > time.Minute {
+		t.Errorf("Expected LastSeen to be updated, got %v", device.LastSeen)
+	}
+	if device.FirmwareVersion != "v1.2.0" {
+		t.Errorf("Expected reported firmware v1.2.0, got %s", device.FirmwareVersion)
+	}
+
+	time.Sleep(20 * time.Millisecond) // Handlers run asynchronously
+	if connected.Load() != 1 {
+		t.Errorf("Expected 1 device.connected event, got %d", connected.Load())
+	}
+
+	if err := scheduler.Heartbeat(ctx, "unknown", ""); err == nil {
+		t.Error("Expected error for unknown device")
+	}
+}
+
+func TestScheduler_OnConnectUpdatesDevicesAsTheyCheckIn(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 3)
+	ctx := context.Background()
+	setOffline(t, scheduler, "device-1", "device-2", "device-3")
+
+	update := core.Update{
+		ID:         "on-connect",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		DeviceIDs:  []string{"device-1", "device-2"},
+		Strategy:   core.StrategyOnConnect,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForOnConnect(t, scheduler, "on-connect")
+
+	if delivery.GetPushCount() != 0 {
+		t.Fatalf("Expected no pushes before any device checks in, got %d", delivery.GetPushCount())
+	}
+
+	// A flapping device checks in repeatedly but is updated once
+	for i := 0; i < 3; i++ {
+		if err := scheduler.Heartbeat(ctx, "device-1", ""); err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+	}
+	waitForPushes(t, delivery, 1)
+
+	// Devices not targeted by the update are ignored
+	scheduler.Heartbeat(ctx, "device-3", "")
+
+	time.Sleep(50 * time.Millisecond)
+	scheduler.Heartbeat(ctx, "device-1", "")
+	time.Sleep(20 * time.Millisecond)
+	if delivery.GetPushCount() != 1 {
+		t.Fatalf("Expected device-1 to be updated once, got %d pushes", delivery.GetPushCount())
+	}
+
+	if err := scheduler.Heartbeat(ctx, "device-2", ""); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "on-connect", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_OnConnectRetriesFailedDevice(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+	setOffline(t, scheduler, "device-1")
+
+	delivery.ShouldFail = true
+
+	update := core.Update{
+		ID:         "on-connect-retry",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyOnConnect,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForOnConnect(t, scheduler, "on-connect-retry")
+
+	scheduler.Heartbeat(ctx, "device-1", "")
+	waitForPushes(t, delivery, 1)
+
+	// Wait for the failure to be recorded, then let the next check-in succeed
+	deadline := time.Now().Add(5 * time.Second)
+	for !deviceFailed(scheduler, "on-connect-retry", "device-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for device-1 to fail")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	delivery.ShouldFail = false
+
+	// Keep checking in until the retry is picked up
+	for delivery.GetPushCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for device-1 to be retried")
+		}
+		scheduler.Heartbeat(ctx, "device-1", "")
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForStatus(t, scheduler, "on-connect-retry", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 pushes (failure + retry), got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_OnConnectPushesOnlineDevicesImmediately(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 2)
+	ctx := context.Background()
+	setOffline(t, scheduler, "device-2")
+
+	update := core.Update{
+		ID:         "on-connect-online",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyOnConnect,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	// device-1 is online, so it is updated without checking in
+	waitForPushes(t, delivery, 1)
+	time.Sleep(20 * time.Millisecond)
+	if delivery.GetPushCount() != 1 {
+		t.Fatalf("Expected only the online device to be updated, got %d pushes", delivery.GetPushCount())
+	}
+
+	scheduler.Heartbeat(ctx, "device-2", "")
+	waitForStatus(t, scheduler, "on-connect-online", core.StatusCompleted)
+}
+
+func TestScheduler_OnConnectGivesUpAfterMaxAttempts(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+	scheduler.config.MaxOnConnectAttempts = 2
+
+	delivery.ShouldFail = true
+
+	update := core.Update{
+		ID:         "on-connect-give-up",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyOnConnect,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	var finished atomic.Int64
+	scheduler.orchestrator.Subscribe(events.EventUpdateCompleted, events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		finished.Add(1)
+	}))
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	// The first push is immediate; keep checking in until it is retried
+	waitForPushes(t, delivery, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for delivery.GetPushCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for device-1 to be retried")
+		}
+		scheduler.Heartbeat(ctx, "device-1", "")
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// After the second failure the device is left failed and the update ends
+	waitForStatus(t, scheduler, "on-connect-give-up", core.StatusCompleted)
+	if !deviceFailed(scheduler, "on-connect-give-up", "device-1") {
+		t.Error("Expected device-1 to be recorded as failed")
+	}
+
+	scheduler.Heartbeat(ctx, "device-1", "")
+	time.Sleep(20 * time.Millisecond)
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 pushes, got %d", delivery.GetPushCount())
+	}
+	if finished.Load() != 1 {
+		t.Errorf("Expected the update to finish once, got %d events", finished.Load())
+	}
+}
+
+func TestScheduler_StopInterruptsOnConnect(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+	setOffline(t, scheduler, "device-1")
+
+	update := core.Update{
+		ID:         "on-connect-stop",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyOnConnect,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	waitForOnConnect(t, scheduler, "on-connect-stop")
+
+	// device-1 never checks in, so only Stop ends the update
+	stopWithin(t, scheduler, 5*time.Second)
+
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+// setOffline marks devices offline, so on-connect updates wait for them.
+func setOffline(t *testing.T, s *Scheduler, deviceIDs ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+	for _, deviceID := range deviceIDs {
+		device, err := s.registry.Get(ctx, deviceID)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", deviceID, err)
+		}
+		device.Status = core.DeviceOffline
+		if err := s.registry.Update(ctx, *device); err != nil {
+			t.Fatalf("Failed to update %s: %v", deviceID, err)
+		}
+	}
+}
+
+// waitForOnConnect waits until an on-connect update is listening for heartbeats.
+func waitForOnConnect(t *testing.T, s *Scheduler, updateID string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		active := s.updates[updateID].onConnect != nil
+		s.mu.RUnlock()
+
+		if active {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for on-connect update %s to start", updateID)
+}
+
+// deviceFailed reports whether the orchestrator recorded a device as failed.
+func deviceFailed(s *Scheduler, updateID, deviceID string) bool {
+	status, err := s.orchestrator.GetStatus(context.Background(), updateID)
+	return err == nil && status.DeviceStatus[deviceID] == string(core.StatusFailed)
+}
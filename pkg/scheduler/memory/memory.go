@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/scheduler"
+)
+
+// Store implements an in-memory scheduler store. State survives restarting
+// a scheduler within the same process (e.g., in tests), not the process.
+type Store struct {
+	mu          sync.RWMutex
+	records     map[string]scheduler.Record
+	devices     map[string]map[string]scheduler.DeviceOutcome
+	transitions map[string][]scheduler.Transition
+}
+
+// New creates a new in-memory scheduler store.
+func New() *Store {
+	return &Store{
+		records:     make(map[string]scheduler.Record),
+		devices:     make(map[string]map[string]scheduler.DeviceOutcome),
+		transitions: make(map[string][]scheduler.Transition),
+	}
+}
+
+// Save creates or replaces an update's record.
+func (s *Store) Save(ctx context.Context, record scheduler.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := record.Update.ID
+	previous, exists := s.records[id]
+	if !exists || previous.Status != record.Status {
+		s.transitions[id] = append(s.transitions[id], scheduler.Transition{
+			Status: record.Status,
+			Time:   time.Now(),
+		})
+	}
+
+	record.Devices = nil
+	record.Approvals = append([]core.Approval(nil), record.Approvals...)
+	s.records[id] = record
+
+	return nil
+}
+
+// SaveDevice records the outcome of an update on one device.
+func (s *Store) SaveDevice(ctx context.Context, updateID string, outcome scheduler.DeviceOutcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.records[updateID]; !exists {
+		return fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+	}
+
+	if s.devices[updateID] == nil {
+		s.devices[updateID] = make(map[string]scheduler.DeviceOutcome)
+	}
+	s.devices[updateID][outcome.DeviceID] = outcome
+
+	return nil
+}
+
+// Get returns an update's record, including its device outcomes.
+func (s *Store) Get(ctx context.Context, updateID string) (*scheduler.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.records[updateID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+	}
+
+	record = s.withDevices(record)
+	return &record, nil
+}
+
+// List returns the records of all updates, oldest first.
+func (s *Store) List(ctx context.Context) ([]scheduler.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]scheduler.Record, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, s.withDevices(record))
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.Before(records[j].CreatedAt)
+		}
+		return records[i].Update.ID < records[j].Update.ID
+	})
+
+	return records, nil
+}
+
+// Transitions returns an update's status history, oldest first.
+func (s *Store) Transitions(ctx context.Context, updateID string) ([]scheduler.Transition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.records[updateID]; !exists {
+		return nil, fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+	}
+
+	return append([]scheduler.Transition(nil), s.transitions[updateID]...), nil
+}
+
+// Close is a no-op for the in-memory store.
+func (s *Store) Close() error {
+	return nil
+}
+
+// withDevices returns a copy of a record with its device outcomes attached.
+// The caller must hold s.mu.
+func (s *Store) withDevices(record scheduler.Record) scheduler.Record {
+	record.Approvals = append([]core.Approval(nil), record.Approvals...)
+	record.Devices = make(map[string]scheduler.DeviceOutcome, len(s.devices[record.Update.ID]))
+	for deviceID, outcome := range s.devices[record.Update.ID] {
+		record.Devices[deviceID] = outcome
+	}
+	return record
+}
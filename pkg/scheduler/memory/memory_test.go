@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/scheduler"
+)
+
+func TestMemoryStore_SaveAndGet(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	now := time.Now()
+	record := scheduler.Record{
+		Update:    core.Update{ID: "update-1", Strategy: core.StrategyImmediate},
+		Status:    core.StatusInProgress,
+		CreatedAt: now,
+		StartedAt: &now,
+		Approvals: []core.Approval{{Phase: "Canary", Approver: "ops", Approved: true, Time: now}},
+	}
+
+	if err := store.Save(ctx, record); err != nil {
+		t.Fatalf("Failed to save record: %v", err)
+	}
+	if err := store.SaveDevice(ctx, "update-1", scheduler.DeviceOutcome{DeviceID: "device-1", Status: core.StatusCompleted, Time: now}); err != nil {
+		t.Fatalf("Failed to save device outcome: %v", err)
+	}
+
+	// Saving the record again keeps its device outcomes
+	record.Status = core.StatusCompleted
+	if err := store.Save(ctx, record); err != nil {
+		t.Fatalf("Failed to save record: %v", err)
+	}
+
+	got, err := store.Get(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if got.Status != core.StatusCompleted {
+		t.Errorf("Expected status completed, got %s", got.Status)
+	}
+	if len(got.Approvals) != 1 {
+		t.Errorf("Expected 1 approval, got %+v", got.Approvals)
+	}
+	if len(got.Devices) != 1 || got.Devices["device-1"].Status != core.StatusCompleted {
+		t.Errorf("Expected 1 device outcome, got %+v", got.Devices)
+	}
+
+	// Returned records are copies
+	got.Devices["device-2"] = scheduler.DeviceOutcome{DeviceID: "device-2"}
+	again, _ := store.Get(ctx, "update-1")
+	if len(again.Devices) != 1 {
+		t.Errorf("Expected stored outcomes to be unaffected, got %+v", again.Devices)
+	}
+}
+
+func TestMemoryStore_Transitions(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	record := scheduler.Record{Update: core.Update{ID: "update-1"}, CreatedAt: time.Now()}
+	for _, status := range []core.UpdateStatus{
+		core.StatusPending,
+		core.StatusPending, // Not a transition
+		core.StatusInProgress,
+		core.StatusFailed,
+	} {
+		record.Status = status
+		store.Save(ctx, record)
+	}
+
+	transitions, err := store.Transitions(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("Failed to get transitions: %v", err)
+	}
+
+	expected := []core.UpdateStatus{core.StatusPending, core.StatusInProgress, core.StatusFailed}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected %d transitions, got %d", len(expected), len(transitions))
+	}
+	for i, status := range expected {
+		if transitions[i].Status != status {
+			t.Errorf("Transition %d: expected %s, got %s", i, status, transitions[i].Status)
+		}
+	}
+}
+
+func TestMemoryStore_ListOrdersByCreation(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	base := time.Now()
+	store.Save(ctx, scheduler.Record{Update: core.Update{ID: "second"}, CreatedAt: base.Add(time.Second)})
+	store.Save(ctx, scheduler.Record{Update: core.Update{ID: "first"}, CreatedAt: base})
+
+	records, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(records) != 2 || records[0].Update.ID != "first" || records[1].Update.ID != "second" {
+		t.Errorf("Expected records oldest first, got %+v", records)
+	}
+}
+
+func TestMemoryStore_NotFound(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound from Get, got %v", err)
+	}
+	if _, err := store.Transitions(ctx, "missing"); !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound from Transitions, got %v", err)
+	}
+	err := store.SaveDevice(ctx, "missing", scheduler.DeviceOutcome{DeviceID: "device-1"})
+	if !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound from SaveDevice, got %v", err)
+	}
+}
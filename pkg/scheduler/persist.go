@@ -0,0 +1,252 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+)
+
+// save persists an update's record. Failures are ignored: every save
+// rewrites the whole record, so the next transition repairs a missed one.
+// The caller must hold s.mu.
+func (s *Scheduler) save(scheduled *scheduledUpdate) {
+	if s.store == nil {
+		return
+	}
+	s.store.Save(context.Background(), s.record(scheduled))
+}
+
+// record builds the persisted form of an update. The caller must hold s.mu.
+func (s *Scheduler) record(scheduled *scheduledUpdate) Record {
+	record := Record{
+		Update:     scheduled.update,
+		Status:     scheduled.status,
+		PausedFrom: scheduled.pausedFrom,
+		CreatedAt:  scheduled.createdAt,
+		StartedAt:  scheduled.startedAt,
+		Phase:      scheduled.phase,
+		Approvals:  append([]core.Approval(nil), scheduled.approvals...),
+		RollbackID: scheduled.rollbackID,
+	}
+	if r := scheduled.recurring; r != nil && !r.next.IsZero() {
+		next := r.next
+		record.NextRun = &next
+	}
+	return record
+}
+
+// recordDeviceOutcome persists the outcome of a device update. It is called
+// by the orchestrator as the outcome is reached, so a device's outcomes are
+// saved in the order they happen.
+func (s *Scheduler) recordDeviceOutcome(ctx context.Context, updateID, deviceID string, status core.UpdateStatus, err error) {
+	outcome := DeviceOutcome{
+		DeviceID: deviceID,
+		Status:   status,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+
+	// The outcome stands even if the run is being cancelled
+	s.store.SaveDevice(context.WithoutCancel(ctx), updateID, outcome)
+}
+
+// reconcile restores the updates persisted in the store. Updates that were
+// running when the scheduler last stopped are resumed or failed according to
+// the configured RecoveryPolicy.
+func (s *Scheduler) reconcile(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		if _, exists := s.updates[record.Update.ID]; exists {
+			continue
+		}
+
+		scheduled, err := s.restore(ctx, record)
+		if err != nil {
+			return fmt.Errorf("update %s: %w", record.Update.ID, err)
+		}
+		s.updates[record.Update.ID] = scheduled
+	}
+
+	// Recurring updates pick their unfinished instance back up
+	for id, scheduled := range s.updates {
+		r := scheduled.recurring
+		if r == nil {
+			continue
+		}
+
+		r.instances = r.instances[:0]
+		for instanceID, instance := range s.updates {
+			if instance.update.InstanceOf == id {
+				r.instances = append(r.instances, instanceID)
+			}
+		}
+		sort.Strings(r.instances) // IDs embed the fire time
+
+		for _, instanceID := range r.instances {
+			if !s.instanceFinished(instanceID) {
+				r.active = instanceID
+			}
+		}
+	}
+
+	return nil
+}
+
+// restore rebuilds a scheduled update from its record. The caller must hold s.mu.
+func (s *Scheduler) restore(ctx context.Context, record Record) (*scheduledUpdate, error) {
+	scheduled := &scheduledUpdate{
+		update:     record.Update,
+		status:     record.Status,
+		createdAt:  record.CreatedAt,
+		startedAt:  record.StartedAt,
+		pausedFrom: record.PausedFrom,
+		phase:      record.Phase,
+		approvals:  record.Approvals,
+		rollbackID: record.RollbackID,
+	}
+
+	if record.Update.Strategy == core.StrategyRecurring {
+		r, err := newRecurringState(record.Update, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if record.NextRun != nil {
+			r.next = *record.NextRun // Firings missed while down are caught up
+		}
+		scheduled.recurring = r
+	}
+
+	if !interrupted(record) {
+		return scheduled, nil
+	}
+
+	if s.config.Recovery == RecoverFail {
+		scheduled.status = core.StatusFailed
+		scheduled.pausedFrom = ""
+		s.save(scheduled)
+
+		s.orchestrator.Publish(ctx, events.Event{
+			Type:      events.EventUpdateFailed,
+			UpdateID:  record.Update.ID,
+			Timestamp: time.Now(),
+			Error:     core.ErrUpdateInterrupted,
+		})
+		return scheduled, nil
+	}
+
+	// Run the update again, skipping devices that already have an outcome
+	// (failed devices are not retried)
+	scheduled.restored = make(map[string]core.UpdateStatus, len(record.Devices))
+	for deviceID, outcome := range record.Devices {
+		scheduled.restored[deviceID] = outcome.Status
+	}
+
+	if scheduled.status == core.StatusPaused {
+		scheduled.pausedFrom = core.StatusPending // Runs again once resumed
+	} else {
+		scheduled.status = core.StatusPending
+		scheduled.pausedFrom = ""
+	}
+	s.save(scheduled)
+
+	return scheduled, nil
+}
+
+// interrupted reports whether a record belongs to an update that was running
+// when the scheduler stopped.
+func interrupted(record Record) bool {
+	switch record.Status {
+	case core.StatusInProgress, core.StatusAwaitingApproval:
+		return true
+	case core.StatusPaused:
+		return record.PausedFrom == core.StatusInProgress
+	default:
+		return false
+	}
+}
+
+// restoreOutcomes hands the device outcomes recovered from the store to a
+// resumed update's orchestrator run.
+func (s *Scheduler) restoreOutcomes(ctx context.Context, updateID string) error {
+	s.mu.RLock()
+	restored := s.updates[updateID].restored
+	s.mu.RUnlock()
+
+	if len(restored) == 0 {
+		return nil
+	}
+	return s.orchestrator.RestoreOutcomes(ctx, updateID, restored)
+}
+
+// remainingDevices returns the devices of an update that do not have an
+// outcome recovered from the store.
+func (s *Scheduler) remainingDevices(updateID string, devices []core.Device) []core.Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	restored := s.updates[updateID].restored
+	if len(restored) == 0 {
+		return devices
+	}
+
+	remaining := make([]core.Device, 0, len(devices))
+	for _, device := range devices {
+		if _, done := restored[device.ID]; !done {
+			remaining = append(remaining, device)
+		}
+	}
+	return remaining
+}
+
+// setPhase records the rollout phase an update is in.
+func (s *Scheduler) setPhase(updateID string, phase int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduled := s.updates[updateID]
+	scheduled.phase = phase
+	s.save(scheduled)
+}
+
+// addOutcomes fills in the device outcomes recorded in the store for an
+// update the orchestrator is not tracking (e.g., one that finished before a
+// restart).
+func (s *Scheduler) addOutcomes(ctx context.Context, status *core.Status) {
+	if s.store == nil {
+		return
+	}
+
+	record, err := s.store.Get(ctx, status.UpdateID)
+	if err != nil || len(record.Devices) == 0 {
+		return
+	}
+
+	status.TotalDevices = len(record.Devices)
+	status.DeviceStatus = make(map[string]string, len(record.Devices))
+	for deviceID, outcome := range record.Devices {
+		status.DeviceStatus[deviceID] = string(outcome.Status)
+		switch outcome.Status {
+		case core.StatusCompleted:
+			status.Completed++
+		case core.StatusFailed:
+			status.Failed++
+		}
+	}
+}
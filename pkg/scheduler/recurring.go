@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+)
+
+// maxCatchUp bounds how many missed firings of a recurring update are run
+// after downtime (the most recent ones are kept).
+const maxCatchUp = 100
+
+// recurringState tracks the firings of a recurring update.
+type recurringState struct {
+	schedule  *cronSchedule
+	next      time.Time   // Next firing not yet handled
+	queue     []time.Time // Firings waiting for the running instance to finish
+	active    string      // Instance currently pending or running (if any)
+	instances []string    // Instances spawned so far, oldest first
+}
+
+// newRecurringState validates a recurring update and computes its first
+// firing. Firings are counted from the update's CreatedAt if it is set, so
+// an update re-scheduled after downtime catches up on the firings it missed.
+func newRecurringState(update core.Update, now time.Time) (*recurringState, error) {
+	rec := update.Recurrence
+	if rec == nil {
+		return nil, fmt.Errorf("recurring strategy requires a Recurrence")
+	}
+
+	switch rec.Overlap {
+	case "", core.OverlapSkip, core.OverlapQueue, core.OverlapReplace:
+	default:
+		return nil, fmt.Errorf("unknown overlap policy: %s", rec.Overlap)
+	}
+	switch rec.CatchUp {
+	case "", core.CatchUpSkip, core.CatchUpOnce, core.CatchUpAll:
+	default:
+		return nil, fmt.Errorf("unknown catch-up policy: %s", rec.CatchUp)
+	}
+
+	schedule, err := parseCron(rec.Cron, rec.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	from := now
+	if !update.CreatedAt.IsZero() && update.CreatedAt.Before(now) {
+		from = update.CreatedAt
+	}
+
+	next := schedule.next(from)
+	if next.IsZero() {
+		return nil, fmt.Errorf("cron expression %q never fires", rec.Cron)
+	}
+
+	return &recurringState{schedule: schedule, next: next}, nil
+}
+
+// Upcoming returns the next count fire times of a recurring update.
+func (s *Scheduler) Upcoming(ctx context.Context, updateID string, count int) ([]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scheduled, exists := s.updates[updateID]
+	if !exists {
+		return nil, fmt.Errorf("update %s not found", updateID)
+	}
+
+	r := scheduled.recurring
+	if r == nil {
+		return nil, fmt.Errorf("update %s is not recurring", updateID)
+	}
+
+	times := make([]time.Time, 0, count)
+	for t := r.next; !t.IsZero() && len(times) < count; t = r.schedule.next(t) {
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// fireRecurring handles the due firings of a recurring update, spawning an
+// instance per firing according to the update's overlap and catch-up
+// policies. A firing is missed if it is more than two ticks old by the time
+// the scheduler sees it. The caller must hold s.mu.
+func (s *Scheduler) fireRecurring(ctx context.Context, scheduled *scheduledUpdate, now time.Time) {
+	r := scheduled.recurring
+	rec := scheduled.update.Recurrence
+
+	if r.active != "" && s.instanceFinished(r.active) {
+		r.active = ""
+	}
+	if r.active == "" && len(r.queue) > 0 {
+		fire := r.queue[0]
+		r.queue = r.queue[1:]
+		s.spawnInstance(ctx, scheduled, fire)
+	}
+
+	grace := 2 * s.config.TickInterval
+	var missed, due []time.Time
+	skipped := 0
+	for !r.next.IsZero() && !r.next.After(now) {
+		switch {
+		case now.Sub(r.next) <= grace:
+			due = append(due, r.next)
+		case len(missed) < maxCatchUp:
+			missed = append(missed, r.next)
+		default:
+			missed = append(missed[1:], r.next)
+			skipped++
+		}
+		r.next = r.schedule.next(r.next)
+	}
+
+	switch rec.CatchUp {
+	case core.CatchUpAll:
+		due = append(missed, due...)
+	case core.CatchUpOnce:
+		if len(missed) > 0 {
+			skipped += len(missed) - 1
+			due = append([]time.Time{missed[len(missed)-1]}, due...)
+		}
+	default:
+		skipped += len(missed)
+	}
+	if skipped > 0 {
+		s.publishRecurring(ctx, events.EventRecurringSkipped, scheduled.update.ID, map[string]interface{}{
+			"reason":  "missed",
+			"firings": skipped,
+		})
+	}
+
+	for _, fire := range due {
+		if r.active == "" {
+			s.spawnInstance(ctx, scheduled, fire)
+			continue
+		}
+
+		switch rec.Overlap {
+		case core.OverlapQueue:
+			r.queue = append(r.queue, fire)
+		case core.OverlapReplace:
+			s.cancelLocked(s.updates[r.active])
+			s.spawnInstance(ctx, scheduled, fire)
+		default:
+			s.publishRecurring(ctx, events.EventRecurringSkipped, scheduled.update.ID, map[string]interface{}{
+				"reason":    "overlap",
+				"fire_time": fire,
+				"running":   r.active,
+			})
+		}
+	}
+
+	if len(due) > 0 || skipped > 0 {
+		s.save(scheduled) // Record that these firings were handled
+	}
+}
+
+// spawnInstance schedules a child instance of a recurring update for one
+// firing. The caller must hold s.mu.
+func (s *Scheduler) spawnInstance(ctx context.Context, parent *scheduledUpdate, fire time.Time) {
+	r := parent.recurring
+	now := time.Now()
+
+	instance := parent.update
+	instance.ID = fmt.Sprintf("%s-%s", parent.update.ID, fire.UTC().Format("20060102T1504Z"))
+	instance.Strategy = core.StrategyImmediate
+	if len(instance.RolloutPhases) > 0 {
+		instance.Strategy = core.StrategyProgressive
+	}
+	instance.Recurrence = nil
+	instance.InstanceOf = parent.update.ID
+	instance.CreatedAt = now
+
+	if _, exists := s.updates[instance.ID]; exists {
+		return // Already spawned (e.g., the same firing was queued twice)
+	}
+
+	s.updates[instance.ID] = &scheduledUpdate{
+		update:    instance,
+		status:    core.StatusPending,
+		createdAt: now,
+	}
+	r.active = instance.ID
+	r.instances = append(r.instances, instance.ID)
+	s.save(s.updates[instance.ID])
+	s.save(parent)
+
+	s.publishRecurring(ctx, events.EventRecurringFired, parent.update.ID, map[string]interface{}{
+		"instance_id": instance.ID,
+		"fire_time":   fire,
+	})
+}
+
+// instanceFinished reports whether an instance of a recurring update has
+// reached a terminal state. The caller must hold s.mu.
+func (s *Scheduler) instanceFinished(instanceID string) bool {
+	instance, exists := s.updates[instanceID]
+	if !exists {
+		return true
+	}
+
+	switch instance.status {
+	case core.StatusCompleted, core.StatusFailed, core.StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// publishRecurring emits a recurring update event.
+func (s *Scheduler) publishRecurring(ctx context.Context, eventType events.EventType, updateID string, data map[string]interface{}) {
+	s.orchestrator.Publish(ctx, events.Event{
+		Type:      eventType,
+		UpdateID:  updateID,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
@@ -0,0 +1,300 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+)
+
+// recurringUpdate returns an hourly recurring update created hoursAgo hours
+// ago, so it has that many missed firings when the scheduler starts.
+func recurringUpdate(t *testing.T, id string, hoursAgo int, overlap core.OverlapPolicy, catchUp core.CatchUpPolicy) core.Update {
+	return core.Update{
+		ID:         id,
+		PayloadURL: writePayload(t, "config bundle"),
+		Strategy:   core.StrategyRecurring,
+		Recurrence: &core.Recurrence{
+			Cron:    "@hourly",
+			Overlap: overlap,
+			CatchUp: catchUp,
+		},
+		CreatedAt: time.Now().Add(-time.Duration(hoursAgo)*time.Hour - time.Minute),
+	}
+}
+
+func TestScheduler_RecurringCatchUpQueuesInstances(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 2)
+	ctx := context.Background()
+
+	delivery.PushDelay = 20 * time.Millisecond
+
+	update := recurringUpdate(t, "nightly", 3, core.OverlapQueue, core.CatchUpAll)
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForPushes(t, delivery, 6)
+	instances := waitForInstances(t, scheduler, "nightly", 3)
+	for _, id := range instances {
+		waitForStatus(t, scheduler, id, core.StatusCompleted)
+	}
+
+	// Queued instances run one at a time
+	var starts []time.Time
+	for _, id := range instances {
+		status, _ := scheduler.Status(ctx, id)
+		if status.InstanceOf != "nightly" {
+			t.Errorf("Expected %s to be an instance of nightly, got %q", id, status.InstanceOf)
+		}
+		scheduler.mu.RLock()
+		starts = append(starts, *scheduler.updates[id].startedAt)
+		scheduler.mu.RUnlock()
+	}
+	for i := 1; i < len(starts); i++ {
+		if starts[i].Sub(starts[i-1]) < delivery.PushDelay {
+			t.Errorf("Expected instance %d to start after instance %d finished", i, i-1)
+		}
+	}
+
+	// The recurring update itself keeps waiting for its next firing
+	status, err := scheduler.Status(ctx, "nightly")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.Status != core.StatusScheduled {
+		t.Errorf("Expected recurring update to stay scheduled, got %s", status.Status)
+	}
+	if status.NextRun == nil || !status.NextRun.After(time.Now()) {
+		t.Errorf("Expected next run in the future, got %v", status.NextRun)
+	}
+}
+
+func TestScheduler_RecurringOverlapSkip(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+
+	delivery.PushDelay = 100 * time.Millisecond
+
+	var skipped atomic.Int64
+	scheduler.orchestrator.Subscribe(events.EventRecurringSkipped, events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		if event.Data["reason"] == "overlap" {
+			skipped.Add(1)
+		}
+	}))
+
+	update := recurringUpdate(t, "skip", 3, core.OverlapSkip, core.CatchUpAll)
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	instances := waitForInstances(t, scheduler, "skip", 1)
+	waitForStatus(t, scheduler, instances[0], core.StatusCompleted)
+	time.Sleep(50 * time.Millisecond)
+
+	if got := len(recurringInstances(scheduler, "skip")); got != 1 {
+		t.Errorf("Expected 1 instance, got %d", got)
+	}
+	if skipped.Load() != 2 {
+		t.Errorf("Expected 2 overlapping firings to be skipped, got %d", skipped.Load())
+	}
+}
+
+func TestScheduler_RecurringOverlapReplace(t *testing.T) {
+	scheduler, _ := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+
+	update := recurringUpdate(t, "replace", 3, core.OverlapReplace, core.CatchUpAll)
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	instances := waitForInstances(t, scheduler, "replace", 3)
+
+	// Each firing replaced the previous instance before it started
+	waitForStatus(t, scheduler, instances[0], core.StatusCancelled)
+	waitForStatus(t, scheduler, instances[1], core.StatusCancelled)
+	waitForStatus(t, scheduler, instances[2], core.StatusCompleted)
+}
+
+func TestScheduler_RecurringCatchUpPolicies(t *testing.T) {
+	tests := []struct {
+		catchUp   core.CatchUpPolicy
+		instances int
+	}{
+		{"", 0},
+		{core.CatchUpSkip, 0},
+		{core.CatchUpOnce, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.catchUp), func(t *testing.T) {
+			scheduler, _ := setupExecutingScheduler(t, 1)
+			ctx := context.Background()
+
+			update := recurringUpdate(t, "catch-up", 5, core.OverlapQueue, tt.catchUp)
+			if err := scheduler.Schedule(ctx, update); err != nil {
+				t.Fatalf("Failed to schedule update: %v", err)
+			}
+
+			if err := scheduler.Start(ctx); err != nil {
+				t.Fatalf("Failed to start scheduler: %v", err)
+			}
+			defer scheduler.Stop()
+
+			time.Sleep(100 * time.Millisecond)
+
+			if got := len(recurringInstances(scheduler, "catch-up")); got != tt.instances {
+				t.Errorf("Expected %d instances, got %d", tt.instances, got)
+			}
+		})
+	}
+}
+
+func TestScheduler_RecurringUpcoming(t *testing.T) {
+	scheduler := setupTestScheduler(t)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:       "certs",
+		Strategy: core.StrategyRecurring,
+		Recurrence: &core.Recurrence{
+			Cron:     "0 3 * * MON",
+			TimeZone: "Europe/Berlin",
+		},
+	}
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	times, err := scheduler.Upcoming(ctx, "certs", 3)
+	if err != nil {
+		t.Fatalf("Failed to list upcoming runs: %v", err)
+	}
+	if len(times) != 3 {
+		t.Fatalf("Expected 3 upcoming runs, got %d", len(times))
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	for i, fire := range times {
+		local := fire.In(berlin)
+		if local.Weekday() != time.Monday || local.Hour() != 3 || local.Minute() != 0 {
+			t.Errorf("Run %d at %s, expected Monday 03:00 Berlin time", i, local)
+		}
+		if i > 0 && !fire.After(times[i-1]) {
+			t.Errorf("Runs out of order: %s after %s", fire, times[i-1])
+		}
+	}
+
+	scheduler.Schedule(ctx, core.Update{ID: "once", Strategy: core.StrategyImmediate})
+	if _, err := scheduler.Upcoming(ctx, "once", 3); err == nil {
+		t.Error("Expected error listing runs of a non-recurring update")
+	}
+}
+
+func TestScheduler_CancelRecurringCancelsInstance(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 1)
+	ctx := context.Background()
+
+	delivery.PushDelay = 5 * time.Second
+
+	update := recurringUpdate(t, "cancel", 2, core.OverlapQueue, core.CatchUpAll)
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForPushes(t, delivery, 1)
+
+	if err := scheduler.Cancel(ctx, "cancel"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	instances := recurringInstances(scheduler, "cancel")
+	if len(instances) != 1 {
+		t.Fatalf("Expected 1 instance (the other queued), got %d", len(instances))
+	}
+	waitForStatus(t, scheduler, instances[0], core.StatusCancelled)
+
+	// The queued firing is dropped
+	time.Sleep(50 * time.Millisecond)
+	if got := len(recurringInstances(scheduler, "cancel")); got != 1 {
+		t.Errorf("Expected no further instances after cancel, got %d", got)
+	}
+}
+
+func TestScheduler_ScheduleRejectsInvalidRecurrence(t *testing.T) {
+	scheduler := setupTestScheduler(t)
+	ctx := context.Background()
+
+	tests := []*core.Recurrence{
+		nil,
+		{Cron: "every night"},
+		{Cron: "@daily", TimeZone: "Nowhere/Special"},
+		{Cron: "@daily", Overlap: "stack"},
+		{Cron: "@daily", CatchUp: "some"},
+		{Cron: "0 0 31 2 *"},
+	}
+
+	for _, rec := range tests {
+		update := core.Update{ID: "bad-recurrence", Strategy: core.StrategyRecurring, Recurrence: rec}
+		if err := scheduler.Schedule(ctx, update); err == nil {
+			t.Errorf("Expected error scheduling recurrence %+v", rec)
+		}
+	}
+}
+
+// recurringInstances returns the instances spawned by a recurring update,
+// oldest first.
+func recurringInstances(s *Scheduler, updateID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var instances []string
+	for id, scheduled := range s.updates {
+		if scheduled.update.InstanceOf == updateID {
+			instances = append(instances, id)
+		}
+	}
+	sort.Strings(instances) // IDs embed the fire time
+	return instances
+}
+
+// waitForInstances waits until a recurring update has spawned count instances.
+func waitForInstances(t *testing.T, s *Scheduler, updateID string, count int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if instances := recurringInstances(s, updateID); len(instances) >= count {
+			return instances
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for %d instances of %s", count, updateID)
+	return nil
+}
@@ -2,12 +2,16 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
 	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry"
 )
 
@@ -18,6 +22,15 @@ type Config struct {
 
 	// MaxConcurrentUpdates limits how many updates can run simultaneously
 	MaxConcurrentUpdates int
+
+	// Recovery decides what Start does with persisted updates that were
+	// running when the scheduler last stopped
+	Recovery RecoveryPolicy
+
+	// MaxOnConnectAttempts limits how many times an on-connect update is
+	// pushed to a device whose update fails before the device is left
+	// failed (0 = no limit)
+	MaxOnConnectAttempts int
 }
 
 // DefaultConfig returns scheduler configuration with sensible defaults.
@@ -25,6 +38,8 @@ func DefaultConfig() *Config {
 	return &Config{
 		TickInterval:         1 * time.Minute,
 		MaxConcurrentUpdates: 5,
+		Recovery:             RecoverResume,
+		MaxOnConnectAttempts: 3,
 	}
 }
 
@@ -33,10 +48,13 @@ type Scheduler struct {
 	config       *Config
 	orchestrator *orchestrator.Orchestrator
 	registry     registry.Registry
+	fetcher      *payload.Fetcher
+	store        Store
 
 	mu            sync.RWMutex
 	updates       map[string]*scheduledUpdate
 	running       bool
+	cancel        context.CancelFunc // Ends every run started since Start
 	stopCh        chan struct{}
 	wg            sync.WaitGroup
 }
@@ -48,31 +66,75 @@ type scheduledUpdate struct {
 	createdAt time.Time
 	startedAt *time.Time
 	cancelFn  context.CancelFunc
+
+	pausedAt   *time.Time        // When the update was paused
+	pausedFrom core.UpdateStatus // Status to restore on resume
+	hold       *gateHold         // Rollout gate holding the update between phases
+	onConnect  *onConnectRun     // Active on-connect update waiting for devices
+	recurring  *recurringState   // Firing state of a recurring update
+
+	approvals  []core.Approval // Approval gate decisions, oldest first
+	rollbackID string          // Rollback update triggered by this update
+
+	phase    int                          // Rollout phase in progress
+	restored map[string]core.UpdateStatus // Device outcomes recovered from the store
 }
 
-// New creates a new scheduler.
+// New creates a new scheduler that stages payloads in the system temp directory.
 func New(config *Config, orch *orchestrator.Orchestrator, reg registry.Registry) *Scheduler {
+	return NewWithFetcher(config, orch, reg, payload.NewFetcher(payload.DefaultConfig()))
+}
+
+// NewWithFetcher creates a scheduler with a custom payload fetcher.
+func NewWithFetcher(config *Config, orch *orchestrator.Orchestrator, reg registry.Registry, fetcher *payload.Fetcher) *Scheduler {
+	return NewWithStore(config, orch, reg, fetcher, nil)
+}
+
+// NewWithStore creates a scheduler that persists its state in store, so
+// updates survive a restart. Persisted updates are restored by Start.
+// A nil store keeps state in memory only.
+func NewWithStore(config *Config, orch *orchestrator.Orchestrator, reg registry.Registry, fetcher *payload.Fetcher, store Store) *Scheduler {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	return &Scheduler{
+	s := &Scheduler{
 		config:       config,
 		orchestrator: orch,
 		registry:     reg,
+		fetcher:      fetcher,
+		store:        store,
 		updates:      make(map[string]*scheduledUpdate),
 		stopCh:       make(chan struct{}),
 	}
+
+	return s
 }
 
-// Start begins the scheduler's background processing.
+// Start restores any persisted updates and begins the scheduler's
+// background processing.
 func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.RLock()
+	running := s.running
+	s.mu.RUnlock()
+	if running {
+		return fmt.Errorf("scheduler already running")
+	}
+
+	if err := s.reconcile(ctx); err != nil {
+		return fmt.Errorf("failed to restore scheduler state: %w", err)
+	}
+
 	s.mu.Lock()
 	if s.running {
 		s.mu.Unlock()
 		return fmt.Errorf("scheduler already running")
 	}
+	// Updates run under a context Stop can end, so that updates waiting
+	// for devices, windows or operators do not hold up shutdown
+	ctx, cancel := context.WithCancel(ctx)
 	s.running = true
+	s.cancel = cancel
 	s.mu.Unlock()
 
 	s.wg.Add(1)
@@ -81,7 +143,8 @@ func (s *Scheduler) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop gracefully shuts down the scheduler.
+// Stop gracefully shuts down the scheduler. Running updates are interrupted
+// and left running in the store, so the next Start can recover them.
 func (s *Scheduler) Stop() error {
 	s.mu.Lock()
 	if !s.running {
@@ -89,20 +152,15 @@ func (s *Scheduler) Stop() error {
 		return fmt.Errorf("scheduler not running")
 	}
 	s.running = false
+	cancel := s.cancel
+	s.cancel = nil
 	s.mu.Unlock()
 
+	// Interrupt running updates before waiting for them to return
+	cancel()
 	close(s.stopCh)
 	s.wg.Wait()
 
-	// Cancel all running updates
-	s.mu.Lock()
-	for _, update := range s.updates {
-		if update.cancelFn != nil {
-			update.cancelFn()
-		}
-	}
-	s.mu.Unlock()
-
 	return nil
 }
 
@@ -112,8 +170,21 @@ func (s *Scheduler) Schedule(ctx context.Context, update core.Update) error {
 		return fmt.Errorf("update ID is required")
 	}
 
+	if update.Window != nil {
+		if err := validateWindow(*update.Window); err != nil {
+			return fmt.Errorf("invalid update window: %w", err)
+		}
+	}
+
+	// Refuse updates whose manifest is not signed by a trusted key
+	update, err := s.orchestrator.VerifyManifest(update)
+	if err != nil {
+		return err
+	}
+
 	// Determine initial status based on strategy
 	var status core.UpdateStatus
+	var recurring *recurringState
 	switch update.Strategy {
 	case core.StrategyImmediate:
 		status = core.StatusPending
@@ -123,9 +194,22 @@ func (s *Scheduler) Schedule(ctx context.Context, update core.Update) error {
 		}
 		status = core.StatusScheduled
 	case core.StrategyProgressive:
+		for _, phase := range update.RolloutPhases {
+			switch phase.OnGateFailure {
+			case "", core.GateHalt, core.GateBlock, core.GateApproval:
+			default:
+				return fmt.Errorf("phase %q: unknown gate action: %s", phase.Name, phase.OnGateFailure)
+			}
+		}
 		status = core.StatusPending
 	case core.StrategyOnConnect:
 		status = core.StatusScheduled // Will be triggered by device connection events
+	case core.StrategyRecurring:
+		var err error
+		if recurring, err = newRecurringState(update, time.Now()); err != nil {
+			return err
+		}
+		status = core.StatusScheduled // Spawns an instance each time it fires
 	default:
 		return fmt.Errorf("unknown update strategy: %s", update.Strategy)
 	}
@@ -139,12 +223,20 @@ func (s *Scheduler) Schedule(ctx context.Context, update core.Update) error {
 	}
 
 	// Add to scheduled updates
-	s.updates[update.ID] = &scheduledUpdate{
+	scheduled := &scheduledUpdate{
 		update:    update,
 		status:    status,
 		createdAt: time.Now(),
+		recurring: recurring,
 	}
 
+	if s.store != nil {
+		if err := s.store.Save(ctx, s.record(scheduled)); err != nil {
+			return fmt.Errorf("failed to persist update: %w", err)
+		}
+	}
+	s.updates[update.ID] = scheduled
+
 	return nil
 }
 
@@ -158,23 +250,58 @@ func (s *Scheduler) Status(ctx context.Context, updateID string) (*core.Status,
 		return nil, fmt.Errorf("update %s not found", updateID)
 	}
 
-	// If update is running, get status from orchestrator
-	if scheduled.status == core.StatusInProgress {
-		return s.orchestrator.GetStatus(ctx, updateID)
+	// If update is running (or paused mid-run), get status from orchestrator
+	if scheduled.status == core.StatusInProgress || s.orchestrator.IsRunning(updateID) {
+		status, err := s.orchestrator.GetStatus(ctx, updateID)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		s.annotateStatus(status, scheduled)
+		// A rollout gate may hold the update between phases
+		if scheduled.status == core.StatusPaused || scheduled.status == core.StatusAwaitingApproval {
+			status.Status = scheduled.status
+		}
+		s.mu.RUnlock()
+
+		return status, nil
 	}
 
 	// Return basic status for scheduled/pending updates
-	return &core.Status{
+	status := &core.Status{
 		UpdateID:     updateID,
 		Status:       scheduled.status,
 		TotalDevices: 0,
 		Completed:    0,
 		Failed:       0,
 		StartedAt:    scheduled.createdAt,
-	}, nil
+	}
+	s.addOutcomes(ctx, status)
+	s.mu.RLock()
+	s.annotateStatus(status, scheduled)
+	s.mu.RUnlock()
+
+	return status, nil
 }
 
-// Cancel attempts to cancel a running or scheduled update.
+// annotateStatus adds what the scheduler knows about an update (rollback
+// links and approval history) to its status. The caller must hold s.mu.
+func (s *Scheduler) annotateStatus(status *core.Status, scheduled *scheduledUpdate) {
+	status.RollbackID = scheduled.rollbackID
+	status.RollbackOf = scheduled.update.RollbackOf
+	status.Approvals = append([]core.Approval(nil), scheduled.approvals...)
+	if scheduled.hold != nil && scheduled.status == core.StatusAwaitingApproval {
+		status.PendingApproval = scheduled.hold.phase
+	}
+	status.InstanceOf = scheduled.update.InstanceOf
+	if r := scheduled.recurring; r != nil && !r.next.IsZero() && scheduled.status != core.StatusCancelled {
+		next := r.next
+		status.NextRun = &next
+	}
+}
+
+// Cancel attempts to cancel a running or scheduled update. Cancelling a
+// recurring update stops it firing and cancels its running instance.
 func (s *Scheduler) Cancel(ctx context.Context, updateID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -184,6 +311,20 @@ func (s *Scheduler) Cancel(ctx context.Context, updateID string) error {
 		return fmt.Errorf("update %s not found", updateID)
 	}
 
+	if r := scheduled.recurring; r != nil {
+		r.queue = nil
+		if instance, exists := s.updates[r.active]; exists {
+			s.cancelLocked(instance)
+		}
+	}
+
+	s.cancelLocked(scheduled)
+
+	return nil
+}
+
+// cancelLocked cancels an update. The caller must hold s.mu.
+func (s *Scheduler) cancelLocked(scheduled *scheduledUpdate) {
 	// Cancel if running
 	if scheduled.cancelFn != nil {
 		scheduled.cancelFn()
@@ -191,6 +332,71 @@ func (s *Scheduler) Cancel(ctx context.Context, updateID string) error {
 
 	// Update status
 	scheduled.status = core.StatusCancelled
+	s.save(scheduled)
+}
+
+// Pause holds a pending, scheduled or running update.
+// In-flight device pushes finish, but no further devices or rollout phases
+// are started until Resume is called.
+func (s *Scheduler) Pause(ctx context.Context, updateID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduled, exists := s.updates[updateID]
+	if !exists {
+		return fmt.Errorf("update %s not found", updateID)
+	}
+
+	switch scheduled.status {
+	case core.StatusPending, core.StatusScheduled:
+		// Not started yet; processScheduledUpdates skips paused updates
+	case core.StatusInProgress:
+		// The update may still be staging its payload, in which case
+		// beginUpdate applies the pause once the orchestrator run exists
+		if s.orchestrator.IsRunning(updateID) {
+			if err := s.orchestrator.Pause(ctx, updateID); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot pause update %s in status %s", updateID, scheduled.status)
+	}
+
+	now := time.Now()
+	scheduled.pausedAt = &now
+	scheduled.pausedFrom = scheduled.status
+	scheduled.status = core.StatusPaused
+	s.save(scheduled)
+
+	return nil
+}
+
+// Resume continues a paused update from where it was held, including a
+// rollout blocked by a phase gate. Updates awaiting approval are released
+// with Approve or Reject instead.
+func (s *Scheduler) Resume(ctx context.Context, updateID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduled, exists := s.updates[updateID]
+	if !exists {
+		return fmt.Errorf("update %s not found", updateID)
+	}
+
+	if scheduled.status == core.StatusAwaitingApproval {
+		return fmt.Errorf("update %s is awaiting approval; approve or reject it instead", updateID)
+	}
+	if scheduled.status != core.StatusPaused {
+		return fmt.Errorf("update %s is not paused", updateID)
+	}
+
+	if s.orchestrator.IsPaused(updateID) {
+		if err := s.orchestrator.Resume(ctx, updateID); err != nil {
+			return err
+		}
+	}
+
+	s.releaseHold(scheduled, nil)
 
 	return nil
 }
@@ -225,14 +431,14 @@ func (s *Scheduler) ListAll(ctx context.Context) ([]core.Status, error) {
 		status, err := s.orchestrator.GetStatus(ctx, id)
 		if err != nil {
 			// If orchestrator doesn't have it, use what we know
-			results = append(results, core.Status{
+			status = &core.Status{
 				UpdateID:  id,
 				Status:    scheduled.status,
 				StartedAt: scheduled.createdAt,
-			})
-		} else {
-			results = append(results, *status)
+			}
 		}
+		s.annotateStatus(status, scheduled)
+		results = append(results, *status)
 	}
 
 	return results, nil
@@ -263,6 +469,15 @@ func (s *Scheduler) processScheduledUpdates(ctx context.Context) {
 	defer s.mu.Unlock()
 
 	now := time.Now()
+
+	// Fire due recurring updates; the instances they spawn are pending
+	// updates like any other
+	for _, scheduled := range s.updates {
+		if scheduled.recurring != nil && scheduled.status == core.StatusScheduled {
+			s.fireRecurring(ctx, scheduled, now)
+		}
+	}
+
 	runningCount := s.countRunningUpdates()
 
 	for id, scheduled := range s.updates {
@@ -293,6 +508,10 @@ func (s *Scheduler) processScheduledUpdates(ctx context.Context) {
 		case core.StrategyProgressive:
 			// Progressive updates start immediately and are managed by phases
 			shouldRun = true
+
+		case core.StrategyOnConnect:
+			// On-connect updates start listening for device heartbeats
+			shouldRun = s.isInUpdateWindow(scheduled.update, now)
 		}
 
 		if shouldRun {
@@ -333,6 +552,7 @@ func (s *Scheduler) executeUpdate(parentCtx context.Context, updateID string, sc
 	scheduled.status = core.StatusInProgress
 	now := time.Now()
 	scheduled.startedAt = &now
+	s.save(scheduled)
 
 	// Execute in background
 	s.wg.Add(1)
@@ -341,20 +561,124 @@ func (s *Scheduler) executeUpdate(parentCtx context.Context, updateID string, sc
 		defer cancel()
 
 		// Execute the update via orchestrator
-		// Note: For this to work, we need the payload. In a real implementation,
-		// the orchestrator would fetch the payload from PayloadURL
 		err := s.executeUpdateStrategy(ctx, scheduled.update)
 
-		// Update final status
-		s.mu.Lock()
+		// The update has reached a terminal state, so its staged payload
+		// is no longer needed by any phase
+		s.fetcher.Release(updateID)
+
+		s.finishUpdate(parentCtx, scheduled, err)
+
+		// A rollout that fell below its success threshold, or was rejected
+		// by an operator, reverts the devices it already updated
+		halted := errors.Is(err, core.ErrSuccessThresholdNotMet) || errors.Is(err, core.ErrUpdateRejected)
+		if halted && scheduled.update.RollbackPayloadURL != "" {
+			s.rollback(parentCtx, scheduled, err)
+		}
+	}()
+}
+
+// finishUpdate records the final status of an update (a cancelled update
+// stays cancelled). An update interrupted by shutdown, i.e. by parentCtx
+// ending or the scheduler stopping, is left running in the store so Start
+// can recover it; any other cancellation (e.g., through the orchestrator)
+// is recorded.
+func (s *Scheduler) finishUpdate(parentCtx context.Context, scheduled *scheduledUpdate, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shutdown := parentCtx.Err() != nil || !s.running
+	if errors.Is(err, core.ErrCancelled) && shutdown && scheduled.status != core.StatusCancelled {
+		scheduled.status = core.StatusCancelled
+		scheduled.cancelFn = nil
+		return
+	}
+
+	if errors.Is(err, core.ErrCancelled) {
+		scheduled.status = core.StatusCancelled
+	} else if scheduled.status != core.StatusCancelled {
 		if err != nil {
 			scheduled.status = core.StatusFailed
 		} else {
 			scheduled.status = core.StatusCompleted
 		}
-		scheduled.cancelFn = nil
+	}
+	scheduled.cancelFn = nil
+	s.save(scheduled)
+}
+
+// rollback pushes the rollback payload of a failed update to every device
+// the update completed on. The rollback runs as its own update, linked to
+// the original, so it has its own events and status and can be cancelled
+// or paused independently.
+func (s *Scheduler) rollback(parentCtx context.Context, parent *scheduledUpdate, cause error) {
+	update := parent.update
+
+	status, err := s.orchestrator.GetStatus(parentCtx, update.ID)
+	if err != nil {
+		return
+	}
+
+	deviceIDs := make([]string, 0, status.Completed)
+	for deviceID, deviceStatus := range status.DeviceStatus {
+		if deviceStatus == string(core.StatusCompleted) {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+	}
+	if len(deviceIDs) == 0 {
+		return // Nothing was updated, so there is nothing to revert
+	}
+	sort.Strings(deviceIDs)
+
+	now := time.Now()
+	rollbackUpdate := core.Update{
+		ID:            update.ID + "-rollback",
+		Name:          "Rollback of " + update.Name,
+		PayloadURL:    update.RollbackPayloadURL,
+		TargetVersion: update.RollbackVersion,
+		DeviceIDs:     deviceIDs,
+		Strategy:      core.StrategyImmediate,
+		RollbackOf:    update.ID,
+		CreatedAt:     now,
+	}
+
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+
+	scheduled := &scheduledUpdate{
+		update:    rollbackUpdate,
+		status:    core.StatusInProgress,
+		createdAt: now,
+		startedAt: &now,
+		cancelFn:  cancel,
+	}
+
+	s.mu.Lock()
+	if _, exists := s.updates[rollbackUpdate.ID]; exists {
 		s.mu.Unlock()
-	}()
+		return
+	}
+	s.updates[rollbackUpdate.ID] = scheduled
+	parent.rollbackID = rollbackUpdate.ID
+	s.save(scheduled)
+	s.save(parent)
+	s.mu.Unlock()
+
+	s.orchestrator.Publish(ctx, events.Event{
+		Type:      events.EventRollbackStarted,
+		UpdateID:  update.ID,
+		Timestamp: now,
+		Data: map[string]interface{}{
+			"rollback_update_id": rollbackUpdate.ID,
+			"devices":            len(deviceIDs),
+			"reason":             cause.Error(),
+		},
+		Error: cause,
+	})
+
+	err = s.executeImmediate(ctx, rollbackUpdate)
+	s.fetcher.Release(rollbackUpdate.ID)
+	s.finishUpdate(parentCtx, scheduled, err)
 }
 
 // executeUpdateStrategy executes the update based on its strategy.
@@ -369,9 +693,8 @@ func (s *Scheduler) executeUpdateStrategy(ctx context.Context, update core.Updat
 		return s.executeProgressive(ctx, update)
 
 	case core.StrategyOnConnect:
-		// This would be triggered by device connection events
-		// For now, treat as immediate
-		return s.executeImmediate(ctx, update)
+		// Push to each device as it checks in
+		return s.executeOnConnect(ctx, update)
 
 	default:
 		return fmt.Errorf("unsupported strategy: %s", update.Strategy)
@@ -380,13 +703,83 @@ func (s *Scheduler) executeUpdateStrategy(ctx context.Context, update core.Updat
 
 // executeImmediate executes an update immediately on all matched devices.
 func (s *Scheduler) executeImmediate(ctx context.Context, update core.Update) error {
-	// In a full implementation, this would:
-	// 1. Fetch payload from update.PayloadURL
-	// 2. Call orchestrator.ExecuteUpdateWithPayload()
+	devices, err := s.orchestrator.ResolveDevices(ctx, update)
+	if err != nil {
+		return err
+	}
 
-	// For now, we just acknowledge that the scheduler would delegate to orchestrator
-	// The orchestrator already has ExecuteUpdate methods
-	return nil
+	if len(devices) == 0 {
+		return fmt.Errorf("no devices match filter")
+	}
+
+	staged, err := s.stagePayload(ctx, update)
+	if err != nil {
+		return err
+	}
+
+	runCtx, err := s.beginUpdate(ctx, update, devices)
+	if err != nil {
+		return err
+	}
+	defer s.orchestrator.FinishUpdate(ctx, update)
+
+	return s.executeDevices(runCtx, update, devices, staged)
+}
+
+// stagePayload fetches the payload of an update into the local cache and
+// checks it against the digest and size the update expects. A payload that
+// does not match is released so that a later attempt fetches it again.
+// Signed updates have their manifest checked again, as it may have expired
+// since the update was scheduled.
+func (s *Scheduler) stagePayload(ctx context.Context, update core.Update) (*payload.Staged, error) {
+	if len(update.Manifest) > 0 {
+		if _, err := s.orchestrator.VerifyManifest(update); err != nil {
+			return nil, err
+		}
+	}
+
+	staged, err := s.fetcher.StageUpdate(ctx, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage payload: %w", err)
+	}
+
+	if err := staged.Verify(update); err != nil {
+		s.fetcher.Release(update.ID)
+		return nil, err
+	}
+	return staged, nil
+}
+
+// beginUpdate starts the orchestrator run for an update and applies any
+// pause requested while the update was still being prepared.
+func (s *Scheduler) beginUpdate(ctx context.Context, update core.Update, devices []core.Device) (context.Context, error) {
+	runCtx, err := s.orchestrator.BeginUpdate(ctx, update, devices)
+	if err != nil {
+		return nil, err
+	}
+
+	// Persist per-device outcomes as the orchestrator reaches them
+	if s.store != nil {
+		runCtx = orchestrator.WithDeviceOutcome(runCtx, func(device core.Device, status core.UpdateStatus, err error) {
+			s.recordDeviceOutcome(runCtx, update.ID, device.ID, status, err)
+		})
+	}
+
+	s.mu.RLock()
+	paused := s.updates[update.ID].status == core.StatusPaused
+	s.mu.RUnlock()
+
+	if paused {
+		s.orchestrator.Pause(ctx, update.ID)
+	}
+
+	// A resumed update keeps the outcomes of its interrupted run
+	if err := s.restoreOutcomes(runCtx, update.ID); err != nil {
+		s.orchestrator.FinishUpdate(ctx, update)
+		return nil, err
+	}
+
+	return runCtx, nil
 }
 
 // executeProgressive executes an update in phases.
@@ -396,14 +789,9 @@ func (s *Scheduler) executeProgressive(ctx context.Context, update core.Update)
 	}
 
 	// Get all target devices
-	filter := core.Filter{}
-	if update.DeviceFilter != nil {
-		filter = *update.DeviceFilter
-	}
-
-	devices, err := s.registry.List(ctx, filter)
+	devices, err := s.orchestrator.ResolveDevices(ctx, update)
 	if err != nil {
-		return fmt.Errorf("failed to list devices: %w", err)
+		return err
 	}
 
 	totalDevices := len(devices)
@@ -411,7 +799,25 @@ func (s *Scheduler) executeProgressive(ctx context.Context, update core.Update)
 		return fmt.Errorf("no devices match filter")
 	}
 
-	// Execute each phase
+	// Stage the payload once; every phase reads from the same cached copy
+	staged, err := s.stagePayload(ctx, update)
+	if err != nil {
+		return err
+	}
+
+	// Track all phases under a single update
+	runCtx, err := s.beginUpdate(ctx, update, devices)
+	if err != nil {
+		return err
+	}
+	defer s.orchestrator.FinishUpdate(ctx, update)
+
+	// A resumed rollout skips the phases that finished before it was interrupted
+	s.mu.RLock()
+	resumeFrom := s.updates[update.ID].phase
+	s.mu.RUnlock()
+
+	// Execute each phase, checking its gate before moving on
 	deviceOffset := 0
 	for i, phase := range update.RolloutPhases {
 		// Calculate how many devices for this phase
@@ -428,17 +834,70 @@ func (s *Scheduler) executeProgressive(ctx context.Context, update core.Update)
 		// Get devices for this phase
 		phaseDevices := devices[deviceOffset : deviceOffset+phaseDeviceCount]
 
-		// Execute update for this phase
-		// In full implementation: orchestrator.ExecuteUpdateWithPayload() for phaseDevices
-		_ = phaseDevices // TODO: Actually execute
+		// Phases that finished before an interruption are not gated again;
+		// devices without a recorded outcome (e.g., because the registry
+		// lists devices in a different order now) are still updated
+		if i < resumeFrom {
+			if err := s.executeDevices(runCtx, update, phaseDevices, staged); err != nil {
+				return err
+			}
+			deviceOffset += phaseDeviceCount
+			continue
+		}
+		s.setPhase(update.ID, i)
+
+		// Wait for an operator to sign off before starting the phase
+		if phase.RequiresApproval && !s.phaseApproved(update.ID, phase.Name) {
+			if err := s.awaitApproval(runCtx, update, i, phase); err != nil {
+				return err
+			}
+		}
+
+		s.publishPhase(ctx, events.EventPhaseStarted, update.ID, i, phase, map[string]interface{}{
+			"devices": len(phaseDevices),
+		})
+
+		// Execute update for this phase (devices are held while paused,
+		// so a pause between phases holds the next phase)
+		if err := s.executeDevices(runCtx, update, phaseDevices, staged); err != nil {
+			return err
+		}
+
+		// Give updated devices time to misbehave before judging the phase
+		if phase.ObservationPeriod > 0 {
+			select {
+			case <-time.After(phase.ObservationPeriod):
+			case <-runCtx.Done():
+				return core.ErrCancelled
+			}
+			if err := s.orchestrator.RecheckDevices(runCtx, update, phaseDevices); err != nil {
+				return err
+			}
+		}
+
+		rate, err := s.phaseSuccessRate(ctx, update.ID, phaseDevices)
+		if err != nil {
+			return err
+		}
+
+		s.publishPhase(ctx, events.EventPhaseCompleted, update.ID, i, phase, map[string]interface{}{
+			"devices":      len(phaseDevices),
+			"success_rate": rate,
+		})
+
+		if rate < phase.SuccessRate {
+			if err := s.handleGateFailure(runCtx, update, i, phase, rate); err != nil {
+				return err
+			}
+		}
 
 		// Wait before next phase (except for last phase)
 		if i < len(update.RolloutPhases)-1 {
 			select {
 			case <-time.After(phase.WaitTime):
 				// Continue to next phase
-			case <-ctx.Done():
-				return ctx.Err()
+			case <-runCtx.Done():
+				return core.ErrCancelled
 			}
 		}
 
@@ -448,11 +907,69 @@ func (s *Scheduler) executeProgressive(ctx context.Context, update core.Update)
 	return nil
 }
 
+// handleGateFailure applies a phase's gate action after the phase missed its
+// success rate. It returns nil once the rollout may continue.
+func (s *Scheduler) handleGateFailure(ctx context.Context, update core.Update, index int, phase core.RolloutPhase, rate int) error {
+	action := phase.OnGateFailure
+	if action == "" {
+		action = core.GateHalt
+	}
+
+	s.publishPhase(ctx, events.EventPhaseGateFailed, update.ID, index, phase, map[string]interface{}{
+		"success_rate": rate,
+		"action":       string(action),
+	})
+
+	switch action {
+	case core.GateBlock:
+		return s.holdUpdate(ctx, update.ID, core.StatusPaused, phase.Name)
+	case core.GateApproval:
+		return s.awaitApproval(ctx, update, index, phase)
+	default:
+		return fmt.Errorf("%w: phase %q succeeded on %d%% of devices, %d%% required",
+			core.ErrSuccessThresholdNotMet, phase.Name, rate, phase.SuccessRate)
+	}
+}
+
+// publishPhase emits a rollout phase event.
+func (s *Scheduler) publishPhase(ctx context.Context, eventType events.EventType, updateID string, index int, phase core.RolloutPhase, data map[string]interface{}) {
+	data["phase"] = phase.Name
+	data["phase_index"] = index
+	data["success_threshold"] = phase.SuccessRate
+
+	s.orchestrator.Publish(ctx, events.Event{
+		Type:      eventType,
+		UpdateID:  updateID,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
+
+// phaseSuccessRate returns the percentage (0-100) of the given devices whose
+// update completed.
+func (s *Scheduler) phaseSuccessRate(ctx context.Context, updateID string, devices []core.Device) (int, error) {
+	status, err := s.orchestrator.GetStatus(ctx, updateID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get phase status: %w", err)
+	}
+
+	completed := 0
+	for _, device := range devices {
+		if status.DeviceStatus[device.ID] == string(core.StatusCompleted) {
+			completed++
+		}
+	}
+
+	return completed * 100 / len(devices), nil
+}
+
 // countRunningUpdates returns the number of currently running updates.
+// On-connect updates spend most of their time waiting for devices, so they
+// do not count against MaxConcurrentUpdates.
 func (s *Scheduler) countRunningUpdates() int {
 	count := 0
 	for _, scheduled := range s.updates {
-		if scheduled.status == core.StatusInProgress {
+		if scheduled.status == core.StatusInProgress && scheduled.update.Strategy != core.StrategyOnConnect {
 			count++
 		}
 	}
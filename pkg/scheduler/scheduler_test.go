@@ -2,12 +2,21 @@ package scheduler
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
+	"github.com/dovaclean/go-update-orchestrator/pkg/manifest"
 	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
 	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
 )
@@ -373,8 +382,660 @@ func TestScheduler_ProgressivePhases(t *testing.T) {
 	}
 }
 
+func TestScheduler_ExecutesImmediateUpdate(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 5)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "immediate-exec",
+		Name:       "Immediate Update",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyImmediate,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "immediate-exec", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 5 {
+		t.Errorf("Expected 5 pushes, got %d", delivery.GetPushCount())
+	}
+
+	if _, ok := scheduler.fetcher.Get("immediate-exec"); ok {
+		t.Error("Expected staged payload to be released after completion")
+	}
+}
+
+func TestScheduler_ExecutesProgressiveUpdate(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "progressive-exec",
+		Name:       "Progressive Update",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, WaitTime: 10 * time.Millisecond},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "progressive-exec", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 10 {
+		t.Errorf("Expected 10 pushes, got %d", delivery.GetPushCount())
+	}
+
+	status, err := scheduler.orchestrator.GetStatus(ctx, "progressive-exec")
+	if err != nil {
+		t.Fatalf("Failed to get orchestrator status: %v", err)
+	}
+	if status.TotalDevices != 10 || status.Completed != 10 {
+		t.Errorf("Expected 10/10 completed across phases, got %d/%d", status.Completed, status.TotalDevices)
+	}
+}
+
+func TestScheduler_MissingPayloadFails(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 2)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "missing-payload",
+		Name:       "Missing Payload",
+		PayloadURL: "file:///nonexistent/firmware.bin",
+		Strategy:   core.StrategyImmediate,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "missing-payload", core.StatusFailed)
+
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_PayloadDigestMismatchFails(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 2)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:            "tampered-payload",
+		Name:          "Tampered Payload",
+		PayloadURL:    writePayload(t, "firmware v2.0"),
+		PayloadSHA256: strings.Repeat("0", 64),
+		Strategy:      core.StrategyImmediate,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "tampered-payload", core.StatusFailed)
+
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes, got %d", delivery.GetPushCount())
+	}
+	if _, ok := scheduler.fetcher.Get("tampered-payload"); ok {
+		t.Error("Expected mismatching payload to be released")
+	}
+}
+
+func TestScheduler_ScheduleRequiresTrustedManifest(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, untrusted, _ := ed25519.GenerateKey(nil)
+
+	orchConfig := orchestrator.DefaultConfig()
+	orchConfig.Keyring = manifest.NewKeyring()
+	orchConfig.Keyring.Add("release", pub)
+	orch, _ := orchestrator.NewDefault(orchConfig, memory.New(), mocks.NewMockDelivery())
+	scheduler := New(DefaultConfig(), orch, memory.New())
+	ctx := context.Background()
+
+	m := &manifest.Manifest{PayloadSHA256: strings.Repeat("ab", 32), TargetVersion: "2.0.0"}
+	untrustedData, untrustedSig, _ := manifest.Sign(m, untrusted)
+	err := scheduler.Schedule(ctx, core.Update{
+		ID:                "untrusted",
+		Strategy:          core.StrategyImmediate,
+		Manifest:          untrustedData,
+		ManifestSignature: untrustedSig,
+	})
+	if !errors.Is(err, core.ErrInvalidManifest) {
+		t.Errorf("Expected ErrInvalidManifest for an untrusted manifest, got %v", err)
+	}
+
+	err = scheduler.Schedule(ctx, core.Update{ID: "unsigned", Strategy: core.StrategyImmediate})
+	if !errors.Is(err, core.ErrInvalidManifest) {
+		t.Errorf("Expected ErrInvalidManifest for an unsigned update, got %v", err)
+	}
+
+	data, sig, _ := manifest.Sign(m, priv)
+	err = scheduler.Schedule(ctx, core.Update{
+		ID:                "signed",
+		Strategy:          core.StrategyImmediate,
+		Manifest:          data,
+		ManifestSignature: sig,
+	})
+	if err != nil {
+		t.Fatalf("Failed to schedule signed update: %v", err)
+	}
+
+	scheduler.mu.RLock()
+	update := scheduler.updates["signed"].update
+	scheduler.mu.RUnlock()
+	if update.TargetVersion != "2.0.0" || update.PayloadSHA256 != m.PayloadSHA256 {
+		t.Errorf("Expected target version and digest from the manifest, got %+v", update)
+	}
+}
+
+func TestScheduler_CancelRunningUpdate(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	delivery.PushDelay = 5 * time.Second
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "cancel-running",
+		Name:       "Slow Update",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, WaitTime: time.Minute},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "cancel-running", core.StatusInProgress)
+	time.Sleep(50 * time.Millisecond) // Let the canary pushes start
+
+	if err := scheduler.Cancel(ctx, "cancel-running"); err != nil {
+		t.Fatalf("Failed to cancel update: %v", err)
+	}
+
+	// The update must be finalized well before the slow pushes would finish
+	deadline := time.Now().Add(2 * time.Second)
+	for scheduler.orchestrator.IsRunning("cancel-running") {
+		if time.Now().After(deadline) {
+			t.Fatal("Cancelled update is still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status, err := scheduler.orchestrator.GetStatus(ctx, "cancel-running")
+	if err != nil {
+		t.Fatalf("Failed to get orchestrator status: %v", err)
+	}
+	if status.Status != core.StatusCancelled {
+		t.Errorf("Expected status %s, got %s", core.StatusCancelled, status.Status)
+	}
+	if status.Completed != 0 {
+		t.Errorf("Expected no completed devices, got %d", status.Completed)
+	}
+}
+
+func TestScheduler_PauseResumePending(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 3)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "pause-pending",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyImmediate,
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+	if err := scheduler.Pause(ctx, "pause-pending"); err != nil {
+		t.Fatalf("Failed to pause update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	// A paused update is not picked up by the scheduler loop
+	time.Sleep(100 * time.Millisecond)
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes while paused, got %d", delivery.GetPushCount())
+	}
+
+	status, _ := scheduler.Status(ctx, "pause-pending")
+	if status.Status != core.StatusPaused {
+		t.Errorf("Expected status %s, got %s", core.StatusPaused, status.Status)
+	}
+
+	if err := scheduler.Resume(ctx, "pause-pending"); err != nil {
+		t.Fatalf("Failed to resume update: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "pause-pending", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 3 {
+		t.Errorf("Expected 3 pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_PauseBetweenPhases(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	update := core.Update{
+		ID:         "pause-phases",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, WaitTime: 200 * time.Millisecond},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	// Pause during the wait after the canary phase
+	waitForPushes(t, delivery, 2)
+	if err := scheduler.Pause(ctx, "pause-phases"); err != nil {
+		t.Fatalf("Failed to pause update: %v", err)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected next phase to be held, got %d pushes", delivery.GetPushCount())
+	}
+
+	status, _ := scheduler.Status(ctx, "pause-phases")
+	if status.Status != core.StatusPaused {
+		t.Errorf("Expected status %s, got %s", core.StatusPaused, status.Status)
+	}
+
+	if err := scheduler.Resume(ctx, "pause-phases"); err != nil {
+		t.Fatalf("Failed to resume update: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "pause-phases", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 10 {
+		t.Errorf("Expected 10 pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_PauseCompleted(t *testing.T) {
+	scheduler := setupTestScheduler(t)
+	ctx := context.Background()
+
+	scheduler.Schedule(ctx, core.Update{ID: "done", Strategy: core.StrategyImmediate})
+	scheduler.Cancel(ctx, "done")
+
+	if err := scheduler.Pause(ctx, "done"); err == nil {
+		t.Error("Expected error when pausing a cancelled update")
+	}
+	if err := scheduler.Resume(ctx, "done"); err == nil {
+		t.Error("Expected error when resuming an update that is not paused")
+	}
+}
+
 // Helper functions
 
+func TestScheduler_RollbackOnSuccessThreshold(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	// Odd-numbered devices fail, so the phase only reaches 50%
+	delivery.FailDevices = make(map[string]bool)
+	for i := 1; i <= 10; i += 2 {
+		delivery.FailDevices[fmt.Sprintf("device-%d", i)] = true
+	}
+
+	update := core.Update{
+		ID:                 "threshold",
+		Name:               "Threshold Update",
+		PayloadURL:         writePayload(t, "firmware v2.0"),
+		RollbackPayloadURL: writePayload(t, "firmware v1.0"),
+		Strategy:           core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "All", Percentage: 100, SuccessRate: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "threshold-rollback", core.StatusCompleted)
+
+	status, err := scheduler.Status(ctx, "threshold")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.Status != core.StatusFailed {
+		t.Errorf("Expected original update to be failed, got %s", status.Status)
+	}
+	if status.RollbackID != "threshold-rollback" {
+		t.Errorf("Expected rollback link 'threshold-rollback', got %q", status.RollbackID)
+	}
+
+	rollback, err := scheduler.Status(ctx, "threshold-rollback")
+	if err != nil {
+		t.Fatalf("Failed to get rollback status: %v", err)
+	}
+	if rollback.RollbackOf != "threshold" {
+		t.Errorf("Expected rollback to link back to 'threshold', got %q", rollback.RollbackOf)
+	}
+
+	rollback, err = scheduler.orchestrator.GetStatus(ctx, "threshold-rollback")
+	if err != nil {
+		t.Fatalf("Failed to get orchestrator status: %v", err)
+	}
+
+	// Only the devices that took the update are rolled back
+	if rollback.TotalDevices != 5 {
+		t.Errorf("Expected 5 devices rolled back, got %d", rollback.TotalDevices)
+	}
+	for deviceID := range rollback.DeviceStatus {
+		if delivery.FailDevices[deviceID] {
+			t.Errorf("Device %s never took the update and should not be rolled back", deviceID)
+		}
+	}
+
+	if delivery.GetPushCount() != 15 {
+		t.Errorf("Expected 10 update pushes + 5 rollback pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_SuccessThresholdHaltsPhases(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	delivery.ShouldFail = true
+
+	update := core.Update{
+		ID:         "halt",
+		Name:       "Halting Update",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "halt", core.StatusFailed)
+
+	// Only the canary devices were attempted
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected 2 canary pushes, got %d", delivery.GetPushCount())
+	}
+
+	// No rollback payload, so no rollback update
+	scheduler.mu.RLock()
+	_, exists := scheduler.updates["halt-rollback"]
+	scheduler.mu.RUnlock()
+	if exists {
+		t.Error("Expected no rollback update without a rollback payload")
+	}
+}
+
+func TestScheduler_PhaseEvents(t *testing.T) {
+	scheduler, _ := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	counts := make(map[events.EventType]int)
+	record := events.HandlerFunc(func(ctx context.Context, event events.Event) {
+		mu.Lock()
+		counts[event.Type]++
+		mu.Unlock()
+	})
+	scheduler.orchestrator.Subscribe(events.EventPhaseStarted, record)
+	scheduler.orchestrator.Subscribe(events.EventPhaseCompleted, record)
+	scheduler.orchestrator.Subscribe(events.EventPhaseGateFailed, record)
+
+	update := core.Update{
+		ID:         "phase-events",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100},
+			{Name: "Rest", Percentage: 80, SuccessRate: 100},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "phase-events", core.StatusCompleted)
+
+	// Handlers run asynchronously
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		started, completed, gateFailed := counts[events.EventPhaseStarted], counts[events.EventPhaseCompleted], counts[events.EventPhaseGateFailed]
+		mu.Unlock()
+
+		if started == 2 && completed == 2 {
+			if gateFailed != 0 {
+				t.Errorf("Expected no gate failures, got %d", gateFailed)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 started and 2 completed phase events, got %d and %d", started, completed)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduler_GateBlockHoldsRollout(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	delivery.ShouldFail = true
+
+	update := core.Update{
+		ID:         "gate-block",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100, OnGateFailure: core.GateBlock},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "gate-block", core.StatusPaused)
+
+	status, err := scheduler.Status(ctx, "gate-block")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.Status != core.StatusPaused {
+		t.Errorf("Expected blocked rollout to report paused, got %s", status.Status)
+	}
+
+	// The next phase must not start while blocked
+	time.Sleep(50 * time.Millisecond)
+	if delivery.GetPushCount() != 2 {
+		t.Fatalf("Expected only 2 canary pushes while blocked, got %d", delivery.GetPushCount())
+	}
+
+	if err := scheduler.Resume(ctx, "gate-block"); err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "gate-block", core.StatusCompleted)
+
+	if delivery.GetPushCount() != 10 {
+		t.Errorf("Expected 10 pushes after resume, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_GateApprovalCancelled(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	delivery.ShouldFail = true
+
+	update := core.Update{
+		ID:         "gate-approval",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100, OnGateFailure: core.GateApproval},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "gate-approval", core.StatusAwaitingApproval)
+
+	if err := scheduler.Cancel(ctx, "gate-approval"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	waitForStatus(t, scheduler, "gate-approval", core.StatusCancelled)
+
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected only 2 canary pushes, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_GateObservationPeriod(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 10)
+	ctx := context.Background()
+
+	// Both canary devices verify after the push, then degrade
+	delivery.VerifyFailAfter = 2
+
+	update := core.Update{
+		ID:         "observed",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100, ObservationPeriod: 20 * time.Millisecond},
+			{Name: "Rest", Percentage: 80},
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForStatus(t, scheduler, "observed", core.StatusFailed)
+
+	if delivery.GetPushCount() != 2 {
+		t.Errorf("Expected the rollout to halt after the canary, got %d pushes", delivery.GetPushCount())
+	}
+
+	status, err := scheduler.orchestrator.GetStatus(ctx, "observed")
+	if err != nil {
+		t.Fatalf("Failed to get orchestrator status: %v", err)
+	}
+	if status.Failed != 2 || status.Completed != 0 {
+		t.Errorf("Expected both canary devices to fail observation, got %d failed, %d completed", status.Failed, status.Completed)
+	}
+}
+
+func TestScheduler_ScheduleUnknownGateAction(t *testing.T) {
+	scheduler := setupTestScheduler(t)
+
+	update := core.Update{
+		ID:       "bad-gate",
+		Strategy: core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100, OnGateFailure: "retry"},
+		},
+	}
+
+	if err := scheduler.Schedule(context.Background(), update); err == nil {
+		t.Error("Expected error for unknown gate action, got nil")
+	}
+}
+
 func setupTestScheduler(t *testing.T) *Scheduler {
 	t.Helper()
 
@@ -389,6 +1050,102 @@ func setupTestScheduler(t *testing.T) *Scheduler {
 	return New(config, orch, registry)
 }
 
+// setupExecutingScheduler creates a scheduler backed by deviceCount online
+// devices and a mock delivery, with a short tick for fast execution.
+func setupExecutingScheduler(t *testing.T, deviceCount int) (*Scheduler, *mocks.MockDelivery) {
+	t.Helper()
+
+	ctx := context.Background()
+	registry := memory.New()
+	for i := 1; i <= deviceCount; i++ {
+		registry.Add(ctx, core.Device{
+			ID:     fmt.Sprintf("device-%d", i),
+			Name:   fmt.Sprintf("Device %d", i),
+			Status: core.DeviceOnline,
+		})
+	}
+
+	delivery := mocks.NewMockDelivery()
+	orchConfig := orchestrator.DefaultConfig()
+	orchConfig.VerifyAfterPush = true
+	orchConfig.VerifyRetries = 1
+	orchConfig.VerifyPollInterval = time.Millisecond
+	orch, _ := orchestrator.NewDefault(orchConfig, registry, delivery)
+
+	config := DefaultConfig()
+	config.TickInterval = 10 * time.Millisecond
+
+	fetcherConfig := payload.DefaultConfig()
+	fetcherConfig.CacheDir = t.TempDir()
+
+	return NewWithFetcher(config, orch, registry, payload.NewFetcher(fetcherConfig)), delivery
+}
+
+// writePayload writes a payload file and returns its path.
+func writePayload(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "firmware.bin")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write payload: %v", err)
+	}
+	return path
+}
+
+// waitForStatus polls the scheduler until the update reaches the expected status.
+func waitForStatus(t *testing.T, s *Scheduler, updateID string, expected core.UpdateStatus) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		scheduled, exists := s.updates[updateID]
+		var status core.UpdateStatus
+		if exists {
+			status = scheduled.status
+		}
+		s.mu.RUnlock()
+
+		if status == expected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for update %s to reach status %s", updateID, expected)
+}
+
+// waitForPushes polls the mock delivery until at least count pushes were made.
+func waitForPushes(t *testing.T, delivery *mocks.MockDelivery, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for delivery.GetPushCount() < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d pushes, got %d", count, delivery.GetPushCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// stopWithin stops the scheduler, failing the test if Stop does not return
+// within timeout.
+func stopWithin(t *testing.T, s *Scheduler, timeout time.Duration) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Stop() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed to stop scheduler: %v", err)
+		}
+	case <-time.After(timeout):
+		t.Fatalf("Stop did not return within %v", timeout)
+	}
+}
+
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
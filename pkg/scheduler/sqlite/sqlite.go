@@ -0,0 +1,404 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/scheduler"
+	"github.com/mattn/go-sqlite3"
+)
+
+// timeFormat is a fixed-width UTC timestamp format, so stored times sort
+// chronologically as text.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Store implements a SQLite-based scheduler store.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS scheduled_updates (
+	id TEXT PRIMARY KEY,
+	update_json TEXT NOT NULL, -- JSON encoded core.Update
+	status TEXT NOT NULL,
+	paused_from TEXT,
+	created_at TEXT NOT NULL,
+	started_at TEXT,
+	phase INTEGER NOT NULL DEFAULT 0,
+	approvals TEXT, -- JSON encoded []core.Approval
+	rollback_id TEXT,
+	next_run TEXT
+);
+
+CREATE TABLE IF NOT EXISTS update_devices (
+	update_id TEXT NOT NULL REFERENCES scheduled_updates(id) ON DELETE CASCADE,
+	device_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT,
+	recorded_at TEXT NOT NULL,
+	PRIMARY KEY (update_id, device_id)
+);
+
+CREATE TABLE IF NOT EXISTS update_transitions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	update_id TEXT NOT NULL REFERENCES scheduled_updates(id) ON DELETE CASCADE,
+	status TEXT NOT NULL,
+	changed_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_updates_created_at ON scheduled_updates(created_at);
+CREATE INDEX IF NOT EXISTS idx_update_transitions_update_id ON update_transitions(update_id);
+`
+
+// New creates a new SQLite scheduler store.
+func New(dbPath string) (*Store, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Enable foreign keys and WAL mode for better concurrency
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	}
+
+	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	}
+
+	// Create schema
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save creates or replaces an update's record.
+func (s *Store) Save(ctx context.Context, record scheduler.Record) error {
+	updateJSON, err := json.Marshal(record.Update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %w", err)
+	}
+	approvalsJSON, err := json.Marshal(record.Approvals)
+	if err != nil {
+		return fmt.Errorf("failed to marshal approvals: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT status FROM scheduled_updates WHERE id = ?", record.Update.ID).Scan(&previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to query update: %w", err)
+	}
+
+	query := `
+		INSERT INTO scheduled_updates (id, update_json, status, paused_from, created_at, started_at, phase, approvals, rollback_id, next_run)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			update_json = excluded.update_json,
+			status = excluded.status,
+			paused_from = excluded.paused_from,
+			created_at = excluded.created_at,
+			started_at = excluded.started_at,
+			phase = excluded.phase,
+			approvals = excluded.approvals,
+			rollback_id = excluded.rollback_id,
+			next_run = excluded.next_run
+	`
+
+	_, err = tx.ExecContext(ctx, query,
+		record.Update.ID,
+		string(updateJSON),
+		record.Status,
+		record.PausedFrom,
+		formatTime(record.CreatedAt),
+		formatOptionalTime(record.StartedAt),
+		record.Phase,
+		string(approvalsJSON),
+		record.RollbackID,
+		formatOptionalTime(record.NextRun),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save update: %w", err)
+	}
+
+	if !previous.Valid || previous.String != string(record.Status) {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO update_transitions (update_id, status, changed_at) VALUES (?, ?, ?)",
+			record.Update.ID, record.Status, formatTime(time.Now()),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record transition: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit update: %w", err)
+	}
+
+	return nil
+}
+
+// SaveDevice records the outcome of an update on one device.
+func (s *Store) SaveDevice(ctx context.Context, updateID string, outcome scheduler.DeviceOutcome) error {
+	query := `
+		INSERT INTO update_devices (update_id, device_id, status, error, recorded_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(update_id, device_id) DO UPDATE SET
+			status = excluded.status,
+			error = excluded.error,
+			recorded_at = excluded.recorded_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		updateID,
+		outcome.DeviceID,
+		outcome.Status,
+		outcome.Error,
+		formatTime(outcome.Time),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey {
+			return fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+		}
+		return fmt.Errorf("failed to save device outcome: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns an update's record, including its device outcomes.
+func (s *Store) Get(ctx context.Context, updateID string) (*scheduler.Record, error) {
+	row := s.db.QueryRowContext(ctx, selectRecords+" WHERE id = ?", updateID)
+
+	record, err := scanRecord(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+		}
+		return nil, err
+	}
+
+	devices, err := s.deviceOutcomes(ctx, updateID)
+	if err != nil {
+		return nil, err
+	}
+	record.Devices = devices[updateID]
+
+	return &record, nil
+}
+
+// List returns the records of all updates, oldest first.
+func (s *Store) List(ctx context.Context) ([]scheduler.Record, error) {
+	rows, err := s.db.QueryContext(ctx, selectRecords+" ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query updates: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]scheduler.Record, 0)
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate updates: %w", err)
+	}
+
+	devices, err := s.deviceOutcomes(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		records[i].Devices = devices[records[i].Update.ID]
+		if records[i].Devices == nil {
+			records[i].Devices = make(map[string]scheduler.DeviceOutcome)
+		}
+	}
+
+	return records, nil
+}
+
+// Transitions returns an update's status history, oldest first.
+func (s *Store) Transitions(ctx context.Context, updateID string) ([]scheduler.Transition, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT status, changed_at FROM update_transitions WHERE update_id = ? ORDER BY id",
+		updateID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := make([]scheduler.Transition, 0)
+	for rows.Next() {
+		var transition scheduler.Transition
+		var changedAt string
+		if err := rows.Scan(&transition.Status, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transition: %w", err)
+		}
+		if transition.Time, err = time.Parse(timeFormat, changedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse changed_at: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transitions: %w", err)
+	}
+
+	if len(transitions) == 0 {
+		var exists int
+		err := s.db.QueryRowContext(ctx, "SELECT 1 FROM scheduled_updates WHERE id = ?", updateID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", core.ErrUpdateNotFound, updateID)
+		}
+	}
+
+	return transitions, nil
+}
+
+const selectRecords = "SELECT update_json, status, paused_from, created_at, started_at, phase, approvals, rollback_id, next_run FROM scheduled_updates"
+
+// scanRecord scans a row into a Record (without device outcomes).
+func scanRecord(row interface {
+	Scan(dest ...interface{}) error
+}) (scheduler.Record, error) {
+	var record scheduler.Record
+	var updateJSON, createdAtStr string
+	var pausedFrom, startedAtStr, approvalsJSON, rollbackID, nextRunStr sql.NullString
+
+	err := row.Scan(
+		&updateJSON,
+		&record.Status,
+		&pausedFrom,
+		&createdAtStr,
+		&startedAtStr,
+		&record.Phase,
+		&approvalsJSON,
+		&rollbackID,
+		&nextRunStr,
+	)
+	if err != nil {
+		// Return sql.ErrNoRows unwrapped so it can be detected
+		if errors.Is(err, sql.ErrNoRows) {
+			return scheduler.Record{}, err
+		}
+		return scheduler.Record{}, fmt.Errorf("failed to scan update: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(updateJSON), &record.Update); err != nil {
+		return scheduler.Record{}, fmt.Errorf("failed to parse update: %w", err)
+	}
+	if approvalsJSON.Valid {
+		if err := json.Unmarshal([]byte(approvalsJSON.String), &record.Approvals); err != nil {
+			return scheduler.Record{}, fmt.Errorf("failed to parse approvals: %w", err)
+		}
+	}
+	record.PausedFrom = core.UpdateStatus(pausedFrom.String)
+	record.RollbackID = rollbackID.String
+
+	if record.CreatedAt, err = time.Parse(timeFormat, createdAtStr); err != nil {
+		return scheduler.Record{}, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	if record.StartedAt, err = parseOptionalTime(startedAtStr); err != nil {
+		return scheduler.Record{}, fmt.Errorf("failed to parse started_at: %w", err)
+	}
+	if record.NextRun, err = parseOptionalTime(nextRunStr); err != nil {
+		return scheduler.Record{}, fmt.Errorf("failed to parse next_run: %w", err)
+	}
+
+	return record, nil
+}
+
+// deviceOutcomes returns device outcomes keyed by update and device ID, for
+// one update or (if updateID is empty) all of them.
+func (s *Store) deviceOutcomes(ctx context.Context, updateID string) (map[string]map[string]scheduler.DeviceOutcome, error) {
+	query := "SELECT update_id, device_id, status, error, recorded_at FROM update_devices"
+	var args []interface{}
+	if updateID != "" {
+		query += " WHERE update_id = ?"
+		args = append(args, updateID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	outcomes := make(map[string]map[string]scheduler.DeviceOutcome)
+	if updateID != "" {
+		outcomes[updateID] = make(map[string]scheduler.DeviceOutcome)
+	}
+	for rows.Next() {
+		var id, recordedAt string
+		var errStr sql.NullString
+		var outcome scheduler.DeviceOutcome
+		if err := rows.Scan(&id, &outcome.DeviceID, &outcome.Status, &errStr, &recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device outcome: %w", err)
+		}
+		outcome.Error = errStr.String
+		if outcome.Time, err = time.Parse(timeFormat, recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse recorded_at: %w", err)
+		}
+
+		if outcomes[id] == nil {
+			outcomes[id] = make(map[string]scheduler.DeviceOutcome)
+		}
+		outcomes[id][outcome.DeviceID] = outcome
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate device outcomes: %w", err)
+	}
+
+	return outcomes, nil
+}
+
+// formatTime formats a timestamp for storage.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// formatOptionalTime formats an optional timestamp for storage.
+func formatOptionalTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+// parseOptionalTime parses an optional stored timestamp.
+func parseOptionalTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(timeFormat, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,572 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
+	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+	"github.com/dovaclean/go-update-orchestrator/pkg/registry/memory"
+	"github.com/dovaclean/go-update-orchestrator/pkg/scheduler"
+	"github.com/dovaclean/go-update-orchestrator/testing/mocks"
+)
+
+func TestSQLiteStore_SaveAndGet(t *testing.T) {
+	store := setupTestStore(t, filepath.Join(t.TempDir(), "scheduler.db"))
+	ctx := context.Background()
+
+	now := time.Now()
+	next := now.Add(time.Hour)
+	record := scheduler.Record{
+		Update: core.Update{
+			ID:         "update-1",
+			PayloadURL: "https://example.com/firmware.bin",
+			Strategy:   core.StrategyProgressive,
+			RolloutPhases: []core.RolloutPhase{
+				{Name: "Canary", Percentage: 10, WaitTime: time.Minute},
+			},
+		},
+		Status:     core.StatusAwaitingApproval,
+		PausedFrom: core.StatusInProgress,
+		CreatedAt:  now,
+		StartedAt:  &now,
+		Phase:      1,
+		Approvals:  []core.Approval{{Phase: "Canary", Approver: "ops", Approved: true, Time: now}},
+		NextRun:    &next,
+	}
+
+	if err := store.Save(ctx, record); err != nil {
+		t.Fatalf("Failed to save record: %v", err)
+	}
+	if err := store.SaveDevice(ctx, "update-1", scheduler.DeviceOutcome{DeviceID: "device-1", Status: core.StatusCompleted, Time: now}); err != nil {
+		t.Fatalf("Failed to save device outcome: %v", err)
+	}
+	if err := store.SaveDevice(ctx, "update-1", scheduler.DeviceOutcome{DeviceID: "device-2", Status: core.StatusFailed, Error: "timeout", Time: now}); err != nil {
+		t.Fatalf("Failed to save device outcome: %v", err)
+	}
+
+	got, err := store.Get(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+
+	if got.Status != record.Status || got.PausedFrom != record.PausedFrom || got.Phase != 1 {
+		t.Errorf("Unexpected status fields: %+v", got)
+	}
+	if got.Update.RolloutPhases[0].WaitTime != time.Minute {
+		t.Errorf("Expected rollout phases to round-trip, got %+v", got.Update.RolloutPhases)
+	}
+	if !got.CreatedAt.Equal(now) || got.StartedAt == nil || !got.StartedAt.Equal(now) {
+		t.Errorf("Expected timestamps to round-trip, got %v / %v", got.CreatedAt, got.StartedAt)
+	}
+	if got.NextRun == nil || !got.NextRun.Equal(next) {
+		t.Errorf("Expected next run %v, got %v", next, got.NextRun)
+	}
+	if len(got.Approvals) != 1 || got.Approvals[0].Approver != "ops" {
+		t.Errorf("Expected approvals to round-trip, got %+v", got.Approvals)
+	}
+	if len(got.Devices) != 2 || got.Devices["device-2"].Error != "timeout" {
+		t.Errorf("Expected 2 device outcomes, got %+v", got.Devices)
+	}
+}
+
+func TestSQLiteStore_Transitions(t *testing.T) {
+	store := setupTestStore(t, filepath.Join(t.TempDir(), "scheduler.db"))
+	ctx := context.Background()
+
+	record := scheduler.Record{Update: core.Update{ID: "update-1"}, CreatedAt: time.Now()}
+	for _, status := range []core.UpdateStatus{
+		core.StatusPending,
+		core.StatusInProgress,
+		core.StatusInProgress, // Not a transition
+		core.StatusCompleted,
+	} {
+		record.Status = status
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatalf("Failed to save record: %v", err)
+		}
+	}
+
+	transitions, err := store.Transitions(ctx, "update-1")
+	if err != nil {
+		t.Fatalf("Failed to get transitions: %v", err)
+	}
+
+	expected := []core.UpdateStatus{core.StatusPending, core.StatusInProgress, core.StatusCompleted}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected %d transitions, got %d", len(expected), len(transitions))
+	}
+	for i, status := range expected {
+		if transitions[i].Status != status {
+			t.Errorf("Transition %d: expected %s, got %s", i, status, transitions[i].Status)
+		}
+	}
+}
+
+func TestSQLiteStore_ListOrdersByCreation(t *testing.T) {
+	store := setupTestStore(t, filepath.Join(t.TempDir(), "scheduler.db"))
+	ctx := context.Background()
+
+	base := time.Now()
+	for i, id := range []string{"third", "first", "second"} {
+		offset := map[int]time.Duration{0: 2 * time.Second, 1: 0, 2: time.Second}[i]
+		store.Save(ctx, scheduler.Record{Update: core.Update{ID: id}, Status: core.StatusPending, CreatedAt: base.Add(offset)})
+	}
+
+	records, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	for i, id := range []string{"first", "second", "third"} {
+		if records[i].Update.ID != id {
+			t.Errorf("Record %d: expected %s, got %s", i, id, records[i].Update.ID)
+		}
+	}
+}
+
+func TestSQLiteStore_NotFound(t *testing.T) {
+	store := setupTestStore(t, filepath.Join(t.TempDir(), "scheduler.db"))
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound from Get, got %v", err)
+	}
+	if _, err := store.Transitions(ctx, "missing"); !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound from Transitions, got %v", err)
+	}
+	err := store.SaveDevice(ctx, "missing", scheduler.DeviceOutcome{DeviceID: "device-1", Status: core.StatusCompleted})
+	if !errors.Is(err, core.ErrUpdateNotFound) {
+		t.Errorf("Expected ErrUpdateNotFound from SaveDevice, got %v", err)
+	}
+}
+
+func TestSQLiteStore_SchedulerResumesAfterRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "scheduler.db")
+	registry := setupTestDevices(t, 10)
+
+	update := core.Update{
+		ID:         "restart",
+		PayloadURL: writePayload(t),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20},
+			{Name: "Stores", Percentage: 80, RequiresApproval: true},
+		},
+	}
+
+	// First process: the canary phase runs, then the rollout waits for approval
+	store := setupTestStore(t, dbPath)
+	first, firstDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverResume)
+
+	ctx := context.Background()
+	if err := first.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+	if err := first.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	waitForStatus(t, first, "restart", core.StatusAwaitingApproval)
+	waitForOutcomes(t, store, "restart", 2)
+
+	// The process shuts down while the rollout is held
+	stopScheduler(t, first)
+	expectStored(t, store, "restart", core.StatusAwaitingApproval)
+	store.Close()
+
+	if firstDelivery.GetPushCount() != 2 {
+		t.Fatalf("Expected 2 canary pushes before restart, got %d", firstDelivery.GetPushCount())
+	}
+
+	// Second process: the update resumes at the held phase
+	store = setupTestStore(t, dbPath)
+	second, secondDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverResume)
+
+	if err := second.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer second.Stop()
+
+	waitForStatus(t, second, "restart", core.StatusAwaitingApproval)
+	if err := second.Approve(ctx, "restart", "Stores", "ops@example.com"); err != nil {
+		t.Fatalf("Failed to approve: %v", err)
+	}
+	waitForStatus(t, second, "restart", core.StatusCompleted)
+
+	// Canary devices are not updated again
+	if secondDelivery.GetPushCount() != 8 {
+		t.Errorf("Expected 8 pushes after restart, got %d", secondDelivery.GetPushCount())
+	}
+
+	record, err := store.Get(ctx, "restart")
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	waitForOutcomes(t, store, "restart", 10)
+
+	transitions, err := store.Transitions(ctx, "restart")
+	if err != nil {
+		t.Fatalf("Failed to get transitions: %v", err)
+	}
+	expected := []core.UpdateStatus{
+		core.StatusPending,
+		core.StatusInProgress,
+		core.StatusAwaitingApproval,
+		core.StatusPending, // Recovered after the restart
+		core.StatusInProgress,
+		core.StatusAwaitingApproval,
+		core.StatusInProgress,
+		core.StatusCompleted,
+	}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected %d transitions, got %+v", len(expected), transitions)
+	}
+	for i, status := range expected {
+		if transitions[i].Status != status {
+			t.Errorf("Transition %d: expected %s, got %s", i, status, transitions[i].Status)
+		}
+	}
+	if len(record.Approvals) != 1 {
+		t.Errorf("Expected 1 approval, got %+v", record.Approvals)
+	}
+}
+
+func TestSQLiteStore_SchedulerKeepsBlockedRolloutAcrossStop(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "scheduler.db")
+	registry := setupTestDevices(t, 10)
+
+	update := core.Update{
+		ID:         "blocked",
+		PayloadURL: writePayload(t),
+		Strategy:   core.StrategyProgressive,
+		RolloutPhases: []core.RolloutPhase{
+			{Name: "Canary", Percentage: 20, SuccessRate: 100, OnGateFailure: core.GateBlock},
+			{Name: "Stores", Percentage: 80},
+		},
+	}
+
+	// First process: the canary fails its gate, which blocks the rollout
+	store := setupTestStore(t, dbPath)
+	first, firstDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverResume)
+	firstDelivery.ShouldFail = true
+
+	ctx := context.Background()
+	if err := first.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+	if err := first.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	waitForStatus(t, first, "blocked", core.StatusPaused)
+
+	stopScheduler(t, first)
+	expectStored(t, store, "blocked", core.StatusPaused)
+	store.Close()
+
+	// Second process: the rollout is still blocked until an operator resumes it
+	store = setupTestStore(t, dbPath)
+	second, secondDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverResume)
+
+	if err := second.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer second.Stop()
+
+	waitForStatus(t, second, "blocked", core.StatusPaused)
+	time.Sleep(50 * time.Millisecond)
+	if secondDelivery.GetPushCount() != 0 {
+		t.Fatalf("Expected no pushes while blocked, got %d", secondDelivery.GetPushCount())
+	}
+
+	if err := second.Resume(ctx, "blocked"); err != nil {
+		t.Errorf("Failed to resume the recovered rollout: %v", err)
+	}
+}
+
+func TestSQLiteStore_SchedulerFailsInterruptedUpdates(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "scheduler.db")
+	registry := setupTestDevices(t, 2)
+
+	store := setupTestStore(t, dbPath)
+	first, firstDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverFail)
+	firstDelivery.PushDelay = 10 * time.Second
+
+	ctx := context.Background()
+	first.Schedule(ctx, core.Update{ID: "interrupted", PayloadURL: writePayload(t), Strategy: core.StrategyImmediate})
+	if err := first.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+
+	for firstDelivery.GetPushCount() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stopScheduler(t, first)
+	expectStored(t, store, "interrupted", core.StatusInProgress)
+	store.Close()
+
+	store = setupTestStore(t, dbPath)
+	second, secondDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverFail)
+
+	if err := second.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer second.Stop()
+
+	status, err := second.Status(ctx, "interrupted")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.Status != core.StatusFailed {
+		t.Errorf("Expected interrupted update to be failed, got %s", status.Status)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if secondDelivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes after restart, got %d", secondDelivery.GetPushCount())
+	}
+}
+
+func TestSQLiteStore_SchedulerRecordsOrchestratorCancel(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "scheduler.db")
+	registry := setupTestDevices(t, 2)
+	store := setupTestStore(t, dbPath)
+
+	delivery := mocks.NewMockDelivery()
+	delivery.PushDelay = 10 * time.Second
+	sched, orch := setupTestSchedulerWith(t, store, registry, scheduler.RecoverResume, delivery)
+
+	ctx := context.Background()
+	sched.Schedule(ctx, core.Update{ID: "cancelled", PayloadURL: writePayload(t), Strategy: core.StrategyImmediate})
+	if err := sched.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer sched.Stop()
+
+	for delivery.GetPushCount() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Cancelling through the orchestrator is not a shutdown
+	if err := orch.Cancel(ctx, "cancelled"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	waitForStatus(t, sched, "cancelled", core.StatusCancelled)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		record, err := store.Get(ctx, "cancelled")
+		if err != nil {
+			t.Fatalf("Failed to get record: %v", err)
+		}
+		if record.Status == core.StatusCancelled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the store to record the cancellation, got %s", record.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSQLiteStore_SchedulerSkipsFailedDevicesOnResume(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "scheduler.db")
+	registry := setupTestDevices(t, 2)
+	update := core.Update{ID: "resumed", PayloadURL: writePayload(t), Strategy: core.StrategyImmediate}
+
+	// First process: device-1 fails while device-2 is still being updated
+	store := setupTestStore(t, dbPath)
+	firstDelivery := &holdingDelivery{MockDelivery: mocks.NewMockDelivery(), hold: "device-2"}
+	firstDelivery.FailDevices = map[string]bool{"device-1": true}
+	first, _ := setupTestSchedulerWith(t, store, registry, scheduler.RecoverResume, firstDelivery)
+
+	ctx := context.Background()
+	first.Schedule(ctx, update)
+	if err := first.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	waitForOutcomes(t, store, "resumed", 1)
+	stopScheduler(t, first)
+	expectStored(t, store, "resumed", core.StatusInProgress)
+	store.Close()
+
+	// Second process: only device-2 is updated; device-1 stays failed
+	store = setupTestStore(t, dbPath)
+	second, secondDelivery := setupTestScheduler(t, store, registry, scheduler.RecoverResume)
+
+	if err := second.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer second.Stop()
+
+	waitForStatus(t, second, "resumed", core.StatusCompleted)
+	if secondDelivery.GetPushCount() != 1 {
+		t.Errorf("Expected only device-2 to be pushed after restart, got %d pushes", secondDelivery.GetPushCount())
+	}
+
+	record, err := store.Get(ctx, "resumed")
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if outcome := record.Devices["device-1"]; outcome.Status != core.StatusFailed || outcome.Error == "" {
+		t.Errorf("Expected device-1 to stay failed with its error, got %+v", outcome)
+	}
+	if outcome := record.Devices["device-2"]; outcome.Status != core.StatusCompleted {
+		t.Errorf("Expected device-2 to be completed, got %+v", outcome)
+	}
+}
+
+// holdingDelivery holds pushes to one device until they are cancelled.
+type holdingDelivery struct {
+	*mocks.MockDelivery
+	hold string
+}
+
+func (d *holdingDelivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
+	if device.ID == d.hold {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return d.MockDelivery.Push(ctx, device, payload)
+}
+
+// setupTestStore opens a store that is closed when the test ends.
+func setupTestStore(t *testing.T, dbPath string) *Store {
+	t.Helper()
+
+	store, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+// setupTestDevices creates a registry with count online devices.
+func setupTestDevices(t *testing.T, count int) *memory.Registry {
+	t.Helper()
+
+	registry := memory.New()
+	for i := 1; i <= count; i++ {
+		registry.Add(context.Background(), core.Device{
+			ID:     fmt.Sprintf("device-%d", i),
+			Status: core.DeviceOnline,
+		})
+	}
+	return registry
+}
+
+// setupTestScheduler creates a scheduler persisting to store, with its own
+// orchestrator and mock delivery (as after a process restart).
+func setupTestScheduler(t *testing.T, store scheduler.Store, registry *memory.Registry, recovery scheduler.RecoveryPolicy) (*scheduler.Scheduler, *mocks.MockDelivery) {
+	t.Helper()
+
+	delivery := mocks.NewMockDelivery()
+	s, _ := setupTestSchedulerWith(t, store, registry, recovery, delivery)
+	return s, delivery
+}
+
+// setupTestSchedulerWith creates a scheduler persisting to store, pushing
+// through the given delivery, and returns it with its orchestrator.
+func setupTestSchedulerWith(t *testing.T, store scheduler.Store, registry *memory.Registry, recovery scheduler.RecoveryPolicy, delivery delivery.Delivery) (*scheduler.Scheduler, *orchestrator.Orchestrator) {
+	t.Helper()
+
+	orchConfig := orchestrator.DefaultConfig()
+	orchConfig.VerifyAfterPush = true
+	orchConfig.VerifyRetries = 1
+	orchConfig.VerifyPollInterval = time.Millisecond
+	orch, _ := orchestrator.NewDefault(orchConfig, registry, delivery)
+
+	config := scheduler.DefaultConfig()
+	config.TickInterval = 10 * time.Millisecond
+	config.Recovery = recovery
+
+	fetcherConfig := payload.DefaultConfig()
+	fetcherConfig.CacheDir = t.TempDir()
+
+	return scheduler.NewWithStore(config, orch, registry, payload.NewFetcher(fetcherConfig), store), orch
+}
+
+// writePayload writes a payload file and returns its path.
+func writePayload(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "firmware.bin")
+	if err := os.WriteFile(path, []byte("firmware v2.0"), 0o644); err != nil {
+		t.Fatalf("Failed to write payload: %v", err)
+	}
+	return path
+}
+
+// waitForStatus polls the scheduler until the update reaches the expected status.
+func waitForStatus(t *testing.T, s *scheduler.Scheduler, updateID string, expected core.UpdateStatus) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := s.Status(context.Background(), updateID)
+		if err == nil && status.Status == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for update %s to reach %s", updateID, expected)
+}
+
+// waitForOutcomes waits until the store has count device outcomes for an update.
+func waitForOutcomes(t *testing.T, store *Store, updateID string, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		record, err := store.Get(context.Background(), updateID)
+		if err == nil && len(record.Devices) >= count {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for %d device outcomes of %s", count, updateID)
+}
+
+// stopScheduler stops the scheduler as a process shutting down would,
+// failing the test if Stop does not return promptly.
+func stopScheduler(t *testing.T, s *scheduler.Scheduler) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Stop() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed to stop scheduler: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+}
+
+// expectStored checks the status persisted for an update.
+func expectStored(t *testing.T, store *Store, updateID string, expected core.UpdateStatus) {
+	t.Helper()
+
+	record, err := store.Get(context.Background(), updateID)
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if record.Status != expected {
+		t.Fatalf("Expected %s to be stored as %s, got %s", updateID, expected, record.Status)
+	}
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+// Store persists scheduler state so scheduled and in-flight updates survive
+// a restart. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save creates or replaces an update's record. A change of status is
+	// appended to the update's transition history.
+	Save(ctx context.Context, record Record) error
+
+	// SaveDevice records the outcome of an update on one device.
+	SaveDevice(ctx context.Context, updateID string, outcome DeviceOutcome) error
+
+	// Get returns an update's record, including its device outcomes.
+	// It returns core.ErrUpdateNotFound if the update is unknown.
+	Get(ctx context.Context, updateID string) (*Record, error)
+
+	// List returns the records of all updates, oldest first.
+	List(ctx context.Context) ([]Record, error)
+
+	// Transitions returns an update's status history, oldest first.
+	Transitions(ctx context.Context, updateID string) ([]Transition, error)
+
+	// Close releases the store's resources.
+	Close() error
+}
+
+// Record is the persisted state of a scheduled update.
+type Record struct {
+	Update     core.Update
+	Status     core.UpdateStatus
+	PausedFrom core.UpdateStatus // Status to restore on resume (paused or held updates)
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	Phase      int             // Rollout phase in progress (progressive updates)
+	Approvals  []core.Approval // Approval gate decisions, oldest first
+	RollbackID string          // Rollback update triggered by this update
+	NextRun    *time.Time      // Next firing not yet handled (recurring updates)
+
+	// Devices holds per-device outcomes keyed by device ID. It is filled in
+	// by Get and List and ignored by Save (use SaveDevice).
+	Devices map[string]DeviceOutcome
+}
+
+// DeviceOutcome is the result of an update on one device.
+type DeviceOutcome struct {
+	DeviceID string
+	Status   core.UpdateStatus // StatusCompleted or StatusFailed
+	Error    string            // Failure reason (if failed)
+	Time     time.Time         // When the outcome was recorded
+}
+
+// Transition is a change of an update's status.
+type Transition struct {
+	Status core.UpdateStatus
+	Time   time.Time
+}
+
+// RecoveryPolicy defines what Start does with updates that were running when
+// the scheduler last stopped.
+type RecoveryPolicy string
+
+const (
+	// RecoverResume runs interrupted updates again, skipping devices with a
+	// recorded outcome (failed devices are not retried) and rollout phases
+	// that already finished.
+	RecoverResume RecoveryPolicy = "resume"
+
+	// RecoverFail marks interrupted updates failed with core.ErrUpdateInterrupted.
+	RecoverFail RecoveryPolicy = "fail"
+)
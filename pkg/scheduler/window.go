@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload"
+)
+
+// window is a recurring update window resolved to a single time zone.
+type window struct {
+	start int // Opening time in minutes after local midnight
+	end   int // Closing time in minutes after local midnight
+	days  [7]bool
+	loc   *time.Location
+}
+
+// parseWindow resolves a recurring update window in the given time zone.
+func parseWindow(w core.UpdateWindow, zone string) (*window, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window start: %w", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window end: %w", err)
+	}
+
+	if zone == "" {
+		zone = "UTC"
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", zone, err)
+	}
+
+	parsed := &window{start: start, end: end, loc: loc}
+	if len(w.Days) == 0 {
+		for i := range parsed.days {
+			parsed.days[i] = true
+		}
+	}
+	for _, day := range w.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return nil, fmt.Errorf("invalid window day: %d", day)
+		}
+		parsed.days[day] = true
+	}
+
+	return parsed, nil
+}
+
+// parseClock parses an "HH:MM" time of day into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether the window is open at t. A window whose end is
+// before its start wraps past midnight and belongs to the day it opens on;
+// a window whose start equals its end is open all day.
+func (w *window) contains(t time.Time) bool {
+	local := t.In(w.loc)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+
+	switch {
+	case w.start < w.end:
+		return w.days[day] && minute >= w.start && minute < w.end
+	case w.start > w.end:
+		previous := (day + 6) % 7
+		return (w.days[day] && minute >= w.start) || (w.days[previous] && minute < w.end)
+	default:
+		return w.days[day]
+	}
+}
+
+// nextOpen returns the next time at or after t when the window is open.
+func (w *window) nextOpen(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+
+	local := t.In(w.loc)
+	for i := 0; i <= 7; i++ {
+		opens := time.Date(local.Year(), local.Month(), local.Day()+i, w.start/60, w.start%60, 0, 0, w.loc)
+		if opens.After(t) && w.days[opens.Weekday()] {
+			return opens
+		}
+	}
+
+	// Unreachable for a window open on at least one day
+	return t.Add(24 * time.Hour)
+}
+
+// deviceWindow resolves an update's recurring window for a device, using the
+// device's time zone attribute if it has a valid one.
+func deviceWindow(update core.Update, device core.Device) (*window, error) {
+	if zone := device.Metadata[core.MetadataTimeZone]; zone != "" {
+		if w, err := parseWindow(*update.Window, zone); err == nil {
+			return w, nil
+		}
+		// Fall back to the window's own time zone
+	}
+	return parseWindow(*update.Window, update.Window.TimeZone)
+}
+
+// validateWindow checks that an update's recurring window can be evaluated.
+func validateWindow(w core.UpdateWindow) error {
+	_, err := parseWindow(w, w.TimeZone)
+	return err
+}
+
+// executeDevices pushes an update to devices, honouring the update's
+// recurring window if it has one. ctx must be the orchestrator run context.
+func (s *Scheduler) executeDevices(ctx context.Context, update core.Update, devices []core.Device, staged *payload.Staged) error {
+	devices = s.remainingDevices(update.ID, devices)
+	if len(devices) == 0 {
+		return nil
+	}
+
+	if update.Window == nil {
+		return s.orchestrator.ExecuteOnDevices(ctx, update, devices, staged.Shared())
+	}
+	return s.executeWindowed(ctx, update, devices, staged)
+}
+
+// executeWindowed pushes an update to each device while the device's window
+// is open, waiting for windows to open as needed, so the update rolls across
+// time zones as each device's window opens. Devices whose window closes
+// before their push starts are held until it reopens; pushes already in
+// flight are allowed to finish.
+func (s *Scheduler) executeWindowed(ctx context.Context, update core.Update, devices []core.Device, staged *payload.Staged) error {
+	windows := make(map[string]*window, len(devices))
+	for _, device := range devices {
+		w, err := deviceWindow(update, device)
+		if err != nil {
+			return fmt.Errorf("device %s: %w", device.ID, err)
+		}
+		windows[device.ID] = w
+	}
+
+	gateCtx := orchestrator.WithDeviceGate(ctx, func(device core.Device) bool {
+		return windows[device.ID].contains(time.Now())
+	})
+
+	pending := devices
+	for len(pending) > 0 {
+		now := time.Now()
+		open := make([]core.Device, 0, len(pending))
+		var next time.Time
+		for _, device := range pending {
+			w := windows[device.ID]
+			if w.contains(now) {
+				open = append(open, device)
+			} else if opens := w.nextOpen(now); next.IsZero() || opens.Before(next) {
+				next = opens
+			}
+		}
+
+		if len(open) > 0 {
+			if err := s.orchestrator.ExecuteOnDevices(gateCtx, update, open, staged.Shared()); err != nil {
+				return err
+			}
+
+			var err error
+			if pending, err = s.unfinishedDevices(ctx, update.ID, pending); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Sleep until the next window opens, re-checking at least every tick
+		wait := time.Until(next)
+		if wait > s.config.TickInterval {
+			wait = s.config.TickInterval
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return core.ErrCancelled
+		}
+	}
+
+	return nil
+}
+
+// windowGate returns a device gate that only admits devices whose recurring
+// update window is currently open.
+func (s *Scheduler) windowGate(update core.Update, devices []core.Device) (orchestrator.DeviceGate, error) {
+	windows := make(map[string]*window, len(devices))
+	for _, device := range devices {
+		w, err := deviceWindow(update, device)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", device.ID, err)
+		}
+		windows[device.ID] = w
+	}
+
+	return func(device core.Device) bool {
+		w, ok := windows[device.ID]
+		return ok && w.contains(time.Now())
+	}, nil
+}
+
+// unfinishedDevices returns the devices whose update has neither completed
+// nor failed.
+func (s *Scheduler) unfinishedDevices(ctx context.Context, updateID string, devices []core.Device) ([]core.Device, error) {
+	status, err := s.orchestrator.GetStatus(ctx, updateID)
+	if err != nil {
+		return nil, err
+	}
+
+	unfinished := make([]core.Device, 0, len(devices))
+	for _, device := range devices {
+		switch core.UpdateStatus(status.DeviceStatus[device.ID]) {
+		case core.StatusCompleted, core.StatusFailed:
+		default:
+			unfinished = append(unfinished, device)
+		}
+	}
+	return unfinished, nil
+}
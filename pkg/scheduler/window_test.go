@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+)
+
+func TestWindow_Contains(t *testing.T) {
+	// Weeknights 01:00-05:00
+	w, err := parseWindow(core.UpdateWindow{
+		Start: "01:00",
+		End:   "05:00",
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"opening minute", time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC), true},
+		{"inside", time.Date(2026, 3, 2, 3, 30, 0, 0, time.UTC), true},
+		{"closing minute", time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC), false},
+		{"before", time.Date(2026, 3, 2, 0, 59, 0, 0, time.UTC), false},
+		{"weekend", time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.contains(tt.at); got != tt.want {
+				t.Errorf("contains(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow_WrapsPastMidnight(t *testing.T) {
+	// Friday night 22:00 until Saturday 02:00
+	w, err := parseWindow(core.UpdateWindow{
+		Start: "22:00",
+		End:   "02:00",
+		Days:  []time.Weekday{time.Friday},
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+
+	if !w.contains(time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC)) {
+		t.Error("Expected window open late Friday")
+	}
+	if !w.contains(time.Date(2026, 3, 7, 1, 0, 0, 0, time.UTC)) {
+		t.Error("Expected Friday's window to still be open early Saturday")
+	}
+	if w.contains(time.Date(2026, 3, 6, 1, 0, 0, 0, time.UTC)) {
+		t.Error("Expected window closed early Friday (Thursday is not a window day)")
+	}
+	if w.contains(time.Date(2026, 3, 7, 23, 0, 0, 0, time.UTC)) {
+		t.Error("Expected window closed late Saturday")
+	}
+}
+
+func TestWindow_TimeZone(t *testing.T) {
+	w, err := parseWindow(core.UpdateWindow{Start: "02:00", End: "04:00"}, "America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+
+	// 02:30 EST is 07:30 UTC in winter, 02:30 EDT is 06:30 UTC in summer
+	if !w.contains(time.Date(2026, 1, 15, 7, 30, 0, 0, time.UTC)) {
+		t.Error("Expected window open at 02:30 EST")
+	}
+	if !w.contains(time.Date(2026, 7, 15, 6, 30, 0, 0, time.UTC)) {
+		t.Error("Expected window open at 02:30 EDT")
+	}
+	if w.contains(time.Date(2026, 1, 15, 2, 30, 0, 0, time.UTC)) {
+		t.Error("Expected window closed at 02:30 UTC")
+	}
+}
+
+func TestWindow_NextOpen(t *testing.T) {
+	w, err := parseWindow(core.UpdateWindow{
+		Start: "01:00",
+		End:   "05:00",
+		Days:  []time.Weekday{time.Monday},
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+
+	// Saturday afternoon -> Monday 01:00
+	got := w.nextOpen(time.Date(2026, 3, 7, 15, 0, 0, 0, time.UTC))
+	want := time.Date(2026, 3, 9, 1, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("nextOpen = %s, want %s", got, want)
+	}
+
+	inside := time.Date(2026, 3, 9, 2, 0, 0, 0, time.UTC)
+	if got := w.nextOpen(inside); !got.Equal(inside) {
+		t.Errorf("nextOpen inside the window = %s, want %s", got, inside)
+	}
+}
+
+func TestWindow_Invalid(t *testing.T) {
+	tests := []core.UpdateWindow{
+		{Start: "25:00", End: "02:00"},
+		{Start: "01:00", End: "2am"},
+		{Start: "01:00", End: "02:00", TimeZone: "Mars/Olympus_Mons"},
+		{Start: "01:00", End: "02:00", Days: []time.Weekday{7}},
+	}
+
+	for _, w := range tests {
+		if err := validateWindow(w); err == nil {
+			t.Errorf("Expected error for window %+v", w)
+		}
+	}
+}
+
+func TestScheduler_WindowFollowsDeviceTimeZone(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 2)
+	ctx := context.Background()
+
+	// device-2 is twelve hours ahead of UTC, outside a window around now
+	device, _ := scheduler.registry.Get(ctx, "device-2")
+	device.Metadata = map[string]string{core.MetadataTimeZone: "Etc/GMT-12"}
+	scheduler.registry.Update(ctx, *device)
+
+	now := time.Now().UTC()
+	update := core.Update{
+		ID:         "windowed",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyImmediate,
+		Window: &core.UpdateWindow{
+			Start: now.Add(-time.Hour).Format("15:04"),
+			End:   now.Add(time.Hour).Format("15:04"),
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	waitForPushes(t, delivery, 1)
+	time.Sleep(50 * time.Millisecond)
+
+	if delivery.GetPushCount() != 1 {
+		t.Fatalf("Expected only device-1 to be updated, got %d pushes", delivery.GetPushCount())
+	}
+
+	status, err := scheduler.orchestrator.GetStatus(ctx, "windowed")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if status.DeviceStatus["device-1"] != string(core.StatusCompleted) {
+		t.Errorf("Expected device-1 completed, got %q", status.DeviceStatus["device-1"])
+	}
+	if status.DeviceStatus["device-2"] == string(core.StatusCompleted) {
+		t.Error("Expected device-2 to wait for its window")
+	}
+
+	// The update stays running until device-2's window opens
+	scheduled, _ := scheduler.Status(ctx, "windowed")
+	if scheduled.Status != core.StatusInProgress {
+		t.Errorf("Expected update in progress, got %s", scheduled.Status)
+	}
+
+	if err := scheduler.Cancel(ctx, "windowed"); err != nil {
+		t.Fatalf("Failed to cancel update: %v", err)
+	}
+}
+
+func TestScheduler_StopWhileWaitingForWindow(t *testing.T) {
+	scheduler, delivery := setupExecutingScheduler(t, 2)
+	ctx := context.Background()
+
+	// The window is closed for every device for the next two hours
+	now := time.Now().UTC()
+	update := core.Update{
+		ID:         "window-stop",
+		PayloadURL: writePayload(t, "firmware v2.0"),
+		Strategy:   core.StrategyImmediate,
+		Window: &core.UpdateWindow{
+			Start: now.Add(2 * time.Hour).Format("15:04"),
+			End:   now.Add(3 * time.Hour).Format("15:04"),
+		},
+	}
+
+	if err := scheduler.Schedule(ctx, update); err != nil {
+		t.Fatalf("Failed to schedule update: %v", err)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatalf("Failed to start scheduler: %v", err)
+	}
+	waitForStatus(t, scheduler, "window-stop", core.StatusInProgress)
+
+	// Wait for the payload to be staged, so the update is waiting for the window
+	deadline := time.Now().Add(5 * time.Second)
+	for !scheduler.orchestrator.IsRunning("window-stop") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the update to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stopWithin(t, scheduler, 5*time.Second)
+
+	if delivery.GetPushCount() != 0 {
+		t.Errorf("Expected no pushes outside the window, got %d", delivery.GetPushCount())
+	}
+}
+
+func TestScheduler_ScheduleRejectsInvalidWindow(t *testing.T) {
+	scheduler := setupTestScheduler(t)
+
+	update := core.Update{
+		ID:       "bad-window",
+		Strategy: core.StrategyImmediate,
+		Window:   &core.UpdateWindow{Start: "01:00", End: "later"},
+	}
+
+	if err := scheduler.Schedule(context.Background(), update); err == nil {
+		t.Error("Expected error scheduling an update with an invalid window")
+	}
+}
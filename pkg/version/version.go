@@ -0,0 +1,297 @@
+// Package version parses and compares firmware version strings.
+//
+// Strict parsing follows Semantic Versioning 2.0.0, including pre-release
+// identifiers and build metadata. Lenient parsing additionally accepts the
+// vendor formats commonly reported by devices: a leading "v", missing minor or
+// patch components ("2.3"), and extra numeric components ("1.2.3.4"). In
+// lenient mode, pre-release identifiers are split at letter/digit boundaries
+// so vendor revisions order numerically ("2.3-r7" < "2.3-r10").
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidVersion is returned when a string is not a recognisable version.
+var ErrInvalidVersion = errors.New("invalid version")
+
+// Version is a parsed version number.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Extra      []uint64 // Numeric components beyond patch (lenient mode only)
+	Prerelease []string // Dot-separated pre-release identifiers
+	Build      string   // Build metadata (ignored for precedence)
+}
+
+// Parse parses a strict semantic version such as "1.2.3-rc.1+build.5".
+// A single leading "v" is accepted.
+func Parse(s string) (Version, error) {
+	return parse(s, false)
+}
+
+// ParseLenient parses a version string, tolerating vendor formats such as
+// "v2.3-r7" or "1.2.3.4". Missing minor and patch components are zero.
+func ParseLenient(s string) (Version, error) {
+	return parse(s, true)
+}
+
+// Compare compares two version strings using lenient parsing.
+// It returns -1, 0 or +1 depending on whether a is lower, equal to or higher
+// than b, and an error if either string cannot be parsed.
+func Compare(a, b string) (int, error) {
+	va, err := ParseLenient(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseLenient(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// InRange reports whether v lies within [min, max] using lenient parsing.
+// An empty bound is unbounded. Unparsable versions never match a bounded range.
+func InRange(v, min, max string) bool {
+	if min != "" {
+		if c, err := Compare(v, min); err != nil || c < 0 {
+			return false
+		}
+	}
+	if max != "" {
+		if c, err := Compare(v, max); err != nil || c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare compares v with other by semver precedence.
+// Extra components compare after patch, with missing components treated as zero.
+// Build metadata is ignored.
+func (v Version) Compare(other Version) int {
+	if c := compareUint(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	for i := 0; i < len(v.Extra) || i < len(other.Extra); i++ {
+		var a, b uint64
+		if i < len(v.Extra) {
+			a = v.Extra[i]
+		}
+		if i < len(other.Extra) {
+			b = other.Extra[i]
+		}
+		if c := compareUint(a, b); c != 0 {
+			return c
+		}
+	}
+
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// String returns the canonical form of the version.
+func (v Version) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d.%d.%d", v.Major, v.Minor, v.Patch)
+	for _, n := range v.Extra {
+		fmt.Fprintf(&b, ".%d", n)
+	}
+	if len(v.Prerelease) > 0 {
+		b.WriteString("-")
+		b.WriteString(strings.Join(v.Prerelease, "."))
+	}
+	if v.Build != "" {
+		b.WriteString("+")
+		b.WriteString(v.Build)
+	}
+	return b.String()
+}
+
+// parse implements both strict and lenient parsing.
+func parse(s string, lenient bool) (Version, error) {
+	var v Version
+
+	original := s
+	s = strings.TrimSpace(s)
+	if lenient {
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	} else {
+		s = strings.TrimPrefix(s, "v")
+	}
+	if s == "" {
+		return v, fmt.Errorf("%w: %q", ErrInvalidVersion, original)
+	}
+
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if !validIdentifiers(v.Build, false) {
+			return v, fmt.Errorf("%w: %q: invalid build metadata", ErrInvalidVersion, original)
+		}
+	}
+
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if !validIdentifiers(pre, !lenient) {
+			return v, fmt.Errorf("%w: %q: invalid pre-release", ErrInvalidVersion, original)
+		}
+		v.Prerelease = strings.Split(pre, ".")
+		if lenient {
+			v.Prerelease = splitAlphaNum(v.Prerelease)
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if !lenient && len(parts) != 3 {
+		return v, fmt.Errorf("%w: %q: expected MAJOR.MINOR.PATCH", ErrInvalidVersion, original)
+	}
+
+	nums := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := parseNumber(part, !lenient)
+		if err != nil {
+			return v, fmt.Errorf("%w: %q: %v", ErrInvalidVersion, original, err)
+		}
+		nums[i] = n
+	}
+
+	v.Major = nums[0]
+	if len(nums) > 1 {
+		v.Minor = nums[1]
+	}
+	if len(nums) > 2 {
+		v.Patch = nums[2]
+	}
+	if len(nums) > 3 {
+		v.Extra = nums[3:]
+	}
+
+	return v, nil
+}
+
+// parseNumber parses a numeric version component.
+func parseNumber(s string, strict bool) (uint64, error) {
+	if s == "" || !isNumeric(s) {
+		return 0, fmt.Errorf("non-numeric component %q", s)
+	}
+	if strict && len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("leading zero in component %q", s)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// validIdentifiers checks dot-separated pre-release or build identifiers.
+// Strict pre-release identifiers may not be numeric with leading zeros.
+func validIdentifiers(s string, strictNumeric bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+		if strictNumeric && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitAlphaNum splits identifiers at letter/digit boundaries ("r7" -> "r", "7").
+func splitAlphaNum(ids []string) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		start := 0
+		for i := 1; i < len(id); i++ {
+			if isDigit(id[i]) != isDigit(id[i-1]) {
+				out = append(out, id[start:i])
+				start = i
+			}
+		}
+		out = append(out, id[start:])
+	}
+	return out
+}
+
+// comparePrerelease compares pre-release identifiers by semver precedence.
+// A version without a pre-release has higher precedence than one with.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+// compareIdentifier compares a single pre-release identifier. Numeric
+// identifiers compare numerically and sort before alphanumeric ones.
+func compareIdentifier(a, b string) int {
+	aNum, bNum := isNumeric(a), isNumeric(b)
+	switch {
+	case aNum && bNum:
+		na, _ := strconv.ParseUint(a, 10, 64)
+		nb, _ := strconv.ParseUint(b, 10, 64)
+		return compareUint(na, nb)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
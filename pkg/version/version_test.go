@@ -0,0 +1,121 @@
+package version
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse_Strict(t *testing.T) {
+	v, err := Parse("1.2.3-rc.1+build.5")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if v.Major != 1 || v.Minor != 2 || v.Patch != 3 {
+		t.Errorf("expected 1.2.3, got %d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+	if len(v.Prerelease) != 2 || v.Prerelease[0] != "rc" || v.Prerelease[1] != "1" {
+		t.Errorf("expected pre-release [rc 1], got %v", v.Prerelease)
+	}
+	if v.Build != "build.5" {
+		t.Errorf("expected build 'build.5', got %q", v.Build)
+	}
+	if v.String() != "1.2.3-rc.1+build.5" {
+		t.Errorf("expected canonical string, got %q", v.String())
+	}
+}
+
+func TestParse_StrictRejectsVendorFormats(t *testing.T) {
+	for _, s := range []string{"", "1.2", "1.2.3.4", "01.2.3", "1.2.3-01", "1.2.x", "1.2.3-", "1.2.3+", "v2.3-r7"} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("Parse(%q): expected ErrInvalidVersion, got %v", s, err)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"v2.3-r7", "2.3.0-r.7"},
+		{"V2", "2.0.0"},
+		{"1.2.3.4", "1.2.3.4"},
+		{" 1.02.3 ", "1.2.3"},
+		{"2.0.0-beta2+ci", "2.0.0-beta.2+ci"},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseLenient(tt.input)
+		if err != nil {
+			t.Errorf("ParseLenient(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if v.String() != tt.want {
+			t.Errorf("ParseLenient(%q) = %q, want %q", tt.input, v.String(), tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "v", "latest", "1..2", "1.2.3-r_7"} {
+		if _, err := ParseLenient(s); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("ParseLenient(%q): expected ErrInvalidVersion, got %v", s, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10.0", "1.9.0", 1},
+		{"1.9.0", "1.10.0", -1},
+		{"v1.0.0", "1.0.0", 0},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{"2.3", "2.3.0", 0},
+		{"v2.3-r7", "v2.3-r10", -1},
+		{"1.2.3.4", "1.2.3", 1},
+		{"1.2.3.0", "1.2.3", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := Compare(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Compare(%q, %q) failed: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if _, err := Compare("unknown", "1.0.0"); err == nil {
+		t.Error("expected error comparing unparsable version")
+	}
+}
+
+func TestInRange(t *testing.T) {
+	tests := []struct {
+		v, min, max string
+		want        bool
+	}{
+		{"1.10.0", "1.9.0", "", true},
+		{"1.10.0", "", "1.9.0", false},
+		{"1.9.5", "1.9.0", "1.10.0", true},
+		{"1.0.0", "", "", true},
+		{"", "1.0.0", "", false},
+		{"garbage", "", "2.0.0", false},
+		{"1.0.0", "garbage", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := InRange(tt.v, tt.min, tt.max); got != tt.want {
+			t.Errorf("InRange(%q, %q, %q) = %v, want %v", tt.v, tt.min, tt.max, got, tt.want)
+		}
+	}
+}
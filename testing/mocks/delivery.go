@@ -3,8 +3,11 @@ package mocks
 import (
 	"context"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/delivery"
 )
 
 // MockDelivery is a mock delivery mechanism for testing
@@ -12,6 +15,18 @@ type MockDelivery struct {
 	PushCount   int
 	VerifyCount int
 	ShouldFail  bool
+	PushDelay   time.Duration   // Simulated transfer time (aborted by context cancellation)
+	FailDevices map[string]bool // Devices whose Push fails
+
+	VerifyShouldFail bool   // Fail every Verify call (device never comes back healthy)
+	VerifyFailures   int    // Fail this many Verify calls before succeeding (rebooting device)
+	ReportedVersion  string // Firmware version Verify reports (compared against the target version)
+	VerifyFailAfter  int    // Fail Verify calls once this many have been made (degrading device)
+
+	RejectDeltas bool // Reject delta payloads with delivery.ErrDeltaRejected
+	DeltaCount   int  // Number of delta payloads pushed
+
+	mu sync.Mutex
 }
 
 // NewMockDelivery creates a new mock delivery
@@ -21,25 +36,83 @@ func NewMockDelivery() *MockDelivery {
 
 // Push simulates pushing an update
 func (m *MockDelivery) Push(ctx context.Context, device core.Device, payload io.Reader) error {
+	_, isDelta := delivery.Delta(ctx)
+
+	m.mu.Lock()
 	m.PushCount++
+	shouldFail := m.ShouldFail || m.FailDevices[device.ID]
+	delay := m.PushDelay
+	rejectDelta := isDelta && m.RejectDeltas
+	if isDelta && !rejectDelta {
+		m.DeltaCount++
+	}
+	m.mu.Unlock()
 
-	if m.ShouldFail {
+	if rejectDelta {
+		return delivery.ErrDeltaRejected
+	}
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if shouldFail {
 		return core.ErrDeliveryFailed
 	}
 
-	// Drain the payload
-	io.Copy(io.Discard, payload)
+	// Drain the payload, reporting progress like a real backend
+	io.Copy(io.Discard, delivery.NewProgressReader(ctx, payload))
 
 	return nil
 }
 
 // Verify simulates verifying an update
 func (m *MockDelivery) Verify(ctx context.Context, device core.Device) error {
+	m.mu.Lock()
 	m.VerifyCount++
+	shouldFail := m.ShouldFail || m.VerifyShouldFail
+	if m.VerifyFailAfter > 0 && m.VerifyCount > m.VerifyFailAfter {
+		shouldFail = true
+	}
+	if m.VerifyFailures > 0 {
+		m.VerifyFailures--
+		shouldFail = true
+	}
+	reported := m.ReportedVersion
+	m.mu.Unlock()
 
-	if m.ShouldFail {
+	if shouldFail {
 		return core.ErrVerificationFailed
 	}
 
+	if expected, ok := delivery.TargetVersion(ctx); ok && reported != "" {
+		return delivery.CheckVersion(device.ID, expected, reported)
+	}
+
 	return nil
 }
+
+// GetPushCount returns the number of Push calls.
+func (m *MockDelivery) GetPushCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.PushCount
+}
+
+// GetDeltaCount returns the number of delta payloads pushed.
+func (m *MockDelivery) GetDeltaCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.DeltaCount
+}
+
+// GetVerifyCount returns the number of Verify calls.
+func (m *MockDelivery) GetVerifyCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.VerifyCount
+}
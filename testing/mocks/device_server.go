@@ -1,13 +1,22 @@
 package mocks
 
 import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/dovaclean/go-update-orchestrator/pkg/delta"
 )
 
 // DeviceServer simulates a POS device HTTP server for testing.
@@ -18,8 +27,17 @@ type DeviceServer struct {
 	updateCount     int
 	lastUpdateTime  time.Time
 	lastUpdateSize  int64
-	failNext        bool // Simulate failure on next update
-	alwaysFail      bool // Always fail all updates
+	failNext        bool              // Simulate failure on next update
+	alwaysFail      bool              // Always fail all updates
+	manifestKey     ed25519.PublicKey // Key update manifests must be signed with (optional)
+	lastManifest    []byte            // Manifest received with the last update
+	resumable       bool              // Support resumable uploads
+	partial         map[string][]byte // Bytes held from interrupted uploads, by payload digest
+	interruptAfter  int64             // Drop the next upload after this many bytes
+	bytesReceived   int64             // Payload bytes received over all uploads
+	image           []byte            // Installed firmware image, the base for delta payloads
+	acceptDeltas    bool              // Apply delta payloads
+	deltaCount      int               // Delta payloads applied
 }
 
 // VersionResponse is the JSON response from /version endpoint.
@@ -41,12 +59,23 @@ type UpdateResponse struct {
 func NewDeviceServer(initialVersion string) *DeviceServer {
 	ds := &DeviceServer{
 		firmwareVersion: initialVersion,
+		partial:         make(map[string][]byte),
 	}
 
 	mux := http.NewServeMux()
 
 	// POST /update - Receive firmware update
+	// HEAD /update - Report the offset of an interrupted upload (resumable only)
 	mux.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && ds.isResumable() {
+			ds.mu.RLock()
+			offset := len(ds.partial[r.Header.Get("X-Payload-SHA256")])
+			ds.mu.RUnlock()
+
+			w.Header().Set("Upload-Offset", strconv.Itoa(offset))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -68,15 +97,47 @@ func NewDeviceServer(initialVersion string) *DeviceServer {
 			return
 		}
 
-		// Read the update payload
-		body, err := io.ReadAll(r.Body)
+		// Read the update payload, or the rest of an interrupted one
+		body, status, err := ds.readPayload(r)
 		if err != nil {
-			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		// Check the update manifest like a device that trusts manifestKey
+		manifest, _ := base64.StdEncoding.DecodeString(r.Header.Get("X-Update-Manifest"))
+		signature, _ := base64.StdEncoding.DecodeString(r.Header.Get("X-Update-Manifest-Signature"))
+		if ds.manifestKey != nil && !ed25519.Verify(ds.manifestKey, manifest, signature) {
+			http.Error(w, "Update manifest not trusted", http.StatusForbidden)
 			return
 		}
 
+		// Reject payloads that do not match the digest the orchestrator sent
+		if expected := r.Header.Get("X-Payload-SHA256"); expected != "" {
+			sum := sha256.Sum256(body)
+			if !strings.EqualFold(expected, hex.EncodeToString(sum[:])) {
+				http.Error(w, "Payload digest mismatch", http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		// Apply delta payloads to the installed image
+		if base := r.Header.Get("X-Delta-Base-Version"); base != "" {
+			image, status, err := ds.applyDelta(r, base, body)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+			ds.lastUpdateSize = int64(len(body))
+			body = image
+			ds.deltaCount++
+		} else {
+			ds.lastUpdateSize = int64(len(body))
+		}
+
 		// Simulate update process
-		ds.lastUpdateSize = int64(len(body))
+		ds.image = body
+		ds.lastManifest = manifest
 		ds.lastUpdateTime = time.Now()
 		ds.updateCount++
 
@@ -131,6 +192,85 @@ func NewDeviceServer(initialVersion string) *DeviceServer {
 	return ds
 }
 
+// readPayload reads the payload of an update request. Resumed uploads
+// (with a Content-Range header) are appended to the bytes held from the
+// interrupted upload. Must be called with ds.mu held.
+func (ds *DeviceServer) readPayload(r *http.Request) ([]byte, int, error) {
+	key := r.Header.Get("X-Payload-SHA256")
+
+	var start, total int64
+	contentRange := r.Header.Get("Content-Range")
+	if contentRange != "" {
+		if !ds.resumable {
+			return nil, http.StatusNotImplemented, errors.New("resumable uploads not supported")
+		}
+		var end int64
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid Content-Range: %q", contentRange)
+		}
+		if start != int64(len(ds.partial[key])) {
+			return nil, http.StatusRequestedRangeNotSatisfiable, fmt.Errorf("expected offset %d", len(ds.partial[key]))
+		}
+	}
+
+	// Simulate a link that drops mid-upload
+	var body io.Reader = r.Body
+	interrupted := ds.interruptAfter > 0
+	if interrupted {
+		body = io.LimitReader(r.Body, ds.interruptAfter)
+		ds.interruptAfter = 0
+	}
+
+	data, err := io.ReadAll(body)
+	ds.bytesReceived += int64(len(data))
+	if ds.resumable && key != "" {
+		ds.partial[key] = append(ds.partial[key][:start], data...)
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("failed to read body")
+	}
+	if interrupted {
+		return nil, http.StatusServiceUnavailable, errors.New("simulated connection loss")
+	}
+
+	if contentRange != "" {
+		data = ds.partial[key]
+		if int64(len(data)) != total {
+			return nil, http.StatusBadRequest, fmt.Errorf("received %d of %d bytes", len(data), total)
+		}
+	}
+	delete(ds.partial, key)
+	return data, 0, nil
+}
+
+// applyDelta patches the installed image with a delta payload and checks
+// the result against the target digest. Must be called with ds.mu held.
+func (ds *DeviceServer) applyDelta(r *http.Request, base string, patch []byte) ([]byte, int, error) {
+	if !ds.acceptDeltas {
+		return nil, http.StatusUnsupportedMediaType, errors.New("delta payloads not supported")
+	}
+	if base != ds.firmwareVersion || ds.image == nil {
+		return nil, http.StatusConflict, fmt.Errorf("running %s, not %s", ds.firmwareVersion, base)
+	}
+
+	image, err := delta.Patch(ds.image, patch)
+	if err != nil {
+		return nil, http.StatusConflict, err
+	}
+	sum := sha256.Sum256(image)
+	if !strings.EqualFold(r.Header.Get("X-Delta-Target-SHA256"), hex.EncodeToString(sum[:])) {
+		return nil, http.StatusConflict, errors.New("patched image digest mismatch")
+	}
+	return image, 0, nil
+}
+
+// isResumable reports whether the server supports resumable uploads.
+func (ds *DeviceServer) isResumable() bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.resumable
+}
+
 // URL returns the server URL.
 func (ds *DeviceServer) URL() string {
 	return ds.server.URL
@@ -162,6 +302,75 @@ func (ds *DeviceServer) GetLastUpdateSize() int64 {
 	return ds.lastUpdateSize
 }
 
+// GetLastManifest returns the update manifest received with the last update.
+func (ds *DeviceServer) GetLastManifest() []byte {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.lastManifest
+}
+
+// SetManifestKey makes the server reject updates whose manifest is not
+// signed with key.
+func (ds *DeviceServer) SetManifestKey(key ed25519.PublicKey) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.manifestKey = key
+}
+
+// SetResumable enables resumable uploads: the server reports the offset of
+// an interrupted upload on HEAD /update and accepts the rest with a
+// Content-Range header.
+func (ds *DeviceServer) SetResumable(resumable bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.resumable = resumable
+}
+
+// SetInterruptAfter makes the server drop the next upload after n bytes,
+// as a flaky link would.
+func (ds *DeviceServer) SetInterruptAfter(n int64) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.interruptAfter = n
+}
+
+// GetBytesReceived returns the payload bytes received over all uploads,
+// including interrupted ones.
+func (ds *DeviceServer) GetBytesReceived() int64 {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.bytesReceived
+}
+
+// SetImage sets the installed firmware image that delta payloads patch.
+func (ds *DeviceServer) SetImage(image []byte) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.image = image
+}
+
+// GetImage returns the installed firmware image.
+func (ds *DeviceServer) GetImage() []byte {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.image
+}
+
+// SetAcceptDeltas makes the server apply delta payloads to its installed
+// image (otherwise they are rejected with 415 Unsupported Media Type).
+func (ds *DeviceServer) SetAcceptDeltas(accept bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.acceptDeltas = accept
+}
+
+// GetDeltaCount returns the number of delta payloads applied.
+func (ds *DeviceServer) GetDeltaCount() int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.deltaCount
+}
+
 // SetFailNext configures the server to fail the next update.
 func (ds *DeviceServer) SetFailNext(fail bool) {
 	ds.mu.Lock()
@@ -185,6 +394,10 @@ func (ds *DeviceServer) Reset() {
 	ds.lastUpdateTime = time.Time{}
 	ds.failNext = false
 	ds.alwaysFail = false
+	ds.partial = make(map[string][]byte)
+	ds.interruptAfter = 0
+	ds.bytesReceived = 0
+	ds.deltaCount = 0
 }
 
 // MultiDeviceServer manages multiple mock device servers.
@@ -0,0 +1,226 @@
+package mocks
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// MQTTBroker is a minimal in-process MQTT 3.1.1 broker for tests. It
+// supports QoS 0 and 1 publishes (delivered to subscribers at QoS 0),
+// wildcard subscriptions and keepalives, but not retained messages or
+// persistent sessions.
+type MQTTBroker struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients map[*brokerClient]bool
+	drop    func(topic string, payload []byte) bool
+	wg      sync.WaitGroup
+}
+
+// brokerClient is a connected client and its subscriptions.
+type brokerClient struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	filters map[string]bool // Guarded by MQTTBroker.mu
+}
+
+// NewMQTTBroker starts a broker on a random local port.
+func NewMQTTBroker() (*MQTTBroker, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &MQTTBroker{
+		listener: listener,
+		clients:  make(map[*brokerClient]bool),
+	}
+	b.wg.Add(1)
+	go b.accept()
+	return b, nil
+}
+
+// URL returns the broker address for MQTT clients (e.g., "tcp://127.0.0.1:1883").
+func (b *MQTTBroker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// SetDropFunc makes the broker silently discard publishes for which drop
+// returns true, to simulate lost messages.
+func (b *MQTTBroker) SetDropFunc(drop func(topic string, payload []byte) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop = drop
+}
+
+// DisconnectAll drops every client connection without closing the broker,
+// to simulate a network outage. Clients may reconnect.
+func (b *MQTTBroker) DisconnectAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for client := range b.clients {
+		client.conn.Close()
+		delete(b.clients, client)
+	}
+}
+
+// Subscribed reports whether any connected client is subscribed to topic.
+func (b *MQTTBroker) Subscribed(topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for client := range b.clients {
+		for filter := range client.filters {
+			if topicMatches(filter, topic) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close disconnects all clients and stops the broker.
+func (b *MQTTBroker) Close() {
+	b.listener.Close()
+
+	b.mu.Lock()
+	for client := range b.clients {
+		client.conn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *MQTTBroker) accept() {
+	defer b.wg.Done()
+
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		client := &brokerClient{conn: conn, filters: make(map[string]bool)}
+		b.mu.Lock()
+		b.clients[client] = true
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go b.serve(client)
+	}
+}
+
+// serve handles a client's packets until it disconnects.
+func (b *MQTTBroker) serve(client *brokerClient) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, client)
+		b.mu.Unlock()
+		client.conn.Close()
+	}()
+
+	for {
+		packet, err := packets.ReadPacket(client.conn)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			connack.ReturnCode = packets.Accepted
+			client.write(connack)
+
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			b.mu.Lock()
+			for i, filter := range p.Topics {
+				client.filters[filter] = true
+				suback.ReturnCodes = append(suback.ReturnCodes, min(p.Qoss[i], 1))
+			}
+			b.mu.Unlock()
+			client.write(suback)
+
+		case *packets.UnsubscribePacket:
+			b.mu.Lock()
+			for _, filter := range p.Topics {
+				delete(client.filters, filter)
+			}
+			b.mu.Unlock()
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = p.MessageID
+			client.write(unsuback)
+
+		case *packets.PublishPacket:
+			if p.Qos > 0 {
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				client.write(puback)
+			}
+			b.route(p.TopicName, p.Payload)
+
+		case *packets.PingreqPacket:
+			client.write(packets.NewControlPacket(packets.Pingresp))
+
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// route delivers a publish to every client subscribed to its topic.
+func (b *MQTTBroker) route(topic string, payload []byte) {
+	b.mu.Lock()
+	if b.drop != nil && b.drop(topic, payload) {
+		b.mu.Unlock()
+		return
+	}
+	var subscribers []*brokerClient
+	for client := range b.clients {
+		for filter := range client.filters {
+			if topicMatches(filter, topic) {
+				subscribers = append(subscribers, client)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, client := range subscribers {
+		publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		publish.TopicName = topic
+		publish.Payload = payload
+		client.write(publish)
+	}
+}
+
+func (c *brokerClient) write(packet packets.ControlPacket) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	packet.Write(c.conn)
+}
+
+// topicMatches reports whether a topic matches a subscription filter, which
+// may contain + (one level) and # (all remaining levels) wildcards.
+func topicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
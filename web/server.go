@@ -1,16 +1,22 @@
 package web
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/dovaclean/go-update-orchestrator/pkg/core"
+	"github.com/dovaclean/go-update-orchestrator/pkg/events"
 	"github.com/dovaclean/go-update-orchestrator/pkg/orchestrator"
+	"github.com/dovaclean/go-update-orchestrator/pkg/payload/cache"
 	"github.com/dovaclean/go-update-orchestrator/pkg/registry"
 	"github.com/dovaclean/go-update-orchestrator/pkg/scheduler"
 )
@@ -31,11 +37,16 @@ type Server struct {
 	mu       sync.RWMutex
 
 	templates *template.Template
+
+	payloadCache *cache.Cache
+	pullDelivery http.Handler
 }
 
 // Config holds web server configuration.
 type Config struct {
-	Address string // Server address (e.g., ":8080")
+	Address      string       // Server address (e.g., ":8080")
+	PayloadCache *cache.Cache // Payload cache whose stats are shown (optional)
+	PullDelivery http.Handler // Device API of a pull delivery, served under /api/pull/ (optional)
 }
 
 // DefaultConfig returns default web server configuration.
@@ -57,7 +68,7 @@ func New(config *Config, orch *orchestrator.Orchestrator, sched *scheduler.Sched
 		return nil, err
 	}
 
-	return &Server{
+	s := &Server{
 		addr:         config.Address,
 		orchestrator: orch,
 		scheduler:    sched,
@@ -65,9 +76,51 @@ func New(config *Config, orch *orchestrator.Orchestrator, sched *scheduler.Sched
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
-		clients:   make(map[*websocket.Conn]bool),
-		templates: tmpl,
-	}, nil
+		clients:      make(map[*websocket.Conn]bool),
+		templates:    tmpl,
+		payloadCache: config.PayloadCache,
+		pullDelivery: config.PullDelivery,
+	}
+
+	// Forward rollout progress to WebSocket clients
+	if orch != nil {
+		for _, eventType := range []events.EventType{
+			events.EventPhaseStarted,
+			events.EventPhaseCompleted,
+			events.EventPhaseGateFailed,
+			events.EventPhaseAwaitingApproval,
+			events.EventPhaseApproved,
+			events.EventPhaseRejected,
+			events.EventRollbackStarted,
+			events.EventRecurringFired,
+			events.EventRecurringSkipped,
+			events.EventProgressUpdate,
+		} {
+			orch.Subscribe(eventType, events.HandlerFunc(s.broadcastEvent))
+		}
+	}
+
+	return s, nil
+}
+
+// eventMessage is the WebSocket representation of an orchestrator event.
+type eventMessage struct {
+	Type      events.EventType       `json:"type"`
+	UpdateID  string                 `json:"update_id"`
+	DeviceID  string                 `json:"device_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// broadcastEvent forwards an orchestrator event to all WebSocket clients.
+func (s *Server) broadcastEvent(ctx context.Context, event events.Event) {
+	s.Broadcast(eventMessage{
+		Type:      event.Type,
+		UpdateID:  event.UpdateID,
+		DeviceID:  event.DeviceID,
+		Timestamp: event.Timestamp,
+		Data:      event.Data,
+	})
 }
 
 // Start starts the web server.
@@ -85,9 +138,20 @@ func (s *Server) Start() error {
 
 	// API endpoints
 	mux.HandleFunc("/api/devices", s.handleDevicesAPI)
+	mux.HandleFunc("/api/devices/heartbeat", s.handleHeartbeat)
 	mux.HandleFunc("/api/updates", s.handleUpdatesAPI)
 	mux.HandleFunc("/api/updates/schedule", s.handleScheduleUpdate)
 	mux.HandleFunc("/api/updates/cancel", s.handleCancelUpdate)
+	mux.HandleFunc("/api/updates/pause", s.handlePauseUpdate)
+	mux.HandleFunc("/api/updates/resume", s.handleResumeUpdate)
+	mux.HandleFunc("/api/updates/approve", s.handleApproveUpdate)
+	mux.HandleFunc("/api/updates/reject", s.handleRejectUpdate)
+	mux.HandleFunc("/api/updates/upcoming", s.handleUpcomingRuns)
+	mux.HandleFunc("/api/cache", s.handleCacheStats)
+	mux.HandleFunc("/api/relays", s.handleRelays)
+	if s.pullDelivery != nil {
+		mux.Handle("/api/pull/", s.pullDelivery)
+	}
 
 	// WebSocket
 	mux.HandleFunc("/ws", s.handleWebSocket)
@@ -181,6 +245,35 @@ func (s *Server) handleDevicesAPI(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(devices)
 }
 
+// handleHeartbeat records a device check-in, triggering any on-connect updates for it.
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		DeviceID        string `json:"device_id"`
+		FirmwareVersion string `json:"firmware_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.scheduler.Heartbeat(r.Context(), req.DeviceID, req.FirmwareVersion); err != nil {
+		if errors.Is(err, core.ErrDeviceNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 func (s *Server) handleUpdatesAPI(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -233,14 +326,213 @@ func (s *Server) handleCancelUpdate(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	if err := s.scheduler.Cancel(ctx, req.UpdateID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		// Updates executed directly through the orchestrator are unknown to the scheduler
+		if cancelErr := s.orchestrator.Cancel(ctx, req.UpdateID); cancelErr != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
 }
 
+func (s *Server) handlePauseUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UpdateID string `json:"update_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if err := s.scheduler.Pause(ctx, req.UpdateID); err != nil {
+		// Updates executed directly through the orchestrator are unknown to the scheduler
+		if pauseErr := s.orchestrator.Pause(ctx, req.UpdateID); pauseErr != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "paused"})
+}
+
+func (s *Server) handleResumeUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UpdateID string `json:"update_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if err := s.scheduler.Resume(ctx, req.UpdateID); err != nil {
+		// Updates executed directly through the orchestrator are unknown to the scheduler
+		if resumeErr := s.orchestrator.Resume(ctx, req.UpdateID); resumeErr != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "resumed"})
+}
+
+// approvalRequest is the body of approve and reject requests.
+type approvalRequest struct {
+	UpdateID string `json:"update_id"`
+	Phase    string `json:"phase"`
+	Approver string `json:"approver"`
+	Reason   string `json:"reason"`
+}
+
+func (s *Server) handleApproveUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req approvalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.scheduler.Approve(r.Context(), req.UpdateID, req.Phase, req.Approver); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "approved"})
+}
+
+func (s *Server) handleRejectUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req approvalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.scheduler.Reject(r.Context(), req.UpdateID, req.Phase, req.Approver, req.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "rejected"})
+}
+
+// handleUpcomingRuns lists the next fire times of a recurring update
+// (?update_id=...&count=N, default 5).
+func (s *Server) handleUpcomingRuns(w http.ResponseWriter, r *http.Request) {
+	updateID := r.URL.Query().Get("update_id")
+
+	count := 5
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	times, err := s.scheduler.Upcoming(r.Context(), updateID, count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(times)
+}
+
+// handleCacheStats reports payload cache statistics, or 404 if the server
+// has no payload cache.
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if s.payloadCache == nil {
+		http.Error(w, "payload cache not configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(s.payloadCache.Stats())
+}
+
+// handleRelays lists (GET), assigns (POST) or removes (DELETE ?location=)
+// store relays, or returns 404 if the registry does not track relays.
+func (s *Server) handleRelays(w http.ResponseWriter, r *http.Request) {
+	relays, ok := s.registry.(registry.RelayRegistry)
+	if !ok {
+		http.Error(w, "registry does not support relays", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := relays.ListRelays(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		var relay core.Relay
+		if err := json.NewDecoder(r.Body).Decode(&relay); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if relay.Location == "" || relay.Address == "" {
+			http.Error(w, "location and address are required", http.StatusBadRequest)
+			return
+		}
+		if err := relays.SetRelay(ctx, relay); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]string{"status": "assigned"})
+
+	case http.MethodDelete:
+		err := relays.RemoveRelay(ctx, r.URL.Query().Get("location"))
+		if errors.Is(err, core.ErrRelayNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // WebSocket Handler
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -270,9 +562,10 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 // Broadcast sends a message to all connected WebSocket clients.
+// Writes are serialized since a connection supports only one concurrent writer.
 func (s *Server) Broadcast(message interface{}) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	data, err := json.Marshal(message)
 	if err != nil {
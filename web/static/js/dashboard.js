@@ -49,6 +49,46 @@ async function loadDashboardStats() {
         console.error('Failed to load dashboard stats:', err);
     }
 }
+async function loadCacheStats() {
+    try {
+        // The cache section stays hidden unless the server has a payload cache
+        const resp = await fetch('/api/cache');
+        if (!resp.ok) {
+            return;
+        }
+        const stats = await resp.json();
+        const usage = stats.max_bytes > 0
+            ? `${formatBytes(stats.bytes)} / ${formatBytes(stats.max_bytes)}`
+            : formatBytes(stats.bytes);
+        setText('cache-usage', usage);
+        setText('cache-entries', `${stats.entries} (${stats.referenced} in use)`);
+        const lookups = stats.hits + stats.misses;
+        setText('cache-hit-rate', lookups > 0 ? `${Math.round(stats.hits * 100 / lookups)}%` : '-');
+        const section = document.getElementById('cache-stats');
+        if (section) {
+            section.removeAttribute('hidden');
+        }
+    }
+    catch (err) {
+        console.error('Failed to load cache stats:', err);
+    }
+}
+function setText(id, text) {
+    const el = document.getElementById(id);
+    if (el) {
+        el.textContent = text;
+    }
+}
+function formatBytes(bytes) {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let value = bytes;
+    let unit = 0;
+    while (value >= 1024 && unit < units.length - 1) {
+        value /= 1024;
+        unit++;
+    }
+    return `${value.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+}
 function escapeHtml(text) {
     const div = document.createElement('div');
     div.textContent = text;
@@ -57,7 +97,9 @@ function escapeHtml(text) {
 // Load stats on page load
 document.addEventListener('DOMContentLoaded', () => {
     loadDashboardStats();
+    loadCacheStats();
     // Refresh every 5 seconds
     setInterval(loadDashboardStats, 5000);
+    setInterval(loadCacheStats, 5000);
 });
 export {};
@@ -1,4 +1,4 @@
-import type { Device, UpdateStatus, Stats } from './types.js';
+import type { Device, UpdateStatus, Stats, CacheStats } from './types.js';
 
 async function loadDashboardStats(): Promise<void> {
     try {
@@ -55,6 +55,51 @@ async function loadDashboardStats(): Promise<void> {
     }
 }
 
+async function loadCacheStats(): Promise<void> {
+    try {
+        // The cache section stays hidden unless the server has a payload cache
+        const resp = await fetch('/api/cache');
+        if (!resp.ok) {
+            return;
+        }
+        const stats: CacheStats = await resp.json();
+
+        const usage = stats.max_bytes > 0
+            ? `${formatBytes(stats.bytes)} / ${formatBytes(stats.max_bytes)}`
+            : formatBytes(stats.bytes);
+        setText('cache-usage', usage);
+        setText('cache-entries', `${stats.entries} (${stats.referenced} in use)`);
+
+        const lookups = stats.hits + stats.misses;
+        setText('cache-hit-rate', lookups > 0 ? `${Math.round(stats.hits * 100 / lookups)}%` : '-');
+
+        const section = document.getElementById('cache-stats');
+        if (section) {
+            section.removeAttribute('hidden');
+        }
+    } catch (err) {
+        console.error('Failed to load cache stats:', err);
+    }
+}
+
+function setText(id: string, text: string): void {
+    const el = document.getElementById(id);
+    if (el) {
+        el.textContent = text;
+    }
+}
+
+function formatBytes(bytes: number): string {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let value = bytes;
+    let unit = 0;
+    while (value >= 1024 && unit < units.length - 1) {
+        value /= 1024;
+        unit++;
+    }
+    return `${value.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+}
+
 function escapeHtml(text: string): string {
     const div = document.createElement('div');
     div.textContent = text;
@@ -64,6 +109,8 @@ function escapeHtml(text: string): string {
 // Load stats on page load
 document.addEventListener('DOMContentLoaded', () => {
     loadDashboardStats();
+    loadCacheStats();
     // Refresh every 5 seconds
     setInterval(loadDashboardStats, 5000);
+    setInterval(loadCacheStats, 5000);
 });
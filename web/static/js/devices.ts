@@ -1,37 +1,78 @@
-import type { Device } from './types.js';
+import type { Device, EventMessage } from './types.js';
+
+// Latest transfer progress event per device, pushed over the WebSocket
+const transfers: Record<string, EventMessage> = {};
+let devices: Device[] = [];
 
 async function loadDevices(): Promise<void> {
     try {
         const resp = await fetch('/api/devices');
-        const devices: Device[] = await resp.json();
-
-        const tbody = document.querySelector('#devices-table tbody');
-        if (!tbody) return;
-
-        if (devices.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="6" class="loading">No devices found</td></tr>';
-            return;
-        }
-
-        tbody.innerHTML = devices.map(device => `
-            <tr>
-                <td><code>${escapeHtml(device.ID)}</code></td>
-                <td>${escapeHtml(device.Name || '-')}</td>
-                <td>${escapeHtml(device.Address)}</td>
-                <td><span class="status-badge status-${device.Status}">${device.Status}</span></td>
-                <td>${escapeHtml(device.FirmwareVersion || '-')}</td>
-                <td>${escapeHtml(device.Location || '-')}</td>
-            </tr>
-        `).join('');
+        devices = await resp.json();
+        renderDevices();
     } catch (err) {
         console.error('Failed to load devices:', err);
         const tbody = document.querySelector('#devices-table tbody');
         if (tbody) {
-            tbody.innerHTML = '<tr><td colspan="6" class="loading">Error loading devices</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="7" class="loading">Error loading devices</td></tr>';
         }
     }
 }
 
+function renderDevices(): void {
+    const tbody = document.querySelector('#devices-table tbody');
+    if (!tbody) return;
+
+    if (devices.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="7" class="loading">No devices found</td></tr>';
+        return;
+    }
+
+    tbody.innerHTML = devices.map(device => `
+        <tr>
+            <td><code>${escapeHtml(device.ID)}</code></td>
+            <td>${escapeHtml(device.Name || '-')}</td>
+            <td>${escapeHtml(device.Address)}</td>
+            <td><span class="status-badge status-${device.Status}">${device.Status}</span></td>
+            <td>${escapeHtml(device.FirmwareVersion || '-')}</td>
+            <td>${escapeHtml(device.Location || '-')}</td>
+            <td>${formatTransfer(device.ID)}</td>
+        </tr>
+    `).join('');
+}
+
+function formatTransfer(deviceID: string): string {
+    const event = transfers[deviceID];
+    if (!event || !event.data || event.data.percent === undefined) return '-';
+
+    const percent = Number(event.data.percent);
+    return `
+        <div class="progress-bar">
+            <div class="progress-fill" style="width: ${percent}%"></div>
+        </div>
+        <div class="progress-text">${escapeHtml(event.update_id)}: ${percent}%</div>
+    `;
+}
+
+function trackEvents(): void {
+    const protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const ws = new WebSocket(`${protocol}//${location.host}/ws`);
+    ws.onmessage = (msg) => {
+        const event: EventMessage = JSON.parse(msg.data);
+        if (event.type !== 'progress.update' || !event.device_id) return;
+
+        // Events may arrive out of order; keep the furthest progress of an update
+        const previous = transfers[event.device_id];
+        if (previous && previous.update_id === event.update_id &&
+            Number(previous.data?.percent) > Number(event.data?.percent)) {
+            return;
+        }
+        transfers[event.device_id] = event;
+        renderDevices();
+    };
+    // Reconnect if the server restarts
+    ws.onclose = () => setTimeout(trackEvents, 5000);
+}
+
 function escapeHtml(text: string): string {
     const div = document.createElement('div');
     div.textContent = text;
@@ -41,6 +82,7 @@ function escapeHtml(text: string): string {
 // Load devices on page load
 document.addEventListener('DOMContentLoaded', () => {
     loadDevices();
+    trackEvents();
     // Refresh every 10 seconds
     setInterval(loadDevices, 10000);
 });
@@ -26,9 +26,35 @@ export interface UpdateStatus {
     StartedAt: string;
     CompletedAt: string | null;
     EstimatedEnd: string | null;
+    EstimatedEarliest: string | null;
+    EstimatedLatest: string | null;
+    Throughput: number;
+    RollbackID: string;
+    RollbackOf: string;
+    PendingApproval: string;
+    Approvals: Approval[] | null;
+    InstanceOf: string;
+    NextRun: string | null;
 }
 
-export type UpdateStatusType = 'pending' | 'scheduled' | 'in_progress' | 'completed' | 'failed' | 'cancelled';
+export interface Approval {
+    Phase: string;
+    Approver: string;
+    Approved: boolean;
+    Reason: string;
+    Time: string;
+}
+
+export type UpdateStatusType = 'pending' | 'scheduled' | 'in_progress' | 'completed' | 'failed' | 'cancelled' | 'paused' | 'awaiting_approval';
+
+// Event pushed over the /ws WebSocket
+export interface EventMessage {
+    type: string;
+    update_id: string;
+    device_id?: string;
+    timestamp: string;
+    data?: Record<string, unknown>;
+}
 
 export interface DeviceUpdateStatus {
     Status: string;
@@ -42,3 +68,14 @@ export interface Stats {
     onlineDevices: number;
     activeUpdates: number;
 }
+
+// Payload cache statistics from /api/cache
+export interface CacheStats {
+    entries: number;
+    bytes: number;
+    max_bytes: number;
+    referenced: number;
+    hits: number;
+    misses: number;
+    evictions: number;
+}
@@ -1,3 +1,5 @@
+// Latest rollout phase event per update, pushed over the WebSocket
+const phases = {};
 async function loadUpdates() {
     try {
         const resp = await fetch('/api/updates');
@@ -6,7 +8,7 @@ async function loadUpdates() {
         if (!tbody)
             return;
         if (updates.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="6" class="loading">No updates scheduled</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="8" class="loading">No updates scheduled</td></tr>';
             return;
         }
         tbody.innerHTML = updates.map(update => {
@@ -17,6 +19,7 @@ async function loadUpdates() {
                 <tr>
                     <td><code>${escapeHtml(update.UpdateID)}</code></td>
                     <td><span class="status-badge status-${update.Status}">${update.Status}</span></td>
+                    <td>${formatPhase(update.UpdateID)}</td>
                     <td>${update.TotalDevices}</td>
                     <td>${update.Completed}</td>
                     <td>${update.Failed}</td>
@@ -26,6 +29,7 @@ async function loadUpdates() {
                         </div>
                         <div class="progress-text">${progress}%</div>
                     </td>
+                    <td>${formatETA(update)}</td>
                 </tr>
             `;
         }).join('');
@@ -34,10 +38,64 @@ async function loadUpdates() {
         console.error('Failed to load updates:', err);
         const tbody = document.querySelector('#updates-table tbody');
         if (tbody) {
-            tbody.innerHTML = '<tr><td colspan="6" class="loading">Error loading updates</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="8" class="loading">Error loading updates</td></tr>';
         }
     }
 }
+// formatETA shows the estimated time left and its confidence range
+function formatETA(update) {
+    if (!update.EstimatedEnd)
+        return '-';
+    const left = (end) => {
+        if (!end)
+            return '?';
+        const seconds = Math.max(0, Math.round((Date.parse(end) - Date.now()) / 1000));
+        return seconds < 60 ? `${seconds}s` : `${Math.round(seconds / 60)}m`;
+    };
+    let text = left(update.EstimatedEnd);
+    if (update.EstimatedEarliest && update.EstimatedLatest) {
+        text += ` <span class="eta-range">(${left(update.EstimatedEarliest)}–${left(update.EstimatedLatest)})</span>`;
+    }
+    if (update.Throughput > 0) {
+        text += `<div class="eta-range">${(update.Throughput / 1024 / 1024).toFixed(1)} MB/s</div>`;
+    }
+    return text;
+}
+function formatPhase(updateID) {
+    const event = phases[updateID];
+    if (!event || !event.data)
+        return '-';
+    const phase = escapeHtml(String(event.data.phase));
+    switch (event.type) {
+        case 'phase.started':
+            return `${phase}: running`;
+        case 'phase.completed':
+            return `${phase}: ${event.data.success_rate}% succeeded`;
+        case 'phase.awaiting_approval':
+            return `${phase}: awaiting approval`;
+        case 'phase.approved':
+            return `${phase}: approved by ${escapeHtml(String(event.data.approver))}`;
+        case 'phase.rejected':
+            return `<span class="phase-gate-failed">${phase}: rejected by ${escapeHtml(String(event.data.approver))}</span>`;
+        case 'phase.gate_failed':
+            return `<span class="phase-gate-failed">${phase}: ${event.data.success_rate}% &lt; ${event.data.success_threshold}% (${escapeHtml(String(event.data.action))})</span>`;
+        default:
+            return '-';
+    }
+}
+function trackEvents() {
+    const protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const ws = new WebSocket(`${protocol}//${location.host}/ws`);
+    ws.onmessage = (msg) => {
+        const event = JSON.parse(msg.data);
+        if (event.type.startsWith('phase.')) {
+            phases[event.update_id] = event;
+            loadUpdates();
+        }
+    };
+    // Reconnect if the server restarts
+    ws.onclose = () => setTimeout(trackEvents, 5000);
+}
 function escapeHtml(text) {
     const div = document.createElement('div');
     div.textContent = text;
@@ -46,6 +104,7 @@ function escapeHtml(text) {
 // Load updates on page load
 document.addEventListener('DOMContentLoaded', () => {
     loadUpdates();
+    trackEvents();
     // Refresh every 2 seconds for real-time updates
     setInterval(loadUpdates, 2000);
 });